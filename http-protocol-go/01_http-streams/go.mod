@@ -1,3 +1,11 @@
 module github.com/akhdanfadh/bootdev-courses/http-protocol-go
 
 go 1.24.6
+
+require github.com/stretchr/testify v1.10.0
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,124 @@
+package lines
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"time"
+)
+
+// Delimiter is the end of line sequence a stream splits on.
+type Delimiter int
+
+const (
+	// LF ends lines at "\n", dropping a "\r" before it, so files written on
+	// Windows read the same.
+	LF Delimiter = iota
+	// CRLF ends lines only at "\r\n", as in HTTP/1.1 messages, a bare "\n" is
+	// part of the line.
+	CRLF
+)
+
+// Options configures a line stream.
+type Options struct {
+	Delimiter Delimiter
+	// MaxLineLength bounds a line, delimiter included, defaults to 64 KiB.
+	// A longer line ends the stream with ErrLineTooLong.
+	MaxLineLength int
+}
+
+// Line is a value of a stream. The last value of a stream that failed carries
+// the error, and no text.
+type Line struct {
+	Text string
+	Err  error
+}
+
+const defaultMaxLineLength = 64 << 10
+
+// ErrLineTooLong is the error of a line longer than Options.MaxLineLength.
+var ErrLineTooLong = bufio.ErrTooLong
+
+// Stream reads lines from r and sends them on the returned channel, which is
+// closed at the end of r, after an error, or once ctx is done. A last line
+// without a delimiter is sent too. Cancellation interrupts a blocked read if
+// r has a read deadline, e.g., a net.Conn, and is not reported as an error.
+func Stream(ctx context.Context, r io.Reader, opts Options) <-chan Line {
+	if opts.MaxLineLength <= 0 {
+		opts.MaxLineLength = defaultMaxLineLength
+	}
+	lineChan := make(chan Line)
+	go func() {
+		defer close(lineChan) // ensure channel is closed when done
+
+		if d, ok := r.(interface{ SetReadDeadline(time.Time) error }); ok {
+			stop := context.AfterFunc(ctx, func() { d.SetReadDeadline(time.Now()) })
+			defer stop()
+		}
+		tr := &trackingReader{r: r}
+		scanner := bufio.NewScanner(tr)
+		scanner.Buffer(make([]byte, 0, min(4096, opts.MaxLineLength)), opts.MaxLineLength)
+		scanner.Split(splitFunc(opts.Delimiter, tr))
+		for scanner.Scan() {
+			select {
+			case lineChan <- Line{Text: scanner.Text()}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			select {
+			case lineChan <- Line{Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return lineChan
+}
+
+// trackingReader records the read error, other than io.EOF.
+type trackingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (tr *trackingReader) Read(p []byte) (int, error) {
+	n, err := tr.r.Read(p)
+	if err != nil && err != io.EOF {
+		tr.err = err
+	}
+	return n, err
+}
+
+// splitFunc returns a bufio.SplitFunc splitting on the delimiter. The rest of
+// the data is a last line only at the end of r, not after a read error, since
+// the line may have been cut short.
+func splitFunc(delim Delimiter, tr *trackingReader) bufio.SplitFunc {
+	sep := []byte("\n")
+	if delim == CRLF {
+		sep = []byte("\r\n")
+	}
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.Index(data, sep); i >= 0 {
+			line := data[:i]
+			if delim == LF {
+				line = bytes.TrimSuffix(line, []byte("\r"))
+			}
+			return i + len(sep), line, nil
+		}
+		if atEOF {
+			if tr.err != nil {
+				return 0, nil, tr.err
+			}
+			if delim == LF {
+				return len(data), bytes.TrimSuffix(data, []byte("\r")), nil
+			}
+			return len(data), data, nil // last line without a delimiter
+		}
+		return 0, nil, nil // need more data
+	}
+}
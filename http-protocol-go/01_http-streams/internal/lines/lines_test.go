@@ -0,0 +1,120 @@
+package lines
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkReader reads at most numBytesPerRead bytes at a time.
+type chunkReader struct {
+	data            string
+	numBytesPerRead int
+	pos             int
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	if cr.pos >= len(cr.data) {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(len(p), cr.numBytesPerRead)], cr.data[cr.pos:])
+	cr.pos += n
+	return n, nil
+}
+
+// errReader fails after its data.
+type errReader struct {
+	data string
+	err  error
+}
+
+func (er *errReader) Read(p []byte) (int, error) {
+	if er.data == "" {
+		return 0, er.err
+	}
+	n := copy(p, er.data)
+	er.data = er.data[n:]
+	return n, nil
+}
+
+// collect drains the stream, returning its lines and error, if any.
+func collect(lineChan <-chan Line) ([]string, error) {
+	lines := make([]string, 0)
+	for line := range lineChan {
+		if line.Err != nil {
+			return lines, line.Err
+		}
+		lines = append(lines, line.Text)
+	}
+	return lines, nil
+}
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+
+	// Test: LF and CRLF endings, with a last line without delimiter, split across reads
+	got, err := collect(Stream(ctx, &chunkReader{data: "one\r\ntwo\n\nthree", numBytesPerRead: 3}, Options{}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "two", "", "three"}, got)
+
+	// Test: CRLF only splits on CRLF
+	got, err = collect(Stream(ctx, &chunkReader{data: "a\nb\r\nc\r\n", numBytesPerRead: 1}, Options{Delimiter: CRLF}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a\nb", "c"}, got)
+
+	// Test: Empty input
+	got, err = collect(Stream(ctx, strings.NewReader(""), Options{}))
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	// Test: Line over the maximum length ends the stream
+	got, err = collect(Stream(ctx, strings.NewReader("short\n"+strings.Repeat("x", 100)+"\nafter\n"), Options{MaxLineLength: 50}))
+	require.ErrorIs(t, err, ErrLineTooLong)
+	assert.Equal(t, []string{"short"}, got)
+
+	// Test: Read errors are sent after the lines read so far
+	got, err = collect(Stream(ctx, &errReader{data: "one\ntwo", err: io.ErrUnexpectedEOF}, Options{}))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, []string{"one"}, got)
+}
+
+func TestStreamCancel(t *testing.T) {
+	// Test: Cancellation stops a stream whose consumer stopped reading
+	ctx, cancel := context.WithCancel(context.Background())
+	lineChan := Stream(ctx, strings.NewReader("one\ntwo\nthree\n"), Options{})
+	assert.Equal(t, "one", (<-lineChan).Text)
+	cancel()
+	select {
+	case <-lineChan: // either a line already being sent, or closed
+	case <-time.After(time.Second):
+		require.FailNow(t, "stream not stopped")
+	}
+
+	// Test: Cancellation interrupts a blocked read on a connection, without an error
+	server, client := net.Pipe()
+	defer client.Close()
+	ctx, cancel = context.WithCancel(context.Background())
+	lineChan = Stream(ctx, server, Options{Delimiter: CRLF})
+	_, err := client.Write([]byte("GET / HTTP/1.1\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Line{Text: "GET / HTTP/1.1"}, <-lineChan)
+	cancel()
+	done := make(chan []string)
+	go func() {
+		got, err := collect(lineChan)
+		assert.NoError(t, err)
+		done <- got
+	}()
+	select {
+	case got := <-done:
+		assert.Empty(t, got)
+	case <-time.After(time.Second):
+		require.FailNow(t, "blocked read not interrupted")
+	}
+}
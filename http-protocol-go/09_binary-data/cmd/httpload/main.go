@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/client"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+)
+
+// headerFlags collects repeated -H flags.
+type headerFlags []string
+
+func (h *headerFlags) String() string     { return strings.Join(*h, ", ") }
+func (h *headerFlags) Set(v string) error { *h = append(*h, v); return nil }
+
+func main() {
+	concurrency := flag.Int("c", 10, "number of concurrent connections")
+	requests := flag.Int("n", 0, "total number of requests, 0 runs for the duration instead")
+	duration := flag.Duration("d", 10*time.Second, "how long to run when -n is 0")
+	keepAlive := flag.Bool("keepalive", true, "reuse connections for several requests")
+	method := flag.String("m", "GET", "request method")
+	body := flag.String("body", "", "request body")
+	timeout := flag.Duration("timeout", 5*time.Second, "timeout for each request")
+	var extraHeaders headerFlags
+	flag.Var(&extraHeaders, "H", `extra request header as "Key: Value", can be repeated`)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] http://host:port/path\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *concurrency < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	addr, req, err := buildRequest(flag.Arg(0), *method, *body, extraHeaders, *keepAlive)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Stop on the first of: all requests done, duration elapsed, or interrupted
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	stopAll := func() { stopOnce.Do(func() { close(stop) }) }
+	if *requests == 0 {
+		time.AfterFunc(*duration, stopAll)
+	}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() { <-sigChan; stopAll() }()
+
+	fmt.Printf("Running against %s with %d connections (keep-alive: %v)...\n", flag.Arg(0), *concurrency, *keepAlive)
+	var issued atomic.Int64
+	results := make([]*stats, *concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range *concurrency {
+		results[i] = newStats()
+		wg.Add(1)
+		go func(st *stats) {
+			defer wg.Done()
+			w := &worker{addr: addr, req: req, timeout: *timeout, keepAlive: *keepAlive, stats: st}
+			defer w.close()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if *requests > 0 && issued.Add(1) > int64(*requests) {
+					return
+				}
+				w.do()
+			}
+		}(results[i])
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	total := newStats()
+	for _, st := range results {
+		total.merge(st)
+	}
+	total.report(os.Stdout, elapsed)
+}
+
+// buildRequest parses the target URL into a dial address and a request.
+func buildRequest(rawURL, method, body string, extraHeaders []string, keepAlive bool) (string, *request.Request, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil, err
+	}
+	if u.Scheme != "http" {
+		return "", nil, fmt.Errorf("only http:// URLs are supported, got %q", rawURL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "80")
+	}
+	target := u.RequestURI()
+
+	h := headers.NewHeaders()
+	h.Set("Host", u.Host)
+	h.Set("User-Agent", "httpload")
+	if !keepAlive {
+		h.Set("Connection", "close")
+	}
+	for _, header := range extraHeaders {
+		key, value, found := strings.Cut(header, ":")
+		if !found {
+			return "", nil, fmt.Errorf("invalid header %q, expected \"Key: Value\"", header)
+		}
+		h.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+	return addr, &request.Request{
+		RequestLine: request.RequestLine{Method: method, RequestTarget: target, HTTPVersion: "1.1"},
+		Headers:     h,
+		Body:        []byte(body),
+	}, nil
+}
+
+// worker sends requests in sequence on its own connection.
+type worker struct {
+	addr      string
+	req       *request.Request
+	timeout   time.Duration
+	keepAlive bool
+	stats     *stats
+	conn      *client.Conn
+}
+
+func (w *worker) do() {
+	start := time.Now()
+	if w.conn == nil || w.conn.Closed() {
+		conn, err := client.Dial(w.addr, w.timeout)
+		if err != nil {
+			w.stats.recordError(classify("dial", err))
+			return
+		}
+		w.conn = conn
+	}
+	resp, err := w.conn.Do(w.req)
+	if err != nil {
+		w.stats.recordError(classify("exchange", err))
+		return
+	}
+	w.stats.recordResponse(int(resp.StatusLine.StatusCode), time.Since(start), len(resp.Body))
+	if !w.keepAlive {
+		w.close()
+	}
+}
+
+func (w *worker) close() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+}
+
+// classify returns a short error kind for the breakdown in the report.
+func classify(stage string, err error) string {
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return stage + ": timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return stage + ": connection refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return stage + ": connection reset"
+	case errors.Is(err, syscall.EPIPE):
+		return stage + ": broken pipe"
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return stage + ": " + opErr.Op + " error"
+	}
+	return stage + ": invalid response"
+}
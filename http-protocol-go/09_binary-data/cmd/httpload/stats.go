@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+// stats collects the outcome of requests, one per worker so no locking is needed.
+type stats struct {
+	latencies []time.Duration
+	statuses  map[int]int
+	errors    map[string]int
+	bodyBytes int64
+}
+
+func newStats() *stats {
+	return &stats{
+		latencies: make([]time.Duration, 0, 1024),
+		statuses:  make(map[int]int),
+		errors:    make(map[string]int),
+	}
+}
+
+func (s *stats) recordResponse(status int, latency time.Duration, bodyLen int) {
+	s.latencies = append(s.latencies, latency)
+	s.statuses[status]++
+	s.bodyBytes += int64(bodyLen)
+}
+
+func (s *stats) recordError(kind string) {
+	s.errors[kind]++
+}
+
+func (s *stats) merge(other *stats) {
+	s.latencies = append(s.latencies, other.latencies...)
+	for status, count := range other.statuses {
+		s.statuses[status] += count
+	}
+	for kind, count := range other.errors {
+		s.errors[kind] += count
+	}
+	s.bodyBytes += other.bodyBytes
+}
+
+func (s *stats) report(w io.Writer, elapsed time.Duration) {
+	responses := len(s.latencies)
+	errors := 0
+	for _, count := range s.errors {
+		errors += count
+	}
+	seconds := elapsed.Seconds()
+
+	fmt.Fprintf(w, "\nSummary:\n")
+	fmt.Fprintf(w, "  Duration:    %v\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "  Requests:    %d (%d responses, %d errors)\n", responses+errors, responses, errors)
+	fmt.Fprintf(w, "  Throughput:  %.1f req/s, %.1f KiB/s of body\n", float64(responses)/seconds, float64(s.bodyBytes)/1024/seconds)
+	if responses > 0 {
+		slices.Sort(s.latencies)
+		var sum time.Duration
+		for _, l := range s.latencies {
+			sum += l
+		}
+		fmt.Fprintf(w, "\nLatency:\n")
+		fmt.Fprintf(w, "  Min:   %v\n", s.latencies[0])
+		fmt.Fprintf(w, "  Mean:  %v\n", sum/time.Duration(responses))
+		for _, p := range []float64{50, 90, 95, 99} {
+			fmt.Fprintf(w, "  p%-4s %v\n", fmt.Sprintf("%g:", p), percentile(s.latencies, p))
+		}
+		fmt.Fprintf(w, "  Max:   %v\n", s.latencies[responses-1])
+	}
+	if len(s.statuses) > 0 {
+		fmt.Fprintf(w, "\nStatus codes:\n")
+		for _, status := range slices.Sorted(maps.Keys(s.statuses)) {
+			fmt.Fprintf(w, "  %d: %d\n", status, s.statuses[status])
+		}
+	}
+	if errors > 0 {
+		fmt.Fprintf(w, "\nErrors:\n")
+		for _, kind := range slices.Sorted(maps.Keys(s.errors)) {
+			fmt.Fprintf(w, "  %s: %d\n", kind, s.errors[kind])
+		}
+	}
+}
+
+// percentile returns the p-th percentile of sorted latencies, using the nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentile(t *testing.T) {
+	// latencies returns n sorted latencies of 1ms, 2ms, ..., n ms
+	latencies := func(n int) []time.Duration {
+		sorted := make([]time.Duration, n)
+		for i := range sorted {
+			sorted[i] = time.Duration(i+1) * time.Millisecond
+		}
+		return sorted
+	}
+	cases := []struct {
+		n        int
+		p        float64
+		expected time.Duration
+	}{
+		{6, 20, 2 * time.Millisecond}, // rank ceil(1.2) = 2, rounding would give 1
+		{6, 50, 3 * time.Millisecond},
+		{6, 90, 6 * time.Millisecond},
+		{6, 100, 6 * time.Millisecond},
+		{6, 0, 1 * time.Millisecond}, // clamped to the first
+		{5, 30, 2 * time.Millisecond},
+		{1, 99, 1 * time.Millisecond},
+		{100, 99, 99 * time.Millisecond},
+		{100, 99.5, 100 * time.Millisecond},
+	}
+	for _, c := range cases {
+		// Test: Nearest rank is the smallest value with at least p% at or below it
+		assert.Equal(t, c.expected, percentile(latencies(c.n), c.p), "n=%d p=%g", c.n, c.p)
+	}
+}
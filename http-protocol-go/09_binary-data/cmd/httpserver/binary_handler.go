@@ -13,21 +13,27 @@ const videoPath = "assets/vim.mp4"
 
 func videoHandler(w *response.Writer, req *request.Request) {
 	if req.RequestLine.RequestTarget != "/video" {
-		httpbinHandlerError(w, response.StatusBadRequest, "Invalid request target, expected /video")
+		httpbinHandlerError(w, req, response.StatusBadRequest, "Invalid request target, expected /video")
 		return
 	}
 
-	data, err := os.ReadFile(videoPath)
+	f, err := os.Open(videoPath)
 	if err != nil {
-		httpbinHandlerError(w, response.StatusInternalServerError, "Could not read video file")
+		httpbinHandlerError(w, req, response.StatusInternalServerError, "Could not open video file")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		httpbinHandlerError(w, req, response.StatusInternalServerError, "Could not read video file")
 		return
 	}
 
 	h := headers.NewHeaders()
-	h.Set("Content-Length", fmt.Sprintf("%d", len(data)))
+	h.Set("Content-Length", fmt.Sprintf("%d", info.Size()))
 	h.Set("Connection", "close")
 	h.Set("Content-Type", "video/mp4")
 	w.WriteStatusLine(response.StatusOK)
 	w.WriteHeaders(h)
-	w.WriteBody(data)
+	w.WriteFileBody(f) // zero-copy when possible, instead of reading it all to memory
 }
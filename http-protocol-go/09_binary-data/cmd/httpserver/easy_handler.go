@@ -1,62 +1,80 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
 	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
 	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
 )
 
+// easyPage is a response of easyHandler, rendered as HTML or JSON.
+type easyPage struct {
+	status  response.StatusCode
+	heading string
+	message string
+}
+
+// easyOffers are the media types easyHandler can respond with, HTML first for browsers.
+var easyOffers = []string{"text/html", "application/json"}
+
 func easyHandler(w *response.Writer, req *request.Request) {
 	if req.RequestLine.RequestTarget == "/yourproblem" {
-		w.WriteStatusLine(response.StatusBadRequest)
-		body := []byte(`<html>
-  <head>
-    <title>400 Bad Request</title>
-  </head>
-  <body>
-    <h1>Bad Request</h1>
-    <p>Your request honestly kinda sucked.</p>
-  </body>
-</html>`)
-		writeDefaultEasyHandler(w, body)
+		writeEasyPage(w, req, easyPage{response.StatusBadRequest, "Bad Request", "Your request honestly kinda sucked."})
 		return
 	}
 
 	if req.RequestLine.RequestTarget == "/myproblem" {
-		w.WriteStatusLine(response.StatusInternalServerError)
-		body := []byte(`<html>
-  <head>
-    <title>500 Internal Server Error</title>
-  </head>
-  <body>
-    <h1>Internal Server Error</h1>
-    <p>Okay, you know what? This one is on me.</p>
-  </body>
-</html>`)
-		writeDefaultEasyHandler(w, body)
+		writeEasyPage(w, req, easyPage{response.StatusInternalServerError, "Internal Server Error", "Okay, you know what? This one is on me."})
+		return
+	}
+
+	writeEasyPage(w, req, easyPage{response.StatusOK, "Success!", "Your request was an absolute banger."})
+}
+
+// writeEasyPage writes the page in the media type the client prefers, or a 406.
+func writeEasyPage(w *response.Writer, req *request.Request, page easyPage) {
+	w.SetHeader("Vary", "Accept")
+	contentType, ok := req.NegotiateType(easyOffers...)
+	if !ok {
+		writeNotAcceptable(w, easyOffers)
 		return
 	}
 
-	w.WriteStatusLine(response.StatusOK)
-	body := []byte(`<html>
+	var body []byte
+	if contentType == "application/json" {
+		body, _ = json.Marshal(map[string]any{"status": page.status, "message": page.message})
+	} else {
+		body = fmt.Appendf(nil, `<html>
   <head>
-    <title>200 OK</title>
+    <title>%d %s</title>
   </head>
   <body>
-    <h1>Success!</h1>
-    <p>Your request was an absolute banger.</p>
+    <h1>%s</h1>
+    <p>%s</p>
   </body>
-</html>`)
-	writeDefaultEasyHandler(w, body)
+</html>`, page.status, response.StatusText(page.status), page.heading, page.message)
+	}
+	w.WriteStatusLine(page.status)
+	writeDefaultEasyHandler(w, contentType, body)
 }
 
-func writeDefaultEasyHandler(w *response.Writer, body []byte) {
+func writeDefaultEasyHandler(w *response.Writer, contentType string, body []byte) {
 	headers := headers.NewHeaders()
 	headers.Set("Content-Length", fmt.Sprintf("%d", len(body)))
 	headers.Set("Connection", "close")
-	headers.Set("Content-Type", "text/html")
+	headers.Set("Content-Type", contentType)
 	w.WriteHeaders(headers)
 	w.WriteBody(body)
 }
+
+// writeNotAcceptable responds 406 with the available media types, in plain
+// text since the client accepts none of them, based on RFC 9110 Section 15.5.7.
+func writeNotAcceptable(w *response.Writer, offers []string) {
+	body := []byte("Not acceptable, available: " + strings.Join(offers, ", "))
+	w.WriteStatusLine(response.StatusNotAcceptable)
+	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	w.WriteBody(body)
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"log"
 	"net/http"
@@ -22,7 +24,7 @@ func httpbinHandler(w *response.Writer, req *request.Request) {
 	// Parse original request target
 	origTarget := req.RequestLine.RequestTarget
 	if !strings.HasPrefix(origTarget, "/httpbin/") {
-		httpbinHandlerError(w, response.StatusBadRequest, "Invalid request target")
+		httpbinHandlerError(w, req, response.StatusBadRequest, "Invalid request target")
 		return
 	}
 
@@ -30,7 +32,7 @@ func httpbinHandler(w *response.Writer, req *request.Request) {
 	url := httpbinBase + strings.TrimPrefix(origTarget, "/httpbin/")
 	resp, err := http.Get(url)
 	if err != nil {
-		httpbinHandlerError(w, response.StatusInternalServerError, fmt.Sprintf("Failed to fetch %s: %v", url, err))
+		httpbinHandlerError(w, req, response.StatusInternalServerError, fmt.Sprintf("Failed to fetch %s: %v", url, err))
 		return
 	}
 	defer resp.Body.Close()
@@ -73,9 +75,27 @@ func httpbinHandler(w *response.Writer, req *request.Request) {
 	w.WriteTrailer(t)
 }
 
-func httpbinHandlerError(w *response.Writer, statusCode response.StatusCode, message string) {
+// errorOffers are the media types of error responses, plain text first for curl.
+var errorOffers = []string{"text/plain", "application/json", "text/html"}
+
+func httpbinHandlerError(w *response.Writer, req *request.Request, statusCode response.StatusCode, message string) {
+	w.SetHeader("Vary", "Accept")
+	contentType, ok := req.NegotiateType(errorOffers...)
+	if !ok {
+		writeNotAcceptable(w, errorOffers)
+		return
+	}
+
+	var body []byte
+	switch contentType {
+	case "application/json":
+		body, _ = json.Marshal(map[string]any{"status": statusCode, "error": message})
+	case "text/html":
+		body = fmt.Appendf(nil, "<html>\n  <body>\n    <h1>%d %s</h1>\n    <p>%s</p>\n  </body>\n</html>",
+			statusCode, response.StatusText(statusCode), html.EscapeString(message))
+	default:
+		body = []byte(message)
+	}
 	w.WriteStatusLine(statusCode)
-	body := []byte(message)
-	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
-	w.WriteBody(body)
+	writeDefaultEasyHandler(w, contentType, body)
 }
@@ -1,26 +1,135 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/auth"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/cache"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/metrics"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/ratelimit"
 	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/server"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/tunnel"
 )
 
-const port = 42069
+// authRealm is the protection space of /private/, users of htdigest files must belong to it.
+const authRealm = "httpserver"
 
 func main() {
-	server, err := server.Serve(port, videoHandler)
+	addr := flag.String("addr", ":42069", `bind address, e.g., ":42069" or "unix:/tmp/httpserver.sock"`)
+	cacheSize := flag.Int64("cache-size", 64, "memory cap of the /cached/ proxy in MiB")
+	cacheDir := flag.String("cache-dir", "", "also store /cached/ responses in this directory")
+	htpasswd := flag.String("htpasswd", "", "protect /private/basic with the bcrypt users of this htpasswd file")
+	htdigest := flag.String("htdigest", "", "protect /private/digest with the users of this htdigest file")
+	digestAlgorithm := flag.String("htdigest-algorithm", "MD5", `algorithm of the htdigest file, "MD5" or "SHA-256"`)
+	tunnelEnabled := flag.Bool("tunnel", false, "accept CONNECT, which makes the server a proxy for anyone who can reach it")
+	tunnelPorts := flag.String("tunnel-ports", "443", "comma-separated destination ports allowed for CONNECT, with -tunnel")
+	logFormat := flag.String("log-format", "text", `format of the server logs, "text" or "json"`)
+	logLevel := flag.String("log-level", "info", `minimum level of the server logs, "debug", "info", "warn", or "error"`)
+	flag.Parse()
+
+	logger, err := server.NewLogger(os.Stderr, *logFormat, *logLevel)
+	if err != nil {
+		log.Fatalf("Error configuring logs: %v", err)
+	}
+
+	// Prefer a socket passed by a supervisor (systemd socket activation)
+	listeners, err := server.InheritedListeners()
+	if err != nil {
+		log.Fatalf("Error inheriting listeners: %v", err)
+	}
+	var l net.Listener
+	if len(listeners) > 0 {
+		l = listeners[0]
+	} else if l, err = server.Listen(*addr); err != nil {
+		log.Fatalf("Error listening on %s: %v", *addr, err)
+	}
+
+	router := server.NewRouter()
+	handle := func(method, pattern string, handler server.Handler) {
+		if err := router.Handle(method, pattern, handler); err != nil {
+			log.Fatalf("Error routing %s %s: %v", method, pattern, err)
+		}
+	}
+	handle("GET", "/video", videoHandler)
+	// Each request to httpbin hits the upstream, so keep clients from hammering it
+	limiter := ratelimit.New(ratelimit.Options{Rate: 1, Burst: 5})
+	handle("GET", "/httpbin/", limiter.Wrap(httpbinHandler))
+	// Same upstream, but answered from a cache when the responses allow it
+	proxy, err := cache.New(cache.Options{
+		Upstream: httpbinBase,
+		Prefix:   "/cached/",
+		MaxBytes: *cacheSize << 20,
+		Dir:      *cacheDir,
+		Logger:   logger,
+	})
+	if err != nil {
+		log.Fatalf("Error creating cache: %v", err)
+	}
+	handle("GET", "/cached/", proxy.Serve)
+	if *htpasswd != "" {
+		users, err := auth.LoadHtpasswd(*htpasswd)
+		if err != nil {
+			log.Fatalf("Error loading htpasswd: %v", err)
+		}
+		handle("GET", "/private/basic", auth.NewBasic(authRealm, users).Wrap(easyHandler))
+	}
+	if *htdigest != "" {
+		users, err := auth.LoadHtdigest(*htdigest, authRealm)
+		if err != nil {
+			log.Fatalf("Error loading htdigest: %v", err)
+		}
+		digest, err := auth.NewDigest(auth.DigestOptions{Realm: authRealm, Users: users, Algorithm: *digestAlgorithm})
+		if err != nil {
+			log.Fatalf("Error creating digest auth: %v", err)
+		}
+		handle("GET", "/private/digest", digest.Wrap(easyHandler))
+	}
+	handle("GET", "/", easyHandler)
+
+	// CONNECT targets are not paths, so tunnels are handled before routing
+	var handler server.Handler = router.Serve
+	if *tunnelEnabled {
+		ports, err := parsePorts(*tunnelPorts)
+		if err != nil {
+			log.Fatalf("Error parsing tunnel ports: %v", err)
+		}
+		handler = tunnel.New(tunnel.Options{AllowedPorts: ports, Logger: logger}).Wrap(handler)
+	}
+
+	server, err := server.ServeListener(l, handler, server.WithConnLimits(server.ConnLimits{
+		MaxConns:      100,
+		MaxQueued:     100,
+		MaxConnsPerIP: 10,
+	}), server.WithMetrics(metrics.New(), "/metrics"), server.WithH2C(), server.WithLogger(logger))
 	if err != nil {
 		log.Fatalf("Error starting server: %v", err)
 	}
 	defer server.Close()
-	log.Println("Server started on port", port)
+	log.Println("Server started on", server.Addr())
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
 	log.Println("Server gracefully stopped")
 }
+
+// parsePorts parses a comma-separated list of ports.
+func parsePorts(s string) ([]int, error) {
+	ports := make([]int, 0)
+	for field := range strings.SplitSeq(s, ",") {
+		port, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port: %q", field)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/client"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+)
+
+// replay sends raw HTTP requests from files (or stdin) to a server, optionally
+// split into small delayed writes, to reproduce partial-read edge cases of the
+// parser against a live server, then prints the parsed response.
+func main() {
+	addr := flag.String("addr", "localhost:42069", "TCP address of the server")
+	fragment := flag.Int("fragment", 0, "bytes per write, 0 sends each request in a single write")
+	delay := flag.Duration("delay", 0, "pause between fragments, e.g., 100ms")
+	crlf := flag.Bool("crlf", false, "convert bare LF line endings of the request head to CRLF")
+	timeout := flag.Duration("timeout", 10*time.Second, "deadline for each request and response")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [request-file ...]\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Reads a single request from stdin if no file, or '-', is given.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	failed := false
+	for _, path := range paths {
+		raw, err := readRequest(path)
+		if err != nil {
+			log.Printf("Error reading %s: %v", path, err)
+			failed = true
+			continue
+		}
+		if *crlf {
+			raw = normalizeHead(raw)
+		}
+		fmt.Printf("=== %s (%d bytes)\n", path, len(raw))
+		if err := replay(*addr, raw, *fragment, *delay, *timeout); err != nil {
+			log.Printf("Error replaying %s: %v", path, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func readRequest(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// replay sends the raw request over a new connection, and prints the response.
+func replay(addr string, raw []byte, fragment int, delay, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout + time.Duration(len(raw))*delay))
+
+	if fragment <= 0 {
+		fragment = len(raw)
+	}
+	for chunk := range slices.Chunk(raw, max(fragment, 1)) {
+		if _, err := conn.Write(chunk); err != nil {
+			return fmt.Errorf("sending request: %w", err)
+		}
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+	}
+
+	resp, err := client.ReadResponse(bufio.NewReader(conn), requestMethod(raw))
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+	printResponse(resp)
+	return nil
+}
+
+// requestMethod returns the method of the raw request, i.e., its first word.
+func requestMethod(raw []byte) string {
+	method, _, _ := bytes.Cut(raw, []byte(" "))
+	return string(method)
+}
+
+// normalizeHead converts bare LF to CRLF up to the first empty line, leaving
+// the body untouched, since request files are easier to write with LF only.
+func normalizeHead(raw []byte) []byte {
+	var out bytes.Buffer
+	rest := raw
+	for len(rest) > 0 {
+		line, after, found := bytes.Cut(rest, []byte("\n"))
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		out.Write(line)
+		if !found {
+			break
+		}
+		out.WriteString("\r\n")
+		rest = after
+		if len(line) == 0 {
+			out.Write(rest) // end of head, keep the body as is
+			break
+		}
+	}
+	return out.Bytes()
+}
+
+func printResponse(resp *client.Response) {
+	for _, in := range resp.Interim {
+		fmt.Println("Interim:", in.StatusLine.StatusCode, in.StatusLine.ReasonPhrase)
+		printHeaders(in.Headers)
+	}
+	sl := resp.StatusLine
+	fmt.Println("Status line:")
+	fmt.Println("- Version:", sl.HTTPVersion)
+	fmt.Println("- Status:", sl.StatusCode, sl.ReasonPhrase)
+	fmt.Println("Headers:")
+	printHeaders(resp.Headers)
+	fmt.Printf("Body (%d bytes):\n", len(resp.Body))
+	if utf8.Valid(resp.Body) {
+		fmt.Println(string(resp.Body))
+	} else {
+		fmt.Print(hex.Dump(resp.Body))
+	}
+	if len(resp.Trailers) > 0 {
+		fmt.Println("Trailers:")
+		printHeaders(resp.Trailers)
+	}
+	if _, found := resp.Trailers.Get(headers.ContentSHA256Trailer); found {
+		if err := resp.VerifyContentSHA256(); err != nil {
+			fmt.Println("Integrity: FAILED,", err)
+		} else {
+			fmt.Println("Integrity: OK")
+		}
+	}
+	fmt.Println()
+}
+
+func printHeaders(h map[string]string) {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		fmt.Printf("- %s: %s\n", key, h[key])
+	}
+}
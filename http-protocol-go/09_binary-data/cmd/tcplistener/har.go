@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+)
+
+// harRecorder records inspections to a file in the HTTP Archive format 1.2,
+// see http://www.softwareishard.com/blog/har-12-spec/. Each entry is written
+// over the closing brackets at the end of the file, followed by them again,
+// so the file is valid JSON after every record without being rewritten.
+type harRecorder struct {
+	file    *os.File
+	end     int64 // offset of the closing brackets, where the next entry goes
+	entries int
+}
+
+// harClosing ends the entries array, the log, and the file.
+const harClosing = "\n]}}\n"
+
+// harFile is the whole HAR file, as written piecewise by harRecorder.
+type harFile struct {
+	Log *harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"` // non-standard, but understood by most viewers
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// newHARRecorder creates the HAR file, replacing any existing one, with an
+// empty log.
+func newHARRecorder(path string) (*harRecorder, error) {
+	creator, err := json.Marshal(harCreator{Name: "tcplistener", Version: "1.0"})
+	if err != nil {
+		return nil, err
+	}
+	head := fmt.Sprintf(`{"log":{"version":"1.2","creator":%s,"entries":[`, creator)
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.WriteString(head + harClosing); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &harRecorder{file: file, end: int64(len(head))}, nil
+}
+
+// Record appends the inspection as an entry to the HAR file, writing only the
+// entry. It is not safe for concurrent use.
+func (h *harRecorder) Record(insp *inspection) error {
+	data, err := json.Marshal(newHAREntry(insp))
+	if err != nil {
+		return err
+	}
+	sep := "\n"
+	if h.entries > 0 {
+		sep = ",\n"
+	}
+	entry := sep + string(data)
+	if _, err := h.file.WriteAt([]byte(entry+harClosing), h.end); err != nil {
+		return err
+	}
+	h.end += int64(len(entry))
+	h.entries++
+	return nil
+}
+
+// Close closes the HAR file, which is complete after the last record.
+func (h *harRecorder) Close() error {
+	return h.file.Close()
+}
+
+func newHAREntry(insp *inspection) harEntry {
+	ms := float64(insp.Duration.Microseconds()) / 1000
+	entry := harEntry{
+		StartedDateTime: insp.Start.Format("2006-01-02T15:04:05.000Z07:00"),
+		Time:            ms,
+		Request: harRequest{
+			Cookies:     []harNameValue{},
+			Headers:     []harNameValue{},
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(insp.Raw),
+		},
+		Response: harResponse{
+			Status:      int(insp.Status),
+			StatusText:  response.StatusText(insp.Status),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     []harNameValue{},
+			Content: harContent{
+				Size:     len(insp.Response),
+				MimeType: "text/plain",
+				Text:     string(insp.Response),
+			},
+			HeadersSize: -1,
+			BodySize:    len(insp.Response),
+		},
+		Timings: harTimings{Send: 0, Wait: ms, Receive: 0},
+	}
+	respHeaders := response.GetDefaultHeaders(len(insp.Response))
+	for _, key := range sortedKeys(respHeaders) {
+		entry.Response.Headers = append(entry.Response.Headers, harNameValue{Name: key, Value: respHeaders[key]})
+	}
+
+	if insp.Err != nil {
+		// Keep what we can, the raw bytes are in the comment for debugging
+		entry.Request.Method = "UNKNOWN"
+		entry.Request.URL = "http://unknown/"
+		entry.Request.HTTPVersion = "unknown"
+		entry.Comment = "parse error: " + insp.Err.Error() + "; raw (base64): " +
+			base64.StdEncoding.EncodeToString(insp.Raw)
+		return entry
+	}
+
+	req := insp.Request
+	host, _ := req.Headers.Get("host")
+	if host == "" {
+		host = "unknown"
+	}
+	target := req.RequestLine.RequestTarget
+	if !strings.HasPrefix(target, "/") {
+		target = "/" // asterisk-form and others cannot be part of a URL
+	}
+	entry.Request.Method = req.RequestLine.Method
+	entry.Request.URL = "http://" + host + target
+	entry.Request.HTTPVersion = "HTTP/" + req.RequestLine.HTTPVersion
+	entry.Request.BodySize = len(req.Body)
+	entry.Request.HeadersSize = len(insp.Raw) - len(req.Body)
+	for _, key := range sortedKeys(req.Headers) {
+		entry.Request.Headers = append(entry.Request.Headers, harNameValue{Name: key, Value: req.Headers[key]})
+	}
+	cookies, _ := req.Cookies() // keep the valid ones, even if some are malformed
+	for _, c := range cookies {
+		entry.Request.Cookies = append(entry.Request.Cookies, harNameValue{Name: c.Name, Value: c.Value})
+	}
+	if _, rawQuery, found := strings.Cut(target, "?"); found {
+		if query, err := url.ParseQuery(rawQuery); err == nil {
+			for key, values := range query {
+				for _, value := range values {
+					entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{Name: key, Value: value})
+				}
+			}
+		}
+	}
+	if len(req.Body) > 0 {
+		mimeType, _ := req.Headers.Get("content-type")
+		entry.Request.PostData = &harPostData{MimeType: mimeType, Text: string(req.Body)}
+		if !isText(req.Body) {
+			entry.Request.PostData.Text = base64.StdEncoding.EncodeToString(req.Body)
+			entry.Request.PostData.Encoding = "base64"
+		}
+	}
+	return entry
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inspectRaw builds the inspection of a connection that sent the raw bytes.
+func inspectRaw(raw string) *inspection {
+	req, err := request.RequestFromReader(strings.NewReader(raw))
+	insp := &inspection{
+		Start:    time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Duration: 1500 * time.Microsecond,
+		Request:  req,
+		Err:      err,
+		Raw:      []byte(raw),
+		Status:   response.StatusOK,
+		Response: []byte("ok\n"),
+	}
+	if err != nil {
+		insp.Request = nil
+		insp.Status = response.StatusBadRequest
+	}
+	return insp
+}
+
+func TestNewHAREntry(t *testing.T) {
+	binary := "\x00\x01\xff\xfe"
+	cases := []struct {
+		name     string
+		raw      string
+		method   string
+		url      string
+		postData *harPostData
+		query    []harNameValue
+		cookies  []harNameValue
+		comment  string
+		json     []string // substrings of the entry encoded as JSON
+	}{
+		{
+			name:    "GET with query and cookies",
+			raw:     "GET /search?q=go&q=http HTTP/1.1\r\nHost: localhost\r\nCookie: a=1; b=2\r\n\r\n",
+			method:  "GET",
+			url:     "http://localhost/search?q=go&q=http",
+			query:   []harNameValue{{"q", "go"}, {"q", "http"}},
+			cookies: []harNameValue{{"a", "1"}, {"b", "2"}},
+			json:    []string{`"startedDateTime":"2025-01-02T03:04:05.000Z"`, `"time":1.5`, `"httpVersion":"HTTP/1.1"`},
+		},
+		{
+			name:     "Text body is kept as is",
+			raw:      "POST /form HTTP/1.1\r\nHost: localhost\r\nContent-Type: text/plain\r\nContent-Length: 5\r\n\r\nhello",
+			method:   "POST",
+			url:      "http://localhost/form",
+			postData: &harPostData{MimeType: "text/plain", Text: "hello"},
+			json:     []string{`"postData":{"mimeType":"text/plain","text":"hello"}`, `"bodySize":5`},
+		},
+		{
+			name:     "Binary body is base64",
+			raw:      "POST /upload HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/octet-stream\r\nContent-Length: 4\r\n\r\n" + binary,
+			method:   "POST",
+			url:      "http://localhost/upload",
+			postData: &harPostData{MimeType: "application/octet-stream", Text: "AAH//g==", Encoding: "base64"},
+			json:     []string{`"text":"AAH//g==","encoding":"base64"`},
+		},
+		{
+			name:   "Asterisk-form target",
+			raw:    "OPTIONS * HTTP/1.1\r\nHost: localhost\r\n\r\n",
+			method: "OPTIONS",
+			url:    "http://localhost/",
+		},
+		{
+			name:    "Parse error keeps the raw bytes",
+			raw:     "GET /\r\n\r\n",
+			method:  "UNKNOWN",
+			url:     "http://unknown/",
+			comment: "; raw (base64): " + base64.StdEncoding.EncodeToString([]byte("GET /\r\n\r\n")),
+			json:    []string{`"status":400`, `"statusText":"Bad Request"`},
+		},
+	}
+	for _, c := range cases {
+		// Test: Request is described as far as it was parsed
+		entry := newHAREntry(inspectRaw(c.raw))
+		assert.Equal(t, c.method, entry.Request.Method, c.name)
+		assert.Equal(t, c.url, entry.Request.URL, c.name)
+		assert.Equal(t, c.postData, entry.Request.PostData, c.name)
+		assert.ElementsMatch(t, c.query, entry.Request.QueryString, c.name)
+		assert.Equal(t, append([]harNameValue{}, c.cookies...), entry.Request.Cookies, c.name)
+		if c.comment == "" {
+			assert.Empty(t, entry.Comment, c.name)
+		} else {
+			assert.Contains(t, entry.Comment, "parse error: ", c.name)
+			assert.True(t, strings.HasSuffix(entry.Comment, c.comment), c.name)
+		}
+		assert.Equal(t, "ok\n", entry.Response.Content.Text, c.name)
+
+		// Test: Arrays are never null in JSON, as required by the spec
+		data, err := json.Marshal(entry)
+		require.NoError(t, err, c.name)
+		for _, key := range []string{"cookies", "headers", "queryString"} {
+			assert.NotContains(t, string(data), `"`+key+`":null`, c.name)
+		}
+		for _, want := range c.json {
+			assert.Contains(t, string(data), want, c.name)
+		}
+	}
+}
+
+func TestHARRecorder(t *testing.T) {
+	raws := []string{
+		"GET /first HTTP/1.1\r\nHost: localhost\r\n\r\n",
+		"POST /second HTTP/1.1\r\nHost: localhost\r\nContent-Length: 4\r\n\r\n\x00\x01\xff\xfe",
+		"GET /\r\n\r\n",
+	}
+	cases := []struct {
+		name    string
+		records int
+	}{
+		{"No records", 0},
+		{"One record", 1},
+		{"Many records", len(raws)},
+	}
+	for _, c := range cases {
+		// Test: File parses back after every record, with the entries in order
+		path := filepath.Join(t.TempDir(), "out.har")
+		har, err := newHARRecorder(path)
+		require.NoError(t, err, c.name)
+		for i := range c.records {
+			require.NoError(t, har.Record(inspectRaw(raws[i])), c.name)
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err, c.name)
+			var file harFile
+			require.NoError(t, json.Unmarshal(data, &file), c.name)
+			require.Len(t, file.Log.Entries, i+1, c.name)
+			assert.Equal(t, newHAREntry(inspectRaw(raws[i])), file.Log.Entries[i], c.name)
+		}
+		require.NoError(t, har.Close(), c.name)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err, c.name)
+		var file harFile
+		require.NoError(t, json.Unmarshal(data, &file), c.name)
+		assert.Equal(t, "1.2", file.Log.Version, c.name)
+		assert.Equal(t, "tcplistener", file.Log.Creator.Name, c.name)
+		assert.Len(t, file.Log.Entries, c.records, c.name)
+		if c.records > 0 {
+			assert.Equal(t, "http://localhost/first", file.Log.Entries[0].Request.URL, c.name)
+		}
+	}
+
+	// Test: Existing file is replaced
+	path := filepath.Join(t.TempDir(), "out.har")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("x", 1000)), 0o644))
+	har, err := newHARRecorder(path)
+	require.NoError(t, err)
+	require.NoError(t, har.Record(inspectRaw(raws[0])))
+	require.NoError(t, har.Close())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var file harFile
+	require.NoError(t, json.Unmarshal(data, &file))
+	assert.Len(t, file.Log.Entries, 1)
+}
@@ -1,48 +1,116 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
 )
 
+const readTimeout = 10 * time.Second // give up on clients that stall mid-request
+
 func main() {
-	const port = "42069"
+	addr := flag.String("addr", ":42069", "TCP address to listen on")
+	format := flag.String("format", "pretty", `output format, either "pretty" or "json"`)
+	harPath := flag.String("har", "", "record every request to this HAR file")
+	flag.Parse()
+
+	var printer func(*inspection)
+	switch *format {
+	case "pretty":
+		printer = printPretty
+	case "json":
+		printer = printJSON
+	default:
+		log.Fatalf("Unknown format %q, expected pretty or json", *format)
+	}
+	var har *harRecorder
+	var err error
+	if *harPath != "" {
+		har, err = newHARRecorder(*harPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer har.Close()
+	}
 
 	// Listen a TCP connection
-	listener, err := net.Listen("tcp", ":"+port)
+	listener, err := net.Listen("tcp", *addr)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer listener.Close()
 
-	fmt.Printf("Listening for TCP traffic on port %s...\n", port)
+	fmt.Fprintf(os.Stderr, "Listening for TCP traffic on %s...\n", listener.Addr())
+	var mu sync.Mutex // keep output of concurrent connections from interleaving
 	for {
-		// Wait for a connection
+		// Wait for a connection, a failed accept should not kill the listener
 		conn, err := listener.Accept()
 		if err != nil {
-			log.Fatal(err)
+			log.Println("Error accepting connection:", err)
+			continue
 		}
-		fmt.Println("Connection has been accepted")
+		go func() {
+			insp := inspect(conn)
+			mu.Lock()
+			defer mu.Unlock()
+			printer(insp)
+			if har != nil {
+				if err := har.Record(insp); err != nil {
+					log.Println("Error recording HAR:", err)
+				}
+			}
+		}()
+	}
+}
 
-		// Read the connection
-		req, err := request.RequestFromReader(conn)
-		if err != nil {
-			log.Fatal(err)
-		}
-		fmt.Println("Request line:")
-		fmt.Println("- Method:", req.RequestLine.Method)
-		fmt.Println("- Target:", req.RequestLine.RequestTarget)
-		fmt.Println("- Version:", req.RequestLine.HTTPVersion)
-		fmt.Println("Headers:")
-		for key, value := range req.Headers {
-			fmt.Printf("- %s: %s\n", key, value)
-		}
-		fmt.Println("Body:")
-		fmt.Println(string(req.Body))
+// inspection is everything we learned from a single connection.
+type inspection struct {
+	Start      time.Time
+	Duration   time.Duration
+	RemoteAddr string
+	Request    *request.Request // nil if the request could not be parsed
+	Err        error
+	Raw        []byte // bytes read from the connection, as sent by the client
+	Status     response.StatusCode
+	Response   []byte // body of our response
+}
 
-		fmt.Println("Connection has been closed")
+// inspect reads a request from the connection, answers it, and closes it.
+func inspect(conn net.Conn) *inspection {
+	defer conn.Close()
+	insp := &inspection{
+		Start:      time.Now(),
+		RemoteAddr: conn.RemoteAddr().String(),
 	}
+	conn.SetReadDeadline(insp.Start.Add(readTimeout))
+
+	// Keep a copy of what was read, so unparseable requests can still be shown
+	var raw bytes.Buffer
+	req, err := request.RequestFromReader(io.TeeReader(conn, &raw))
+	insp.Raw = raw.Bytes()
+	insp.Request, insp.Err = req, err
+
+	// Answer so clients like curl do not hang waiting for a response
+	insp.Status = response.StatusOK
+	insp.Response = []byte("Request received and inspected\n")
+	if err != nil {
+		insp.Status = response.StatusBadRequest
+		insp.Response = fmt.Appendf(nil, "Error parsing request: %v\n", err)
+	}
+	w := response.NewWriter(conn)
+	w.WriteStatusLine(insp.Status)
+	w.WriteHeaders(response.GetDefaultHeaders(len(insp.Response)))
+	w.WriteBody(insp.Response)
+
+	insp.Duration = time.Since(insp.Start)
+	return insp
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// printPretty prints the inspection in a human-friendly layout.
+func printPretty(insp *inspection) {
+	fmt.Printf("=== %s from %s (%v)\n", insp.Start.Format("15:04:05.000"), insp.RemoteAddr, insp.Duration)
+	if insp.Err != nil {
+		fmt.Println("Error:", insp.Err)
+		fmt.Printf("Raw (%d bytes):\n", len(insp.Raw))
+		fmt.Print(hex.Dump(insp.Raw))
+		fmt.Println()
+		return
+	}
+
+	req := insp.Request
+	fmt.Println("Request line:")
+	fmt.Println("- Method:", req.RequestLine.Method)
+	fmt.Println("- Target:", req.RequestLine.RequestTarget)
+	fmt.Println("- Version:", req.RequestLine.HTTPVersion)
+	fmt.Println("Headers:")
+	for _, key := range sortedKeys(req.Headers) {
+		fmt.Printf("- %s: %s\n", key, req.Headers[key])
+	}
+	fmt.Printf("Body (%d bytes):\n", len(req.Body))
+	if isText(req.Body) {
+		fmt.Println(string(req.Body))
+	} else {
+		fmt.Print(hex.Dump(req.Body)) // binary payloads would garble the terminal
+	}
+	fmt.Println()
+}
+
+// jsonInspection is the JSON representation of an inspection, one per line.
+type jsonInspection struct {
+	Time       string            `json:"time"`
+	DurationMs float64           `json:"duration_ms"`
+	RemoteAddr string            `json:"remote_addr"`
+	Error      string            `json:"error,omitempty"`
+	Method     string            `json:"method,omitempty"`
+	Target     string            `json:"target,omitempty"`
+	Version    string            `json:"version,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	BodyBase64 string            `json:"body_base64,omitempty"` // set instead of body for binary payloads
+	RawBase64  string            `json:"raw_base64,omitempty"`  // set when the request could not be parsed
+}
+
+// printJSON prints the inspection as a single line of JSON.
+func printJSON(insp *inspection) {
+	out := jsonInspection{
+		Time:       insp.Start.Format("2006-01-02T15:04:05.000Z07:00"),
+		DurationMs: float64(insp.Duration.Microseconds()) / 1000,
+		RemoteAddr: insp.RemoteAddr,
+	}
+	if insp.Err != nil {
+		out.Error = insp.Err.Error()
+		out.RawBase64 = base64.StdEncoding.EncodeToString(insp.Raw)
+	} else {
+		req := insp.Request
+		out.Method = req.RequestLine.Method
+		out.Target = req.RequestLine.RequestTarget
+		out.Version = req.RequestLine.HTTPVersion
+		out.Headers = req.Headers
+		if isText(req.Body) {
+			out.Body = string(req.Body)
+		} else {
+			out.BodyBase64 = base64.StdEncoding.EncodeToString(req.Body)
+		}
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(out); err != nil {
+		fmt.Fprintln(os.Stderr, "Error encoding JSON:", err)
+	}
+}
+
+// isText reports whether the data is valid UTF-8 without control characters
+// other than whitespace, i.e., safe to print as is.
+func isText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if unicode.IsControl(r) && !strings.ContainsRune("\t\r\n", r) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -2,7 +2,10 @@ module github.com/akhdanfadh/bootdev-courses/http-protocol-go
 
 go 1.24.6
 
-require github.com/stretchr/testify v1.10.0
+require (
+	github.com/stretchr/testify v1.10.0
+	golang.org/x/crypto v0.41.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/server"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Basic is a middleware requiring the Basic authentication scheme, based on RFC 7617.
+type Basic struct {
+	realm string
+	users map[string][]byte // user -> bcrypt hash
+}
+
+// dummyHash is compared against for unknown users, so they take as long as known ones.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	return hash
+})
+
+// NewBasic returns a middleware accepting the users, e.g., as loaded by LoadHtpasswd.
+func NewBasic(realm string, users map[string][]byte) *Basic {
+	return &Basic{realm: realm, users: users}
+}
+
+// Wrap returns a handler that calls next with valid credentials, and
+// otherwise responds 401 with a Basic challenge.
+func (b *Basic) Wrap(next server.Handler) server.Handler {
+	return func(w *response.Writer, req *request.Request) {
+		if _, ok := b.Authenticate(req); !ok {
+			challenge := fmt.Sprintf(`Basic realm=%s, charset="UTF-8"`, quote(b.realm))
+			writeUnauthorized(w, challenge)
+			return
+		}
+		next(w, req)
+	}
+}
+
+// Authenticate returns the user whose credentials the request carries, if valid.
+func (b *Basic) Authenticate(req *request.Request) (string, bool) {
+	val, found := req.Headers.Get("authorization")
+	if !found {
+		return "", false
+	}
+	scheme, token, _ := strings.Cut(val, " ")
+	if !strings.EqualFold(scheme, "Basic") {
+		return "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(token))
+	if err != nil {
+		return "", false
+	}
+	// The user-id cannot contain a colon, but the password can, see Section 2
+	user, password, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return "", false
+	}
+	hash, known := b.users[user]
+	if !known {
+		hash = dummyHash()
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || !known {
+		return "", false
+	}
+	return user, true
+}
+
+// writeUnauthorized responds 401 with the challenge, based on RFC 9110 Section 11.6.1.
+func writeUnauthorized(w *response.Writer, challenge string) {
+	body := []byte("Unauthorized")
+	h := response.GetDefaultHeaders(len(body))
+	h.Set("WWW-Authenticate", challenge)
+	w.WriteStatusLine(response.StatusUnauthorized)
+	w.WriteHeaders(h)
+	w.WriteBody(body)
+}
+
+// quote returns the quoted-string of s, based on RFC 9110 Section 5.6.4.
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
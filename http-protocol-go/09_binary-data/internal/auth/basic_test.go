@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// okHandler responds with 200 and a fixed body.
+func okHandler(w *response.Writer, req *request.Request) {
+	body := []byte("ok")
+	w.WriteStatusLine(response.StatusOK)
+	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	w.WriteBody(body)
+}
+
+// serveString runs the handler against a raw request and returns the raw response.
+func serveString(t *testing.T, handler server.Handler, raw string) string {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader(raw))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	handler(response.NewWriter(&buf), req)
+	return buf.String()
+}
+
+func basicRequest(credentials string) string {
+	return "GET / HTTP/1.1\r\nHost: localhost\r\nAuthorization: Basic " +
+		base64.StdEncoding.EncodeToString([]byte(credentials)) + "\r\n\r\n"
+}
+
+func TestBasic(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("pa:ss"), bcrypt.MinCost)
+	require.NoError(t, err)
+	handler := NewBasic(`test "lab"`, map[string][]byte{"alice": hash}).Wrap(okHandler)
+
+	// Test: Missing credentials are challenged
+	resp := serveString(t, handler, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 401 Unauthorized\r\n"))
+	assert.Contains(t, resp, "www-authenticate: Basic realm=\"test \\\"lab\\\"\", charset=\"UTF-8\"\r\n")
+
+	// Test: Valid credentials, the password may contain a colon
+	resp = serveString(t, handler, basicRequest("alice:pa:ss"))
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 200 OK\r\n"))
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\nok"))
+
+	// Test: Wrong password, unknown user, other scheme, and malformed credentials
+	for _, raw := range []string{
+		basicRequest("alice:wrong"),
+		basicRequest("bob:pa:ss"),
+		basicRequest("alice"),
+		"GET / HTTP/1.1\r\nHost: localhost\r\nAuthorization: Bearer abc\r\n\r\n",
+		"GET / HTTP/1.1\r\nHost: localhost\r\nAuthorization: Basic !!!\r\n\r\n",
+	} {
+		resp = serveString(t, handler, raw)
+		assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 401 Unauthorized\r\n"), raw)
+	}
+}
@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/server"
+)
+
+// DigestOptions configures the Digest middleware.
+type DigestOptions struct {
+	Realm string
+	// Users maps each user to the hex HA1 of the algorithm, i.e., the digest of
+	// "user:realm:password", e.g., as loaded by LoadHtdigest or from DigestHA1.
+	Users map[string]string
+	// Algorithm is "SHA-256" or "MD5", defaults to "SHA-256". Apache htdigest
+	// files hold MD5 digests.
+	Algorithm string
+	// NonceTTL is how long a nonce is accepted, defaults to 5 minutes. Clients
+	// with an expired nonce are challenged again with stale=true.
+	NonceTTL time.Duration
+}
+
+// Digest is a middleware requiring the Digest authentication scheme with
+// qop=auth, based on RFC 7616. Nonces are stateless, an HMAC of their time,
+// but their nonce counts are tracked to reject replayed requests.
+type Digest struct {
+	opts   DigestOptions
+	hash   func() hash.Hash
+	key    []byte // signs nonces
+	opaque string
+
+	mu        sync.Mutex
+	counts    map[string]uint32 // nonce -> highest nonce count seen
+	lastSweep time.Time
+	now       func() time.Time // replaceable clock for tests
+}
+
+const defaultNonceTTL = 5 * time.Minute
+
+var (
+	errInvalidCredentials = errors.New("invalid credentials")
+	errStaleNonce         = errors.New("stale nonce")
+)
+
+// NewDigest returns a middleware accepting the users, or an error if the
+// algorithm is unsupported or an HA1 is not a digest of it.
+func NewDigest(opts DigestOptions) (*Digest, error) {
+	if opts.Algorithm == "" {
+		opts.Algorithm = "SHA-256"
+	}
+	h, err := algorithmHash(opts.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[string]string, len(opts.Users))
+	for user, ha1 := range opts.Users {
+		if b, err := hex.DecodeString(ha1); err != nil || len(b) != h().Size() {
+			return nil, fmt.Errorf("HA1 of %s is not a hex %s digest", user, opts.Algorithm)
+		}
+		users[user] = strings.ToLower(ha1) // digested as lowercase hex
+	}
+	opts.Users = users
+	if opts.NonceTTL <= 0 {
+		opts.NonceTTL = defaultNonceTTL
+	}
+	opaque := make([]byte, 24)
+	rand.Read(opaque)
+	return &Digest{
+		opts:   opts,
+		hash:   h,
+		key:    []byte(rand.Text()),
+		opaque: base64.RawURLEncoding.EncodeToString(opaque),
+		counts: make(map[string]uint32),
+		now:    time.Now,
+	}, nil
+}
+
+// DigestHA1 returns the hex HA1 of the credentials, based on RFC 7616 Section 3.4.2.
+func DigestHA1(algorithm, user, realm, password string) (string, error) {
+	h, err := algorithmHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	return hexDigest(h, user+":"+realm+":"+password), nil
+}
+
+func algorithmHash(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case "SHA-256":
+		return sha256.New, nil
+	case "MD5":
+		return md5.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm: %s", algorithm)
+	}
+}
+
+// Wrap returns a handler that calls next with valid credentials, and
+// otherwise responds 401 with a Digest challenge.
+func (d *Digest) Wrap(next server.Handler) server.Handler {
+	return func(w *response.Writer, req *request.Request) {
+		_, err := d.authenticate(req)
+		if err != nil {
+			writeUnauthorized(w, d.challenge(errors.Is(err, errStaleNonce)))
+			return
+		}
+		next(w, req)
+	}
+}
+
+// Authenticate returns the user whose credentials the request carries, if valid.
+func (d *Digest) Authenticate(req *request.Request) (string, bool) {
+	user, err := d.authenticate(req)
+	return user, err == nil
+}
+
+// challenge returns the WWW-Authenticate value, based on RFC 7616 Section 3.3.
+func (d *Digest) challenge(stale bool) string {
+	c := fmt.Sprintf(`Digest realm=%s, qop="auth", algorithm=%s, nonce=%s, opaque=%s`,
+		quote(d.opts.Realm), d.opts.Algorithm, quote(d.newNonce()), quote(d.opaque))
+	if stale {
+		c += ", stale=true"
+	}
+	return c
+}
+
+// authenticate checks the Authorization header, based on RFC 7616 Section 3.4.
+func (d *Digest) authenticate(req *request.Request) (string, error) {
+	val, found := req.Headers.Get("authorization")
+	if !found {
+		return "", errInvalidCredentials
+	}
+	scheme, rest, _ := strings.Cut(val, " ")
+	if !strings.EqualFold(scheme, "Digest") {
+		return "", errInvalidCredentials
+	}
+	params, err := parseParams(rest)
+	if err != nil {
+		return "", err
+	}
+	algorithm := params["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5" // the default, see Section 3.4
+	}
+	if params["realm"] != d.opts.Realm || algorithm != d.opts.Algorithm || params["opaque"] != d.opaque ||
+		params["qop"] != "auth" || params["userhash"] == "true" || params["cnonce"] == "" {
+		return "", errInvalidCredentials
+	}
+	// The digested URI must be the one requested, see Section 3.4.6
+	if params["uri"] != req.RequestLine.RequestTarget {
+		return "", errInvalidCredentials
+	}
+	nc, err := strconv.ParseUint(params["nc"], 16, 32)
+	if err != nil || len(params["nc"]) != 8 {
+		return "", errInvalidCredentials
+	}
+	// Unknown users are digested too, so they take as long as known ones
+	ha1, known := d.opts.Users[params["username"]]
+	if !known {
+		ha1 = strings.Repeat("0", 2*d.hash().Size())
+	}
+
+	expected := digestResponse(d.hash, ha1, req.RequestLine.Method, params["uri"], params["nonce"], params["nc"], params["cnonce"])
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(params["response"]))) != 1 || !known {
+		return "", errInvalidCredentials
+	}
+	// Only a valid response tells the nonce is stale, so the client retries without asking the user
+	if err := d.useNonce(params["nonce"], uint32(nc)); err != nil {
+		return "", err
+	}
+	return params["username"], nil
+}
+
+// digestResponse returns the expected response with qop=auth, based on RFC 7616 Section 3.4.1.
+func digestResponse(h func() hash.Hash, ha1, method, uri, nonce, nc, cnonce string) string {
+	ha2 := hexDigest(h, method+":"+uri)
+	return hexDigest(h, strings.Join([]string{ha1, nonce, nc, cnonce, "auth", ha2}, ":"))
+}
+
+// newNonce returns a nonce made of its creation time and an HMAC of it.
+func (d *Digest) newNonce() string {
+	ts := binary.BigEndian.AppendUint64(nil, uint64(d.now().UnixNano()))
+	return base64.RawURLEncoding.EncodeToString(append(ts, d.sign(ts)...))
+}
+
+func (d *Digest) sign(ts []byte) []byte {
+	mac := hmac.New(sha256.New, d.key)
+	mac.Write(ts)
+	mac.Write([]byte(d.opts.Realm))
+	return mac.Sum(nil)[:16]
+}
+
+// useNonce checks that we issued the nonce, that it has not expired, and that
+// its count is higher than any seen before, based on RFC 7616 Section 5.4.
+func (d *Digest) useNonce(nonce string, nc uint32) error {
+	b, err := base64.RawURLEncoding.DecodeString(nonce)
+	if err != nil || len(b) != 8+16 || !hmac.Equal(b[8:], d.sign(b[:8])) {
+		return errInvalidCredentials
+	}
+	issued := time.Unix(0, int64(binary.BigEndian.Uint64(b[:8])))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	d.sweep(now)
+	if now.Sub(issued) >= d.opts.NonceTTL {
+		return errStaleNonce
+	}
+	if nc <= d.counts[nonce] {
+		return errInvalidCredentials // replayed
+	}
+	d.counts[nonce] = nc
+	return nil
+}
+
+// sweep forgets the counts of expired nonces, at most once per TTL. Caller must hold the lock.
+func (d *Digest) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.opts.NonceTTL {
+		return
+	}
+	for nonce := range d.counts {
+		b, _ := base64.RawURLEncoding.DecodeString(nonce)
+		if now.Sub(time.Unix(0, int64(binary.BigEndian.Uint64(b[:8])))) >= d.opts.NonceTTL {
+			delete(d.counts, nonce)
+		}
+	}
+	d.lastSweep = now
+}
+
+func hexDigest(h func() hash.Hash, s string) string {
+	sum := h()
+	sum.Write([]byte(s))
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// parseParams parses the comma-separated auth-params of a credentials, whose
+// values are tokens or quoted-strings, based on RFC 9110 Section 11.2.
+func parseParams(s string) (map[string]string, error) {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params, nil
+		}
+		name, rest, found := strings.Cut(s, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid auth-param: %s", s)
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		rest = strings.TrimLeft(rest, " \t")
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			if i == len(rest) {
+				return nil, fmt.Errorf("unterminated quoted-string in %s", name)
+			}
+			rest = rest[i+1:]
+		} else {
+			end := strings.IndexAny(rest, ", \t")
+			if end == -1 {
+				end = len(rest)
+			}
+			value.WriteString(rest[:end])
+			rest = rest[end:]
+		}
+		if _, dup := params[name]; dup {
+			return nil, fmt.Errorf("duplicate auth-param: %s", name)
+		}
+		params[name] = value.String()
+		s = rest
+	}
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced clock.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// challengeParams returns the auth-params of the Digest challenge in the raw response.
+func challengeParams(t *testing.T, resp string) map[string]string {
+	t.Helper()
+	for line := range strings.SplitSeq(resp, "\r\n") {
+		if val, found := strings.CutPrefix(line, "www-authenticate: Digest "); found {
+			params, err := parseParams(val)
+			require.NoError(t, err)
+			return params
+		}
+	}
+	require.FailNow(t, "missing Digest challenge", resp)
+	return nil
+}
+
+// digestRequest answers the challenge for the target with the password.
+func digestRequest(challenge map[string]string, target, user, password, nc string) string {
+	ha1, _ := DigestHA1("SHA-256", user, challenge["realm"], password)
+	cnonce := "0a4f113b"
+	response := digestResponse(sha256.New, ha1, "GET", target, challenge["nonce"], nc, cnonce)
+	return fmt.Sprintf("GET %s HTTP/1.1\r\nHost: localhost\r\nAuthorization: Digest username=%q, realm=%q, "+
+		"uri=%q, algorithm=SHA-256, nonce=%q, nc=%s, cnonce=%q, qop=auth, response=%q, opaque=%q\r\n\r\n",
+		target, user, challenge["realm"], target, challenge["nonce"], nc, cnonce, response, challenge["opaque"])
+}
+
+func TestDigestResponse(t *testing.T) {
+	// Test: Examples of RFC 7616 Section 3.9.1
+	nonce := "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v"
+	cnonce := "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+	ha1, err := DigestHA1("MD5", "Mufasa", "http-auth@example.org", "Circle of Life")
+	require.NoError(t, err)
+	assert.Equal(t, "8ca523f5e9506fed4657c9700eebdbec",
+		digestResponse(md5.New, ha1, "GET", "/dir/index.html", nonce, "00000001", cnonce))
+	ha1, err = DigestHA1("SHA-256", "Mufasa", "http-auth@example.org", "Circle of Life")
+	require.NoError(t, err)
+	assert.Equal(t, "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+		digestResponse(sha256.New, ha1, "GET", "/dir/index.html", nonce, "00000001", cnonce))
+}
+
+func TestDigest(t *testing.T) {
+	ha1, err := DigestHA1("SHA-256", "alice", "lab", "secret")
+	require.NoError(t, err)
+	d, err := NewDigest(DigestOptions{Realm: "lab", Users: map[string]string{"alice": ha1}, NonceTTL: time.Minute})
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Now()}
+	d.now = clock.Now
+	handler := d.Wrap(okHandler)
+
+	// Test: Missing credentials are challenged
+	resp := serveString(t, handler, "GET /private HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 401 Unauthorized\r\n"))
+	challenge := challengeParams(t, resp)
+	assert.Equal(t, "lab", challenge["realm"])
+	assert.Equal(t, "auth", challenge["qop"])
+	assert.Equal(t, "SHA-256", challenge["algorithm"])
+	assert.NotContains(t, challenge, "stale")
+
+	// Test: Valid response, then a higher nonce count
+	resp = serveString(t, handler, digestRequest(challenge, "/private", "alice", "secret", "00000001"))
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\nok"))
+	resp = serveString(t, handler, digestRequest(challenge, "/private", "alice", "secret", "00000002"))
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\nok"))
+
+	// Test: Replayed nonce count, wrong password, unknown user, and a URI other than the target
+	for _, raw := range []string{
+		digestRequest(challenge, "/private", "alice", "secret", "00000002"),
+		digestRequest(challenge, "/private", "alice", "wrong", "00000003"),
+		digestRequest(challenge, "/private", "bob", "secret", "00000003"),
+		strings.Replace(digestRequest(challenge, "/private", "alice", "secret", "00000003"), "GET /private", "GET /other", 1),
+	} {
+		resp = serveString(t, handler, raw)
+		assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 401 Unauthorized\r\n"), raw)
+		assert.NotContains(t, challengeParams(t, resp), "stale", raw)
+	}
+
+	// Test: Unknown user answering with the HA1 digested in its place
+	zeros := strings.Repeat("0", 64)
+	raw := digestRequest(challenge, "/private", "bob", "secret", "00000003")
+	ha1Bob, _ := DigestHA1("SHA-256", "bob", "lab", "secret")
+	raw = strings.Replace(raw,
+		digestResponse(sha256.New, ha1Bob, "GET", "/private", challenge["nonce"], "00000003", "0a4f113b"),
+		digestResponse(sha256.New, zeros, "GET", "/private", challenge["nonce"], "00000003", "0a4f113b"), 1)
+	resp = serveString(t, handler, raw)
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 401 Unauthorized\r\n"))
+
+	// Test: Forged nonce
+	forged := map[string]string{"realm": "lab", "nonce": "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", "opaque": challenge["opaque"]}
+	resp = serveString(t, handler, digestRequest(forged, "/private", "alice", "secret", "00000001"))
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 401 Unauthorized\r\n"))
+
+	// Test: Expired nonce with valid credentials is stale, and a new nonce works
+	clock.Advance(time.Minute)
+	resp = serveString(t, handler, digestRequest(challenge, "/private", "alice", "secret", "00000003"))
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 401 Unauthorized\r\n"))
+	challenge = challengeParams(t, resp)
+	assert.Equal(t, "true", challenge["stale"])
+	resp = serveString(t, handler, digestRequest(challenge, "/private", "alice", "secret", "00000001"))
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\nok"))
+}
+
+func TestNewDigest(t *testing.T) {
+	// Test: Unsupported algorithm, and HA1 of another algorithm
+	_, err := NewDigest(DigestOptions{Algorithm: "SHA-512-256"})
+	require.Error(t, err)
+	ha1, err := DigestHA1("MD5", "alice", "lab", "secret")
+	require.NoError(t, err)
+	_, err = NewDigest(DigestOptions{Realm: "lab", Users: map[string]string{"alice": ha1}})
+	require.Error(t, err)
+	_, err = NewDigest(DigestOptions{Realm: "lab", Users: map[string]string{"alice": ha1}, Algorithm: "MD5"})
+	require.NoError(t, err)
+}
+
+func TestParseParams(t *testing.T) {
+	// Test: Tokens and quoted-strings with escapes and commas
+	params, err := parseParams(`username="a\"b", realm="x, y",qop=auth , nc=00000001`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"username": `a"b`, "realm": "x, y", "qop": "auth", "nc": "00000001"}, params)
+
+	// Test: Malformed and duplicate params
+	for _, s := range []string{`realm`, `realm="x`, `qop=auth, qop=auth`} {
+		_, err := parseParams(s)
+		require.Error(t, err, s)
+	}
+}
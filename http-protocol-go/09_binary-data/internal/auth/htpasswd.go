@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ParseHtpasswd parses htpasswd-style lines of "user:hash", where the hash must
+// be bcrypt ("$2y$", as written by `htpasswd -B`, "$2a$", or "$2b$"). Blank
+// lines and lines starting with "#" are skipped.
+func ParseHtpasswd(r io.Reader) (map[string][]byte, error) {
+	users := make(map[string][]byte)
+	err := scanLines(r, func(n int, line string) error {
+		user, hash, found := strings.Cut(line, ":")
+		if !found || user == "" {
+			return fmt.Errorf("line %d: expected user:hash", n)
+		}
+		if !strings.HasPrefix(hash, "$2y$") && !strings.HasPrefix(hash, "$2a$") && !strings.HasPrefix(hash, "$2b$") {
+			return fmt.Errorf("line %d: only bcrypt hashes are supported", n)
+		}
+		users[user] = []byte(hash)
+		return nil
+	})
+	return users, err
+}
+
+// LoadHtpasswd parses the htpasswd file at path, see ParseHtpasswd.
+func LoadHtpasswd(path string) (map[string][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseHtpasswd(f)
+}
+
+// ParseHtdigest parses htdigest-style lines of "user:realm:HA1", keeping the
+// users of the realm, where HA1 is the hex digest of "user:realm:password".
+// Lines of other realms are skipped, so one file may serve several realms.
+func ParseHtdigest(r io.Reader, realm string) (map[string]string, error) {
+	users := make(map[string]string)
+	err := scanLines(r, func(n int, line string) error {
+		parts := strings.Split(line, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return fmt.Errorf("line %d: expected user:realm:HA1", n)
+		}
+		if _, err := hex.DecodeString(parts[2]); err != nil {
+			return fmt.Errorf("line %d: HA1 must be hexadecimal", n)
+		}
+		if parts[1] == realm {
+			users[parts[0]] = strings.ToLower(parts[2])
+		}
+		return nil
+	})
+	return users, err
+}
+
+// LoadHtdigest parses the htdigest file at path, see ParseHtdigest.
+func LoadHtdigest(path, realm string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseHtdigest(f, realm)
+}
+
+// scanLines calls fn with each line that is neither blank nor a comment.
+func scanLines(r io.Reader, fn func(n int, line string) error) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := fn(n, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHtpasswd(t *testing.T) {
+	// Test: bcrypt entries, with comments and blank lines
+	users, err := ParseHtpasswd(strings.NewReader("# users\n\nalice:$2y$05$abc\nbob:$2a$10$def\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"alice": []byte("$2y$05$abc"), "bob": []byte("$2a$10$def")}, users)
+
+	// Test: Other hash formats and malformed lines
+	for _, line := range []string{"alice:{SHA}abc", "alice:$apr1$abc", "alice", ":$2y$05$abc"} {
+		_, err := ParseHtpasswd(strings.NewReader(line))
+		require.Error(t, err, line)
+	}
+
+	// Test: Missing file
+	_, err = LoadHtpasswd(filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
+
+func TestParseHtdigest(t *testing.T) {
+	// Test: Only the users of the realm are kept
+	path := filepath.Join(t.TempDir(), "htdigest")
+	data := "alice:lab:939E7578ED9E3C518A452ACEE763BCE9\nbob:other:939e7578ed9e3c518a452acee763bce9\n"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	users, err := LoadHtdigest(path, "lab")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"alice": "939e7578ed9e3c518a452acee763bce9"}, users)
+
+	// Test: Malformed lines, whatever their realm
+	for _, line := range []string{"alice:lab", "alice:other:xyz", ":lab:939e"} {
+		_, err := ParseHtdigest(strings.NewReader(line), "lab")
+		require.Error(t, err, line)
+	}
+}
@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+)
+
+// Options configures the upstream and storage of a caching proxy.
+type Options struct {
+	Upstream string // base URL requests are forwarded to, e.g., "https://httpbin.org/"
+	Prefix   string // stripped from request targets before appending to Upstream, e.g., "/cached/"
+	MaxBytes int64  // memory cap of stored responses, defaults to 64 MiB
+	// Dir, if set, also stores responses on disk so they survive restarts.
+	// Responses evicted from memory are read back from it, it is not capped.
+	Dir    string
+	Client *http.Client // defaults to a client with a 30 second timeout
+	// Logger logs responses that could not be stored, defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// Proxy is a handler forwarding requests to an upstream, acting as a shared
+// cache based on RFC 9111. Served responses carry an Age header, and an
+// X-Cache header telling whether the upstream was contacted (MISS) or not,
+// including when a stored response was revalidated with a 304 (HIT).
+// Concurrent misses of the same resource are all forwarded.
+type Proxy struct {
+	opts  Options
+	store *store
+	now   func() time.Time // replaceable clock for tests
+}
+
+const (
+	defaultMaxBytes = 64 << 20
+	defaultTimeout  = 30 * time.Second
+)
+
+// hopByHop are fields meaningful for a single connection only, which a proxy
+// must not forward, based on RFC 9110 Section 7.6.1.
+var hopByHop = []string{
+	"connection", "keep-alive", "proxy-connection", "proxy-authenticate",
+	"proxy-authorization", "te", "trailer", "transfer-encoding", "upgrade",
+}
+
+// conditional are the request fields the proxy handles itself instead of
+// forwarding, so the upstream always sends a full response to store.
+var conditional = []string{"if-none-match", "if-modified-since", "if-match", "if-unmodified-since", "if-range"}
+
+func New(opts Options) (*Proxy, error) {
+	if u, err := url.Parse(opts.Upstream); err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid upstream URL: %s", opts.Upstream)
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultMaxBytes
+	}
+	if opts.Dir != "" {
+		if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: defaultTimeout}
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Proxy{
+		opts:  opts,
+		store: newStore(opts.MaxBytes, opts.Dir),
+		now:   time.Now,
+	}, nil
+}
+
+// Serve implements server.Handler by answering from the cache when possible.
+func (p *Proxy) Serve(w *response.Writer, req *request.Request) {
+	target := req.RequestLine.RequestTarget
+	if !strings.HasPrefix(target, p.opts.Prefix) {
+		writeError(w, response.StatusBadRequest, "Invalid request target")
+		return
+	}
+	key := p.opts.Upstream + strings.TrimPrefix(target, p.opts.Prefix)
+
+	// Only GET responses are stored, HEAD is answered from them
+	method := req.RequestLine.Method
+	if method != "GET" && method != "HEAD" {
+		e, err := p.fetch(method, key, req, "")
+		if err != nil {
+			writeError(w, response.StatusBadGateway, fmt.Sprintf("Failed to fetch %s: %v", key, err))
+			return
+		}
+		p.write(w, req, e, "MISS")
+		return
+	}
+
+	stored := p.store.get(key, req.Headers)
+	if stored != nil && stored.fresh(req.Headers, p.now()) {
+		p.write(w, req, stored, "HIT")
+		return
+	}
+
+	// Revalidate a stale response if it has a validator, otherwise fetch anew
+	etag := ""
+	if stored != nil {
+		etag, _ = stored.Header.Get("etag")
+	}
+	e, err := p.fetch("GET", key, req, etag)
+	if err != nil {
+		writeError(w, response.StatusBadGateway, fmt.Sprintf("Failed to fetch %s: %v", key, err))
+		return
+	}
+	status := "MISS"
+	if e.StatusCode == int(response.StatusNotModified) && etag != "" {
+		e = stored.revalidated(e)
+		status = "HIT"
+	}
+	if storable(req.Headers, e) {
+		if err := p.store.put(e); err != nil {
+			p.opts.Logger.Error("cache store error", slog.String("key", key), slog.Any("error", err))
+		}
+	}
+	p.write(w, req, e, status)
+}
+
+// fetch forwards the request to the upstream URL, and reads the full response.
+func (p *Proxy) fetch(method, target string, req *request.Request, etag string) (*entry, error) {
+	var body io.Reader
+	if len(req.Body) > 0 {
+		body = bytes.NewReader(req.Body)
+	}
+	upstreamReq, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range req.Headers {
+		if slices.Contains(hopByHop, key) || slices.Contains(conditional, key) ||
+			key == "host" || key == "content-length" {
+			continue
+		}
+		upstreamReq.Header.Set(key, value)
+	}
+	if etag != "" {
+		upstreamReq.Header.Set("If-None-Match", etag)
+	}
+
+	requestTime := p.now()
+	resp, err := p.opts.Client.Do(upstreamReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	h := headers.NewHeaders()
+	for key, values := range resp.Header {
+		key = strings.ToLower(key)
+		if slices.Contains(hopByHop, key) || key == "content-length" {
+			continue
+		}
+		for _, value := range values {
+			h.Set(key, value)
+		}
+	}
+	return &entry{
+		Key:          target,
+		Vary:         selectVary(req.Headers, h),
+		StatusCode:   resp.StatusCode,
+		Header:       h,
+		Body:         respBody,
+		RequestTime:  requestTime,
+		ResponseTime: p.now(),
+	}, nil
+}
+
+// revalidated returns a copy of the entry freshened by a 304 response, whose
+// fields replace the stored ones, based on RFC 9111 Section 4.3.4.
+func (e *entry) revalidated(notModified *entry) *entry {
+	fresh := *e
+	fresh.Header = maps.Clone(e.Header)
+	maps.Copy(fresh.Header, notModified.Header)
+	fresh.RequestTime = notModified.RequestTime
+	fresh.ResponseTime = notModified.ResponseTime
+	return &fresh
+}
+
+// write sends the entry, or a 304 if it matches the conditional request of the client.
+func (p *Proxy) write(w *response.Writer, req *request.Request, e *entry, status string) {
+	h := maps.Clone(e.Header)
+	h["age"] = strconv.Itoa(int(e.age(p.now()).Seconds()))
+	h["x-cache"] = status
+	h["connection"] = "close"
+
+	etag, hasETag := e.Header.Get("etag")
+	ifNoneMatch, isConditional := req.Headers.Get("if-none-match")
+	if hasETag && isConditional && e.StatusCode == int(response.StatusOK) && etagMatch(ifNoneMatch, etag) {
+		w.WriteStatusLine(response.StatusNotModified)
+		w.WriteHeaders(h)
+		return
+	}
+	h["content-length"] = strconv.Itoa(len(e.Body))
+	w.WriteStatusLine(response.StatusCode(e.StatusCode))
+	w.WriteHeaders(h)
+	w.WriteBody(e.Body)
+}
+
+func writeError(w *response.Writer, statusCode response.StatusCode, message string) {
+	body := []byte(message)
+	w.WriteStatusLine(statusCode)
+	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	w.WriteBody(body)
+}
@@ -0,0 +1,264 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/client"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced clock.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// upstream is a stand-in origin server counting the requests it receives.
+type upstream struct {
+	mu          sync.Mutex
+	hits        map[string]int
+	ifNoneMatch string // last If-None-Match received
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	u.hits[r.URL.Path]++
+	u.ifNoneMatch = r.Header.Get("If-None-Match")
+	u.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/fresh":
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("fresh"))
+	case "/expires":
+		w.Header().Set("Expires", time.Now().Add(10*time.Second).UTC().Format(http.TimeFormat))
+		w.Write([]byte("expires"))
+	case "/etag":
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("etag"))
+	case "/vary":
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte("lang=" + r.Header.Get("Accept-Language")))
+	case "/nostore":
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte("nostore"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (u *upstream) Hits(path string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.hits[path]
+}
+
+// newProxy returns a proxy to a new upstream stand-in, with a fake clock.
+func newProxy(t *testing.T, opts Options) (*Proxy, *upstream, *fakeClock) {
+	t.Helper()
+	up := &upstream{hits: make(map[string]int)}
+	srv := httptest.NewServer(up)
+	t.Cleanup(srv.Close)
+	opts.Upstream = srv.URL + "/"
+	opts.Prefix = "/cached/"
+	p, err := New(opts)
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Now()}
+	p.now = clock.Now
+	return p, up, clock
+}
+
+// do sends a raw request through the proxy, and parses its response.
+func do(t *testing.T, p *Proxy, method, path string, extra ...string) *client.Response {
+	t.Helper()
+	raw := method + " " + path + " HTTP/1.1\r\nHost: localhost\r\n" + strings.Join(extra, "") + "\r\n"
+	req, err := request.RequestFromReader(strings.NewReader(raw))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	w := response.NewWriter(&buf)
+	if method == "HEAD" {
+		w.DiscardBody() // as the server does
+	}
+	p.Serve(w, req)
+	resp, err := client.ReadResponse(bufio.NewReader(&buf), method)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestProxy(t *testing.T) {
+	p, up, clock := newProxy(t, Options{})
+
+	// Test: First request is a miss, then hits while fresh
+	resp := do(t, p, "GET", "/cached/fresh")
+	assert.Equal(t, response.StatusOK, resp.StatusLine.StatusCode)
+	assert.Equal(t, "MISS", resp.Headers["x-cache"])
+	assert.Equal(t, "0", resp.Headers["age"])
+	assert.Equal(t, "fresh", string(resp.Body))
+	clock.Advance(5 * time.Second)
+	resp = do(t, p, "GET", "/cached/fresh")
+	assert.Equal(t, "HIT", resp.Headers["x-cache"])
+	assert.Equal(t, "5", resp.Headers["age"])
+	assert.Equal(t, "fresh", string(resp.Body))
+	assert.Equal(t, 1, up.Hits("/fresh"))
+
+	// Test: HEAD is answered from the stored GET response
+	resp = do(t, p, "HEAD", "/cached/fresh")
+	assert.Equal(t, "HIT", resp.Headers["x-cache"])
+	assert.Equal(t, "5", resp.Headers["content-length"])
+	assert.Empty(t, resp.Body)
+
+	// Test: Request no-cache and max-age bypass the stored response
+	resp = do(t, p, "GET", "/cached/fresh", "Cache-Control: no-cache\r\n")
+	assert.Equal(t, "MISS", resp.Headers["x-cache"])
+	clock.Advance(5 * time.Second)
+	resp = do(t, p, "GET", "/cached/fresh", "Cache-Control: max-age=1\r\n")
+	assert.Equal(t, "MISS", resp.Headers["x-cache"])
+	assert.Equal(t, 3, up.Hits("/fresh"))
+
+	// Test: Stale without a validator is fetched again
+	clock.Advance(61 * time.Second)
+	resp = do(t, p, "GET", "/cached/fresh")
+	assert.Equal(t, "MISS", resp.Headers["x-cache"])
+	assert.Equal(t, 4, up.Hits("/fresh"))
+
+	// Test: no-store responses are never stored
+	do(t, p, "GET", "/cached/nostore")
+	resp = do(t, p, "GET", "/cached/nostore")
+	assert.Equal(t, "MISS", resp.Headers["x-cache"])
+	assert.Equal(t, 2, up.Hits("/nostore"))
+
+	// Test: Upstream errors are passed through but not stored
+	resp = do(t, p, "GET", "/cached/missing")
+	assert.Equal(t, response.StatusNotFound, resp.StatusLine.StatusCode)
+	assert.Equal(t, 1, p.store.len()) // only /fresh
+
+	// Test: Expires is used without max-age, relative to the Date of the upstream
+	p, _, clock = newProxy(t, Options{})
+	resp = do(t, p, "GET", "/cached/expires")
+	assert.Equal(t, "MISS", resp.Headers["x-cache"])
+	resp = do(t, p, "GET", "/cached/expires")
+	assert.Equal(t, "HIT", resp.Headers["x-cache"])
+	clock.Advance(11 * time.Second)
+	resp = do(t, p, "GET", "/cached/expires")
+	assert.Equal(t, "MISS", resp.Headers["x-cache"])
+}
+
+func TestProxyRevalidation(t *testing.T) {
+	p, up, _ := newProxy(t, Options{})
+
+	// Test: no-cache responses are revalidated with If-None-Match on every request
+	resp := do(t, p, "GET", "/cached/etag")
+	assert.Equal(t, "MISS", resp.Headers["x-cache"])
+	assert.Empty(t, up.ifNoneMatch)
+	resp = do(t, p, "GET", "/cached/etag")
+	assert.Equal(t, response.StatusOK, resp.StatusLine.StatusCode)
+	assert.Equal(t, "HIT", resp.Headers["x-cache"])
+	assert.Equal(t, "etag", string(resp.Body))
+	assert.Equal(t, `"v1"`, up.ifNoneMatch)
+	assert.Equal(t, 2, up.Hits("/etag"))
+
+	// Test: Conditional requests of the client are answered by the proxy
+	resp = do(t, p, "GET", "/cached/etag", "If-None-Match: W/\"v0\", \"v1\"\r\n")
+	assert.Equal(t, response.StatusNotModified, resp.StatusLine.StatusCode)
+	assert.Equal(t, `"v1"`, resp.Headers["etag"])
+	assert.Empty(t, resp.Body)
+	assert.Equal(t, `"v1"`, up.ifNoneMatch) // ours, not the one of the client
+	resp = do(t, p, "GET", "/cached/etag", "If-None-Match: \"v0\"\r\n")
+	assert.Equal(t, response.StatusOK, resp.StatusLine.StatusCode)
+}
+
+func TestProxyVary(t *testing.T) {
+	p, up, _ := newProxy(t, Options{})
+
+	// Test: Each variant is stored separately
+	resp := do(t, p, "GET", "/cached/vary", "Accept-Language: en\r\n")
+	assert.Equal(t, "MISS", resp.Headers["x-cache"])
+	resp = do(t, p, "GET", "/cached/vary", "Accept-Language: fr\r\n")
+	assert.Equal(t, "MISS", resp.Headers["x-cache"])
+	assert.Equal(t, "lang=fr", string(resp.Body))
+	resp = do(t, p, "GET", "/cached/vary", "Accept-Language: en\r\n")
+	assert.Equal(t, "HIT", resp.Headers["x-cache"])
+	assert.Equal(t, "lang=en", string(resp.Body))
+
+	// Test: A missing header is a variant too
+	resp = do(t, p, "GET", "/cached/vary")
+	assert.Equal(t, "MISS", resp.Headers["x-cache"])
+	assert.Equal(t, "lang=", string(resp.Body))
+	assert.Equal(t, 3, up.Hits("/vary"))
+}
+
+func TestProxyErrors(t *testing.T) {
+	// Test: Invalid upstream
+	_, err := New(Options{Upstream: "localhost:8080"})
+	require.Error(t, err)
+
+	// Test: Target outside the prefix
+	p, _, _ := newProxy(t, Options{})
+	resp := do(t, p, "GET", "/other")
+	assert.Equal(t, response.StatusBadRequest, resp.StatusLine.StatusCode)
+
+	// Test: Unreachable upstream
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	p, err = New(Options{Upstream: srv.URL + "/", Prefix: "/cached/"})
+	require.NoError(t, err)
+	resp = do(t, p, "GET", "/cached/fresh")
+	assert.Equal(t, response.StatusBadGateway, resp.StatusLine.StatusCode)
+}
+
+func TestFreshness(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newEntry := func(header ...string) *entry {
+		e := &entry{StatusCode: 200, Header: make(map[string]string), RequestTime: now, ResponseTime: now}
+		for i := 0; i < len(header); i += 2 {
+			e.Header.Set(header[i], header[i+1])
+		}
+		return e
+	}
+
+	// Test: s-maxage takes precedence over max-age, which takes precedence over Expires
+	e := newEntry("Cache-Control", "max-age=10, s-maxage=20", "Expires", now.Add(time.Hour).Format(http.TimeFormat))
+	assert.Equal(t, 20*time.Second, e.freshnessLifetime())
+	e = newEntry("Cache-Control", "max-age=10", "Expires", now.Add(time.Hour).Format(http.TimeFormat))
+	assert.Equal(t, 10*time.Second, e.freshnessLifetime())
+	e = newEntry("Date", now.Format(http.TimeFormat), "Expires", now.Add(time.Hour).Format(http.TimeFormat))
+	assert.Equal(t, time.Hour, e.freshnessLifetime())
+	e = newEntry("Expires", "0")
+	assert.Equal(t, time.Duration(0), e.freshnessLifetime())
+
+	// Test: Age accounts for the Age header and the Date of the upstream
+	e = newEntry("Age", "30")
+	assert.Equal(t, 40*time.Second, e.age(now.Add(10*time.Second)))
+	e = newEntry("Date", now.Add(-20*time.Second).Format(http.TimeFormat))
+	assert.Equal(t, 20*time.Second, e.age(now))
+
+	// Test: Storability
+	req := make(map[string]string)
+	assert.True(t, storable(req, newEntry("Cache-Control", "max-age=10")))
+	assert.True(t, storable(req, newEntry("ETag", `"x"`)))
+	assert.False(t, storable(req, newEntry()))
+	assert.False(t, storable(req, newEntry("Cache-Control", "private, max-age=10")))
+	assert.False(t, storable(req, newEntry("Cache-Control", "max-age=10", "Vary", "*")))
+	assert.False(t, storable(req, newEntry("Cache-Control", "max-age=10", "Set-Cookie", "a=b")))
+	req["authorization"] = "Basic Zm9vOmJhcg=="
+	assert.False(t, storable(req, newEntry("Cache-Control", "max-age=10")))
+	assert.True(t, storable(req, newEntry("Cache-Control", "public, max-age=10")))
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+)
+
+// cacheControl holds the directives of a Cache-Control header, based on RFC 9111 Section 5.2.
+// Directive names are lowercase, and arguments are unquoted.
+type cacheControl map[string]string
+
+func parseCacheControl(h headers.Headers) cacheControl {
+	cc := make(cacheControl)
+	val, found := h.Get("cache-control")
+	if !found {
+		// Pragma is only honored without Cache-Control, based on RFC 9111 Section 5.4
+		if pragma, _ := h.Get("pragma"); strings.EqualFold(strings.TrimSpace(pragma), "no-cache") {
+			cc["no-cache"] = ""
+		}
+		return cc
+	}
+	for part := range strings.SplitSeq(val, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name == "" {
+			continue
+		}
+		cc[strings.ToLower(name)] = strings.Trim(arg, `"`)
+	}
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// seconds returns the delta-seconds argument of the directive, if valid.
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	arg, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// cacheableStatus are the status codes a cache may store, based on RFC 9110 Section 15.1.
+var cacheableStatus = []int{200, 203, 204, 206, 300, 301, 308, 404, 405, 410, 414, 501}
+
+// storable reports whether a shared cache may store the response to the request,
+// based on RFC 9111 Section 3. Responses without explicit freshness are only
+// stored if they have an ETag to revalidate with, we do no heuristic freshness.
+func storable(req headers.Headers, e *entry) bool {
+	if parseCacheControl(req).has("no-store") {
+		return false
+	}
+	cc := parseCacheControl(e.Header)
+	if cc.has("no-store") || cc.has("private") {
+		return false
+	}
+	if !slices.Contains(cacheableStatus, e.StatusCode) {
+		return false
+	}
+	if vary, _ := e.Header.Get("vary"); strings.Contains(vary, "*") {
+		return false // never matches a later request
+	}
+	if _, found := e.Header.Get("set-cookie"); found {
+		return false // would hand one client's cookies to another
+	}
+	if _, found := req.Get("authorization"); found &&
+		!cc.has("public") && !cc.has("s-maxage") && !cc.has("must-revalidate") {
+		return false // based on RFC 9111 Section 3.5
+	}
+	_, hasETag := e.Header.Get("etag")
+	return e.freshnessLifetime() > 0 || hasETag
+}
+
+// freshnessLifetime returns how long the response is fresh after it was
+// generated, based on RFC 9111 Section 4.2.1.
+func (e *entry) freshnessLifetime() time.Duration {
+	cc := parseCacheControl(e.Header)
+	if d, ok := cc.seconds("s-maxage"); ok {
+		return d
+	}
+	if d, ok := cc.seconds("max-age"); ok {
+		return d
+	}
+	if val, found := e.Header.Get("expires"); found {
+		expires, err := http.ParseTime(val)
+		if err != nil {
+			return 0 // invalid dates represent a time in the past
+		}
+		return expires.Sub(e.date())
+	}
+	return 0
+}
+
+// age returns the current age of the response, based on RFC 9111 Section 4.2.3.
+func (e *entry) age(now time.Time) time.Duration {
+	apparentAge := max(0, e.ResponseTime.Sub(e.date()))
+	var ageValue time.Duration
+	if val, found := e.Header.Get("age"); found {
+		if n, err := strconv.ParseInt(val, 10, 64); err == nil && n >= 0 {
+			ageValue = time.Duration(n) * time.Second
+		}
+	}
+	responseDelay := e.ResponseTime.Sub(e.RequestTime)
+	correctedInitialAge := max(apparentAge, ageValue+responseDelay)
+	return correctedInitialAge + now.Sub(e.ResponseTime)
+}
+
+// fresh reports whether the entry can be served without revalidation, taking
+// the request directives into account.
+func (e *entry) fresh(req headers.Headers, now time.Time) bool {
+	reqCC := parseCacheControl(req)
+	cc := parseCacheControl(e.Header)
+	if reqCC.has("no-cache") || cc.has("no-cache") {
+		return false
+	}
+	age := e.age(now)
+	if maxAge, ok := reqCC.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+	return age < e.freshnessLifetime()
+}
+
+// date returns the Date header, or the response time if missing or invalid.
+func (e *entry) date() time.Time {
+	if val, found := e.Header.Get("date"); found {
+		if t, err := http.ParseTime(val); err == nil {
+			return t
+		}
+	}
+	return e.ResponseTime
+}
+
+// etagMatch reports whether the If-None-Match value matches the ETag, using
+// weak comparison, based on RFC 9110 Section 13.1.2.
+func etagMatch(ifNoneMatch, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
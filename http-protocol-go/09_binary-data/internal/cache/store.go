@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+)
+
+// entry is a stored response. It is never modified once stored, so it can be
+// served while another request replaces it.
+type entry struct {
+	Key          string            `json:"key"`  // upstream URL
+	Vary         map[string]string `json:"vary"` // request header values selected by Vary
+	StatusCode   int               `json:"status_code"`
+	Header       headers.Headers   `json:"header"`
+	Body         []byte            `json:"body"`
+	RequestTime  time.Time         `json:"request_time"`
+	ResponseTime time.Time         `json:"response_time"`
+}
+
+// size approximates the memory used by the entry.
+func (e *entry) size() int64 {
+	n := len(e.Key) + len(e.Body)
+	for key, value := range e.Header {
+		n += len(key) + len(value)
+	}
+	for key, value := range e.Vary {
+		n += len(key) + len(value)
+	}
+	return int64(n)
+}
+
+// matches reports whether the entry can answer a request with the headers,
+// based on RFC 9111 Section 4.1.
+func (e *entry) matches(h headers.Headers) bool {
+	for key, value := range e.Vary {
+		if got, _ := h.Get(key); strings.TrimSpace(got) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// selectVary returns the request header values selected by the response Vary header.
+func selectVary(req, resp headers.Headers) map[string]string {
+	vary := make(map[string]string)
+	val, _ := resp.Get("vary")
+	for key := range strings.SplitSeq(val, ",") {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+		got, _ := req.Get(key)
+		vary[key] = strings.TrimSpace(got)
+	}
+	return vary
+}
+
+// store is an LRU of entries capped by size, optionally backed by a directory.
+// Each key may have several entries, one per variant selected by Vary.
+type store struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	lru      *list.List                 // of *entry, front is most recently used
+	variants map[string][]*list.Element // key -> entries
+	dir      string
+}
+
+func newStore(maxBytes int64, dir string) *store {
+	return &store{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		variants: make(map[string][]*list.Element),
+		dir:      dir,
+	}
+}
+
+// get returns the entry for the key matching the request headers, or nil.
+// Entries evicted from memory are looked up on disk, if any.
+func (s *store) get(key string, h headers.Headers) *entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, el := range s.variants[key] {
+		if e := el.Value.(*entry); e.matches(h) {
+			s.lru.MoveToFront(el)
+			return e
+		}
+	}
+	if s.dir == "" {
+		return nil
+	}
+	for _, e := range s.load(key) {
+		if e.matches(h) {
+			s.add(e)
+			return e
+		}
+	}
+	return nil
+}
+
+// put stores the entry, replacing the entry of the same variant. The memory
+// copy is kept even if writing to disk fails, which is then reported.
+func (s *store) put(e *entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(e)
+	if s.dir == "" {
+		return nil
+	}
+	return s.save(e)
+}
+
+// len returns the number of entries in memory.
+func (s *store) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// add puts the entry in memory, evicting least recently used entries to stay
+// under the cap. Entries larger than the cap are not kept. Caller must hold the lock.
+func (s *store) add(e *entry) {
+	for _, el := range s.variants[e.Key] {
+		if maps.Equal(el.Value.(*entry).Vary, e.Vary) {
+			s.remove(el)
+			break
+		}
+	}
+	if e.size() > s.maxBytes {
+		return
+	}
+	s.variants[e.Key] = append(s.variants[e.Key], s.lru.PushFront(e))
+	s.size += e.size()
+	for s.size > s.maxBytes {
+		s.remove(s.lru.Back())
+	}
+}
+
+// remove drops the element from memory. Caller must hold the lock.
+func (s *store) remove(el *list.Element) {
+	e := s.lru.Remove(el).(*entry)
+	s.size -= e.size()
+	elems := s.variants[e.Key]
+	for i, other := range elems {
+		if other == el {
+			elems = append(elems[:i], elems[i+1:]...)
+			break
+		}
+	}
+	if len(elems) == 0 {
+		delete(s.variants, e.Key)
+	} else {
+		s.variants[e.Key] = elems
+	}
+}
+
+// path returns the file holding all variants of the key.
+func (s *store) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load reads the variants of the key from disk, missing or corrupt files
+// are treated as empty. Caller must hold the lock.
+func (s *store) load(key string) []*entry {
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		return nil
+	}
+	var entries []*entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil
+	}
+	return entries
+}
+
+// save writes the entry to disk along with the other variants of its key.
+// The disk store is not capped. Caller must hold the lock.
+func (s *store) save(e *entry) error {
+	entries := []*entry{e}
+	for _, other := range s.load(e.Key) {
+		if !maps.Equal(other.Vary, e.Vary) {
+			entries = append(entries, other)
+		}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so readers never see a partial file
+	path := s.path(e.Key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
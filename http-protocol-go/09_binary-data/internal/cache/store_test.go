@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sizedEntry returns an entry for the key whose size is exactly n bytes.
+func sizedEntry(key string, n int) *entry {
+	return &entry{
+		Key:    key,
+		Vary:   map[string]string{},
+		Header: headers.NewHeaders(),
+		Body:   []byte(strings.Repeat("x", n-len(key))),
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := newStore(100, "")
+	h := headers.NewHeaders()
+
+	// Test: Least recently used entries are evicted over the cap
+	require.NoError(t, s.put(sizedEntry("a", 40)))
+	require.NoError(t, s.put(sizedEntry("b", 40)))
+	require.NotNil(t, s.get("a", h)) // now b is the least recently used
+	require.NoError(t, s.put(sizedEntry("c", 40)))
+	assert.NotNil(t, s.get("a", h))
+	assert.Nil(t, s.get("b", h))
+	assert.NotNil(t, s.get("c", h))
+	assert.Equal(t, int64(80), s.size)
+
+	// Test: Replacing an entry does not count it twice
+	require.NoError(t, s.put(sizedEntry("a", 50)))
+	assert.Equal(t, 2, s.len())
+	assert.Equal(t, int64(90), s.size)
+
+	// Test: Entries larger than the cap are not stored
+	require.NoError(t, s.put(sizedEntry("d", 101)))
+	assert.Nil(t, s.get("d", h))
+	assert.Equal(t, 2, s.len())
+
+	// Test: Variants of a key are matched on the request headers
+	en := sizedEntry("v", 10)
+	en.Vary = map[string]string{"accept-language": "en"}
+	fr := sizedEntry("v", 10)
+	fr.Vary = map[string]string{"accept-language": "fr"}
+	require.NoError(t, s.put(en))
+	require.NoError(t, s.put(fr))
+	h.Set("Accept-Language", "fr")
+	assert.Same(t, fr, s.get("v", h))
+	assert.Nil(t, s.get("v", headers.NewHeaders()))
+}
+
+func TestStoreDisk(t *testing.T) {
+	dir := t.TempDir()
+	h := headers.NewHeaders()
+
+	// Test: Entries survive a new store on the same directory
+	s := newStore(100, dir)
+	require.NoError(t, s.put(sizedEntry("a", 40)))
+	s = newStore(100, dir)
+	e := s.get("a", h)
+	require.NotNil(t, e)
+	assert.Equal(t, 40-len("a"), len(e.Body))
+	assert.Equal(t, 1, s.len())
+
+	// Test: Entries evicted from memory are read back from disk
+	require.NoError(t, s.put(sizedEntry("b", 40)))
+	require.NoError(t, s.put(sizedEntry("c", 40)))
+	assert.Equal(t, 2, s.len())
+	assert.NotNil(t, s.get("a", h))
+
+	// Test: Corrupt files are treated as missing
+	require.NoError(t, os.WriteFile(s.path("z"), []byte("{"), 0o644))
+	assert.Nil(t, s.get("z", h))
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+)
+
+// Conn is a client connection to a server, which sends requests one after
+// another on the same connection (keep-alive) until either side closes it.
+type Conn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	closed  bool
+}
+
+// Dial connects to the TCP address. The timeout bounds dialing, and each
+// request and response exchange done later, zero means no timeout.
+func Dial(addr string, timeout time.Duration) (*Conn, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: timeout,
+	}, nil
+}
+
+// Do sends the request and reads its response. After a response asking to
+// close the connection, the connection is closed and Do must not be called again.
+func (c *Conn) Do(req *request.Request) (*Response, error) {
+	if c.closed {
+		return nil, fmt.Errorf("connection is closed")
+	}
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	if err := WriteRequest(c.conn, req); err != nil {
+		c.Close()
+		return nil, err
+	}
+	resp, err := ReadResponse(c.reader, req.RequestLine.Method)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if !resp.KeepAlive() || hasToken(req.Headers, "connection", "close") {
+		c.Close()
+	}
+	return resp, nil
+}
+
+// Closed reports whether the connection was closed, by us or due to the server.
+func (c *Conn) Closed() bool {
+	return c.closed
+}
+
+func (c *Conn) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.conn.Close()
+}
+
+// KeepAlive reports whether the connection may be reused after this response,
+// based on RFC 9112 Section 9.3.
+func (r *Response) KeepAlive() bool {
+	if hasToken(r.Headers, "connection", "close") {
+		return false
+	}
+	if r.StatusLine.HTTPVersion == "1.0" {
+		return hasToken(r.Headers, "connection", "keep-alive")
+	}
+	// A body delimited by closing the connection cannot be followed by another response
+	_, hasLength := r.Headers.Get("content-length")
+	_, hasChunked := r.Headers.Get("transfer-encoding")
+	code := r.StatusLine.StatusCode
+	return hasLength || hasChunked || code == 204 || code == 304 || (code >= 100 && code < 200)
+}
+
+// WriteRequest writes the request in the HTTP/1.1 message format, based on
+// RFC 9112 Section 2.1. Content-Length is set from the body if missing.
+func WriteRequest(w io.Writer, req *request.Request) error {
+	line := req.RequestLine
+	if line.Method == "" || line.RequestTarget == "" {
+		return fmt.Errorf("request must have a method and a target")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", line.Method, line.RequestTarget)
+	keys := make([]string, 0, len(req.Headers))
+	for key := range req.Headers {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys) // deterministic output helps when comparing captures
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s: %s\r\n", key, req.Headers[key])
+	}
+	_, hasLength := req.Headers.Get("content-length")
+	_, hasChunked := req.Headers.Get("transfer-encoding")
+	if !hasLength && !hasChunked && len(req.Body) > 0 {
+		fmt.Fprintf(&b, "content-length: %s\r\n", strconv.Itoa(len(req.Body)))
+	}
+	b.WriteString("\r\n")
+
+	// A single write, so small requests go out in a single packet
+	data := append([]byte(b.String()), req.Body...)
+	_, err := w.Write(data)
+	return err
+}
+
+// hasToken reports whether the comma-separated header contains the token.
+func hasToken(h map[string]string, key, token string) bool {
+	for part := range strings.SplitSeq(h[key], ",") {
+		if strings.EqualFold(strings.TrimSpace(part), token) {
+			return true
+		}
+	}
+	return false
+}
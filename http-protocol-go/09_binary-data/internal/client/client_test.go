@@ -0,0 +1,107 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRequest returns a request for the target with the given headers.
+func newRequest(method, target string, h map[string]string, body string) *request.Request {
+	req := &request.Request{
+		RequestLine: request.RequestLine{Method: method, RequestTarget: target, HTTPVersion: "1.1"},
+		Headers:     headers.NewHeaders(),
+		Body:        []byte(body),
+	}
+	for key, value := range h {
+		req.Headers.Set(key, value)
+	}
+	return req
+}
+
+// keepAliveServer answers each request on a connection with its target,
+// closing the connection after maxRequests responses.
+func keepAliveServer(t *testing.T, maxRequests int) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for i := 1; i <= maxRequests; i++ {
+					req, err := request.RequestFromReader(conn)
+					if err != nil {
+						return
+					}
+					connection := "keep-alive"
+					if i == maxRequests {
+						connection = "close"
+					}
+					body := req.RequestLine.RequestTarget + " " + string(req.Body)
+					fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\nConnection: %s\r\n\r\n%s", len(body), connection, body)
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestWriteRequest(t *testing.T) {
+	// Test: Headers are sorted and Content-Length is added
+	var buf bytes.Buffer
+	err := WriteRequest(&buf, newRequest("POST", "/submit", map[string]string{"Host": "localhost", "Accept": "*/*"}, "hello"))
+	require.NoError(t, err)
+	assert.Equal(t, "POST /submit HTTP/1.1\r\naccept: */*\r\nhost: localhost\r\ncontent-length: 5\r\n\r\nhello", buf.String())
+
+	// Test: Request without a body
+	buf.Reset()
+	err = WriteRequest(&buf, newRequest("GET", "/", map[string]string{"Host": "localhost"}, ""))
+	require.NoError(t, err)
+	assert.Equal(t, "GET / HTTP/1.1\r\nhost: localhost\r\n\r\n", buf.String())
+
+	// Test: Invalid request
+	err = WriteRequest(&buf, newRequest("", "/", nil, ""))
+	require.Error(t, err)
+}
+
+func TestConn(t *testing.T) {
+	addr := keepAliveServer(t, 2)
+
+	// Test: Several requests on a single connection
+	conn, err := Dial(addr, time.Second)
+	require.NoError(t, err)
+	resp, err := conn.Do(newRequest("GET", "/one", map[string]string{"Host": "localhost"}, ""))
+	require.NoError(t, err)
+	assert.Equal(t, "/one ", string(resp.Body))
+	assert.True(t, resp.KeepAlive())
+	assert.False(t, conn.Closed())
+	resp, err = conn.Do(newRequest("POST", "/two", map[string]string{"Host": "localhost"}, "data"))
+	require.NoError(t, err)
+	assert.Equal(t, "/two data", string(resp.Body))
+
+	// Test: Connection closed by the server
+	assert.False(t, resp.KeepAlive())
+	assert.True(t, conn.Closed())
+	_, err = conn.Do(newRequest("GET", "/three", map[string]string{"Host": "localhost"}, ""))
+	require.Error(t, err)
+
+	// Test: Connection closed by the client
+	conn, err = Dial(addr, time.Second)
+	require.NoError(t, err)
+	_, err = conn.Do(newRequest("GET", "/", map[string]string{"Host": "localhost", "Connection": "close"}, ""))
+	require.NoError(t, err)
+	assert.True(t, conn.Closed())
+}
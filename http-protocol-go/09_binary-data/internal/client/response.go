@@ -0,0 +1,215 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+)
+
+// MaxBodySize bounds the body of a response read by ReadResponse, so a server
+// cannot make the client allocate or buffer an arbitrary amount of memory.
+const MaxBodySize = 10 << 20
+
+var ErrBodyTooLarge = errors.New("response body exceeds the maximum size")
+
+// Response represents an HTTP response read by a client, based on RFC 9112 Section 2.1.
+type Response struct {
+	StatusLine StatusLine
+	Headers    headers.Headers
+	Body       []byte
+	Trailers   headers.Headers // only sent with chunked bodies
+	Interim    []Interim       // received before this response, e.g., 103 Early Hints
+}
+
+// Interim is an interim (1xx) response preceding the final one, based on RFC 9110 Section 15.2.
+type Interim struct {
+	StatusLine StatusLine
+	Headers    headers.Headers
+}
+
+// ReadResponse reads a response from the reader, for a request with the given
+// method since it affects whether the response has a body. The reader is
+// buffered so that bytes of a next response on the connection are kept.
+// Bodies larger than MaxBodySize fail with ErrBodyTooLarge.
+func ReadResponse(reader *bufio.Reader, method string) (*Response, error) {
+	return ReadResponseLimit(reader, method, MaxBodySize)
+}
+
+// ReadResponseLimit is ReadResponse with another body size limit, zero or
+// negative means none, e.g., for responses the program buffered itself.
+func ReadResponseLimit(reader *bufio.Reader, method string, maxBodySize int64) (*Response, error) {
+	if maxBodySize <= 0 {
+		maxBodySize = math.MaxInt64
+	}
+	var interim []Interim
+	for {
+		resp, err := readSingle(reader, method, maxBodySize)
+		if err != nil {
+			return nil, err
+		}
+		// Interim responses precede the final one, except for 101 Switching Protocols
+		code := resp.StatusLine.StatusCode
+		if code >= 100 && code < 200 && code != 101 {
+			interim = append(interim, Interim{resp.StatusLine, resp.Headers})
+			continue
+		}
+		resp.Interim = interim
+		return resp, nil
+	}
+}
+
+func readSingle(reader *bufio.Reader, method string, maxBodySize int64) (*Response, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, fmt.Errorf("reading status line: %w", err)
+	}
+	statusLine, err := parseStatusLine(line)
+	if err != nil {
+		return nil, err
+	}
+	resp := &Response{
+		StatusLine: *statusLine,
+		Headers:    headers.NewHeaders(),
+		Body:       make([]byte, 0),
+		Trailers:   headers.NewHeaders(),
+	}
+	if err := readHeaders(reader, resp.Headers); err != nil {
+		return nil, err
+	}
+	if err := resp.readBody(reader, method, maxBodySize); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// readBody reads the body based on the message body length rules of RFC 9112 Section 6.3.
+func (r *Response) readBody(reader *bufio.Reader, method string, maxBodySize int64) error {
+	code := r.StatusLine.StatusCode
+	if method == "HEAD" || (code >= 100 && code < 200) || code == 204 || code == 304 {
+		return nil // never has a body, even if framing headers are present
+	}
+	if te, found := r.Headers.Get("transfer-encoding"); found {
+		if !strings.EqualFold(strings.TrimSpace(lastElement(te)), "chunked") {
+			return fmt.Errorf("unsupported transfer-encoding: %s", te)
+		}
+		return r.readChunkedBody(reader, maxBodySize)
+	}
+	if cl, found := r.Headers.Get("content-length"); found {
+		num, err := strconv.Atoi(cl)
+		if err != nil || num < 0 {
+			return fmt.Errorf("invalid content-length header: %s", cl)
+		}
+		if int64(num) > maxBodySize {
+			return ErrBodyTooLarge
+		}
+		// Grow the body as data arrives instead of trusting the declared length
+		var body bytes.Buffer
+		if _, err := io.CopyN(&body, reader, int64(num)); err != nil {
+			return fmt.Errorf("body shorter than content-length %d: %w", num, err)
+		}
+		r.Body = append(r.Body, body.Bytes()...)
+		return nil
+	}
+	// Otherwise the body is delimited by the server closing the connection
+	body, err := io.ReadAll(io.LimitReader(reader, maxBodySize))
+	if err != nil {
+		return err
+	}
+	if _, err := reader.Peek(1); err == nil { // more than the limit
+		return ErrBodyTooLarge
+	}
+	r.Body = body
+	return nil
+}
+
+// readChunkedBody reads a chunked body and its trailers, based on RFC 9112 Section 7.1.
+// Trailer fields must be declared in the Trailer header, and not be forbidden.
+func (r *Response) readChunkedBody(reader *bufio.Reader, maxBodySize int64) error {
+	declared, err := r.Headers.DeclaredTrailers()
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return fmt.Errorf("reading chunk size: %w", err)
+		}
+		sizeStr, _, _ := strings.Cut(line, ";") // ignore chunk extensions
+		size, err := strconv.ParseInt(strings.TrimSpace(sizeStr), 16, 64)
+		if err != nil || size < 0 {
+			return fmt.Errorf("invalid chunk size: %s", line)
+		}
+		if size == 0 {
+			break // last chunk
+		}
+		if size > maxBodySize-int64(body.Len()) {
+			return ErrBodyTooLarge
+		}
+		if _, err := io.CopyN(&body, reader, size); err != nil {
+			return fmt.Errorf("reading chunk data: %w", err)
+		}
+		if line, err := readLine(reader); err != nil || line != "" {
+			return fmt.Errorf("missing CRLF after chunk data")
+		}
+	}
+	r.Body = append(r.Body, body.Bytes()...)
+	if err := readHeaders(reader, r.Trailers); err != nil {
+		return err
+	}
+	return headers.ValidateTrailers(declared, r.Trailers)
+}
+
+// VerifyContentSHA256 checks the body against the integrity trailers, see
+// headers.VerifyContentSHA256. It is optional, since most servers send none.
+func (r *Response) VerifyContentSHA256() error {
+	return headers.VerifyContentSHA256(r.Body, r.Trailers)
+}
+
+// readHeaders reads field lines into h until the empty line.
+func readHeaders(reader *bufio.Reader, h headers.Headers) error {
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return fmt.Errorf("reading headers: %w", err)
+		}
+		// Headers.Parse expects the CRLF, and reports done on an empty line
+		_, done, err := h.Parse([]byte(line + "\r\n"))
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// readLine reads a line terminated by CRLF, and returns it without the CRLF.
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadSlice('\n')
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return "", fmt.Errorf("line not terminated by CRLF: %q", line)
+	}
+	return string(line[:len(line)-2]), nil
+}
+
+// lastElement returns the last element of a comma-separated list.
+func lastElement(s string) string {
+	if i := strings.LastIndex(s, ","); i != -1 {
+		return s[i+1:]
+	}
+	return s
+}
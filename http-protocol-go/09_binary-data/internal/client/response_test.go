@@ -0,0 +1,134 @@
+package client
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkReader simulates reading a variable number of bytes per chunk from a network connection.
+type chunkReader struct {
+	data            string // The source data to read from
+	numBytesPerRead int    // Maximum bytes to read per Read() call
+	pos             int    // Current position in the data (read cursor)
+}
+
+func (cr *chunkReader) Read(p []byte) (n int, err error) {
+	if cr.pos >= len(cr.data) {
+		return 0, io.EOF
+	}
+	endIndex := min(cr.pos+cr.numBytesPerRead, len(cr.data))
+	n = copy(p, cr.data[cr.pos:endIndex])
+	cr.pos += n
+	return n, nil
+}
+
+// newReader returns a buffered reader over data, read 3 bytes at a time.
+func newReader(data string) *bufio.Reader {
+	return bufio.NewReader(&chunkReader{data: data, numBytesPerRead: 3})
+}
+
+func TestReadResponse(t *testing.T) {
+	// Test: Content-Length body
+	r, err := ReadResponse(newReader("HTTP/1.1 200 OK\r\nContent-Length: 5\r\nContent-Type: text/plain\r\n\r\nhello"), "GET")
+	require.NoError(t, err)
+	assert.Equal(t, "1.1", r.StatusLine.HTTPVersion)
+	assert.Equal(t, response.StatusOK, r.StatusLine.StatusCode)
+	assert.Equal(t, "OK", r.StatusLine.ReasonPhrase)
+	assert.Equal(t, "text/plain", r.Headers["content-type"])
+	assert.Equal(t, "hello", string(r.Body))
+
+	// Test: Chunked body with extensions and trailers
+	r, err = ReadResponse(newReader("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\nTrailer: X-Sum\r\n\r\n"+
+		"5;name=value\r\nhello\r\n6\r\n world\r\n0\r\nX-Sum: abc\r\n\r\n"), "GET")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(r.Body))
+	assert.Equal(t, "abc", r.Trailers["x-sum"])
+
+	// Test: Body delimited by connection close, with multi-word reason phrase
+	r, err = ReadResponse(newReader("HTTP/1.1 500 Internal Server Error\r\n\r\nuntil the end"), "GET")
+	require.NoError(t, err)
+	assert.Equal(t, "Internal Server Error", r.StatusLine.ReasonPhrase)
+	assert.Equal(t, "until the end", string(r.Body))
+
+	// Test: HEAD response has no body despite Content-Length
+	reader := newReader("HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nHTTP/1.1 204 No Content\r\n\r\n")
+	r, err = ReadResponse(reader, "HEAD")
+	require.NoError(t, err)
+	assert.Empty(t, r.Body)
+	r, err = ReadResponse(reader, "GET") // next response on the same connection
+	require.NoError(t, err)
+	assert.Equal(t, response.StatusNoContent, r.StatusLine.StatusCode)
+
+	// Test: Interim responses are recorded ahead of the final one
+	r, err = ReadResponse(newReader("HTTP/1.1 100 Continue\r\n\r\n"+
+		"HTTP/1.1 103 Early Hints\r\nLink: </style.css>; rel=preload\r\n\r\n"+
+		"HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"), "POST")
+	require.NoError(t, err)
+	assert.Equal(t, response.StatusOK, r.StatusLine.StatusCode)
+	assert.Equal(t, "ok", string(r.Body))
+	require.Len(t, r.Interim, 2)
+	assert.Equal(t, response.StatusContinue, r.Interim[0].StatusLine.StatusCode)
+	assert.Equal(t, response.StatusEarlyHints, r.Interim[1].StatusLine.StatusCode)
+	link, _ := r.Interim[1].Headers.Get("link")
+	assert.Equal(t, "</style.css>; rel=preload", link)
+
+	// Test: Body shorter than Content-Length
+	_, err = ReadResponse(newReader("HTTP/1.1 200 OK\r\nContent-Length: 10\r\n\r\nshort"), "GET")
+	require.Error(t, err)
+
+	// Test: Bodies larger than MaxBodySize, without allocating the declared size
+	_, err = ReadResponse(newReader("HTTP/1.1 200 OK\r\nContent-Length: 99999999999\r\n\r\nshort"), "GET")
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+	_, err = ReadResponse(newReader("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n7fffffffffffffff\r\nshort"), "GET")
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+	_, err = ReadResponse(newReader("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n"+
+		"5\r\nhello\r\n"+strconv.FormatInt(MaxBodySize-4, 16)+"\r\n"), "GET")
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+	_, err = ReadResponse(bufio.NewReader(strings.NewReader("HTTP/1.1 200 OK\r\n\r\n"+strings.Repeat("a", MaxBodySize+1))), "GET")
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+
+	// Test: Other limits, or none
+	_, err = ReadResponseLimit(newReader("HTTP/1.1 200 OK\r\n\r\nhello"), "GET", 4)
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+	r, err = ReadResponseLimit(newReader("HTTP/1.1 200 OK\r\n\r\nhello"), "GET", 5)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(r.Body))
+	r, err = ReadResponseLimit(bufio.NewReader(strings.NewReader("HTTP/1.1 200 OK\r\n\r\n"+strings.Repeat("a", MaxBodySize+1))), "GET", 0)
+	require.NoError(t, err)
+	assert.Len(t, r.Body, MaxBodySize+1)
+
+	// Test: Invalid status lines
+	for _, line := range []string{"HTTP/2 200 OK", "HTTP/1.1 20 OK", "HTTP/1.1 abc OK", "garbage"} {
+		_, err = ReadResponse(newReader(line+"\r\n\r\n"), "GET")
+		require.Error(t, err, line)
+	}
+
+	// Test: Invalid chunk size
+	_, err = ReadResponse(newReader("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n"), "GET")
+	require.Error(t, err)
+
+	// Test: Undeclared and forbidden trailers
+	_, err = ReadResponse(newReader("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n0\r\nX-Sum: abc\r\n\r\n"), "GET")
+	require.Error(t, err)
+	_, err = ReadResponse(newReader("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\nTrailer: Set-Cookie\r\n\r\n0\r\nSet-Cookie: a=b\r\n\r\n"), "GET")
+	require.Error(t, err)
+
+	// Test: Integrity trailers
+	r, err = ReadResponse(newReader("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\nTrailer: X-Content-SHA256\r\n\r\n"+
+		"5\r\nhello\r\n0\r\nX-Content-SHA256: 2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824\r\n\r\n"), "GET")
+	require.NoError(t, err)
+	require.NoError(t, r.VerifyContentSHA256())
+	r.Body = []byte("jello")
+	require.Error(t, r.VerifyContentSHA256())
+
+	// Test: Bare LF line ending
+	_, err = ReadResponse(newReader("HTTP/1.1 200 OK\n\n"), "GET")
+	require.Error(t, err)
+}
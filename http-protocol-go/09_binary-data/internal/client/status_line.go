@@ -0,0 +1,41 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+)
+
+type StatusLine struct {
+	HTTPVersion  string
+	StatusCode   response.StatusCode
+	ReasonPhrase string
+}
+
+// parseStatusLine parses the status line without its CRLF, based on RFC 9112 Section 4.
+func parseStatusLine(line string) (*StatusLine, error) {
+	// The reason phrase may contain spaces or be empty, so split at most twice
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid status line: %s", line)
+	}
+	version, found := strings.CutPrefix(parts[0], "HTTP/")
+	if !found || (version != "1.1" && version != "1.0") {
+		return nil, fmt.Errorf("invalid HTTP version: %s", parts[0])
+	}
+	code, err := strconv.Atoi(parts[1])
+	if err != nil || len(parts[1]) != 3 || code < 100 {
+		return nil, fmt.Errorf("invalid status code: %s", parts[1])
+	}
+	reason := ""
+	if len(parts) == 3 {
+		reason = parts[2]
+	}
+	return &StatusLine{
+		HTTPVersion:  version,
+		StatusCode:   response.StatusCode(code),
+		ReasonPhrase: reason,
+	}, nil
+}
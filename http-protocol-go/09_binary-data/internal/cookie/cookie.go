@@ -0,0 +1,162 @@
+package cookie
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cookie represents an HTTP cookie, based on RFC 6265 Section 4.
+type Cookie struct {
+	Name  string
+	Value string
+
+	// Attributes below are only used when building a Set-Cookie header.
+	Path     string
+	Domain   string
+	Expires  time.Time // zero value means no Expires attribute
+	MaxAge   int       // 0 means no Max-Age attribute, negative means Max-Age=0
+	Secure   bool
+	HttpOnly bool
+	SameSite SameSite
+}
+
+type SameSite int
+
+const (
+	SameSiteDefault SameSite = iota // no SameSite attribute
+	SameSiteLax
+	SameSiteStrict
+	SameSiteNone
+)
+
+// timeFormat is the IMF-fixdate format, based on RFC 9110 Section 5.6.7.
+const timeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// Parse parses the value of a Cookie request header, based on RFC 6265 Section 5.4.
+// Like url.ParseQuery, it always returns the valid cookies found, skipping
+// invalid pairs, and err describes the first invalid pair, if any.
+func Parse(s string) ([]*Cookie, error) {
+	cookies := make([]*Cookie, 0)
+	var firstErr error
+	// Pairs are separated by "; ", but we also split on commas since duplicate
+	// Cookie headers are folded into a single comma-separated value
+	pairs := strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ',' })
+	for _, pair := range pairs {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		c, err := parsePair(pair)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, firstErr
+}
+
+// parsePair parses a single name=value pair of a Cookie header.
+func parsePair(pair string) (*Cookie, error) {
+	name, value, found := strings.Cut(pair, "=")
+	if !found {
+		return nil, fmt.Errorf("invalid cookie pair: %s", pair)
+	}
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	value, err := parseValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return &Cookie{Name: name, Value: value}, nil
+}
+
+// Serialize returns the cookie as a Set-Cookie header value, based on
+// RFC 6265 Section 4.1. It returns an error if the cookie is invalid.
+func (c *Cookie) Serialize() (string, error) {
+	if err := validateName(c.Name); err != nil {
+		return "", err
+	}
+	value, err := parseValue(c.Value)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(c.Name + "=" + value)
+	if c.Path != "" {
+		if strings.ContainsAny(c.Path, ";\r\n") {
+			return "", fmt.Errorf("invalid cookie path: %s", c.Path)
+		}
+		b.WriteString("; Path=" + c.Path)
+	}
+	if c.Domain != "" {
+		if strings.ContainsAny(c.Domain, "; \r\n") {
+			return "", fmt.Errorf("invalid cookie domain: %s", c.Domain)
+		}
+		b.WriteString("; Domain=" + strings.TrimPrefix(c.Domain, "."))
+	}
+	if !c.Expires.IsZero() {
+		b.WriteString("; Expires=" + c.Expires.UTC().Format(timeFormat))
+	}
+	if c.MaxAge > 0 {
+		b.WriteString("; Max-Age=" + strconv.Itoa(c.MaxAge))
+	} else if c.MaxAge < 0 {
+		b.WriteString("; Max-Age=0") // expire the cookie immediately
+	}
+	if c.Secure {
+		b.WriteString("; Secure")
+	}
+	if c.HttpOnly {
+		b.WriteString("; HttpOnly")
+	}
+	switch c.SameSite {
+	case SameSiteLax:
+		b.WriteString("; SameSite=Lax")
+	case SameSiteStrict:
+		b.WriteString("; SameSite=Strict")
+	case SameSiteNone:
+		b.WriteString("; SameSite=None")
+	}
+	return b.String(), nil
+}
+
+// validateName validates the cookie name, which must be a token as in RFC 9110 Section 5.6.2.
+func validateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("invalid empty cookie name")
+	}
+	for _, r := range name {
+		if !isTokenChar(r) {
+			return fmt.Errorf("invalid characters in cookie name: '%s'", name)
+		}
+	}
+	return nil
+}
+
+// parseValue validates the cookie value and strips the optional surrounding
+// double quotes, based on the cookie-value grammar in RFC 6265 Section 4.1.1.
+func parseValue(value string) (string, error) {
+	if len(value) > 1 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	for _, r := range value {
+		// cookie-octet excludes CTLs, whitespace, DQUOTE, comma, semicolon, and backslash
+		if r <= 0x20 || r >= 0x7f || r == '"' || r == ',' || r == ';' || r == '\\' {
+			return "", fmt.Errorf("invalid characters in cookie value: '%s'", value)
+		}
+	}
+	return value, nil
+}
+
+func isTokenChar(r rune) bool {
+	if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+}
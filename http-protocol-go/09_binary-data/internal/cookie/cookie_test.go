@@ -0,0 +1,101 @@
+package cookie
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	// Test: Valid single cookie
+	cookies, err := Parse("session=abc123")
+	require.NoError(t, err)
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "session", cookies[0].Name)
+	assert.Equal(t, "abc123", cookies[0].Value)
+
+	// Test: Valid multiple cookies with quoted and empty values
+	cookies, err = Parse(`session=abc123; theme="dark"; empty=`)
+	require.NoError(t, err)
+	require.Len(t, cookies, 3)
+	assert.Equal(t, "theme", cookies[1].Name)
+	assert.Equal(t, "dark", cookies[1].Value)
+	assert.Equal(t, "empty", cookies[2].Name)
+	assert.Equal(t, "", cookies[2].Value)
+
+	// Test: Valid folded duplicate Cookie headers
+	cookies, err = Parse("a=1; b=2, c=3")
+	require.NoError(t, err)
+	require.Len(t, cookies, 3)
+	assert.Equal(t, "c", cookies[2].Name)
+
+	// Test: Invalid pair without equal sign
+	_, err = Parse("session")
+	require.Error(t, err)
+
+	// Test: Invalid cookie name
+	_, err = Parse("se(ss)ion=abc")
+	require.Error(t, err)
+
+	// Test: Invalid cookie value
+	_, err = Parse(`session=ab"c`)
+	require.Error(t, err)
+
+	// Test: Invalid pairs are skipped, valid ones around them are kept
+	cookies, err = Parse(`a=1; broken; se(ss)ion=x; b=2; c=ab"c, d=4`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken")
+	require.Len(t, cookies, 3)
+	assert.Equal(t, "a", cookies[0].Name)
+	assert.Equal(t, "b", cookies[1].Name)
+	assert.Equal(t, "d", cookies[2].Name)
+	assert.Equal(t, "4", cookies[2].Value)
+}
+
+func TestSerialize(t *testing.T) {
+	// Test: Valid cookie without attributes
+	c := &Cookie{Name: "session", Value: "abc123"}
+	s, err := c.Serialize()
+	require.NoError(t, err)
+	assert.Equal(t, "session=abc123", s)
+
+	// Test: Valid cookie with all attributes
+	c = &Cookie{
+		Name:     "session",
+		Value:    "abc123",
+		Path:     "/",
+		Domain:   ".example.com",
+		Expires:  time.Date(2025, time.January, 2, 3, 4, 5, 0, time.UTC),
+		MaxAge:   3600,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: SameSiteStrict,
+	}
+	s, err = c.Serialize()
+	require.NoError(t, err)
+	assert.Equal(t, "session=abc123; Path=/; Domain=example.com; "+
+		"Expires=Thu, 02 Jan 2025 03:04:05 GMT; Max-Age=3600; Secure; HttpOnly; SameSite=Strict", s)
+
+	// Test: Valid deletion cookie
+	c = &Cookie{Name: "session", MaxAge: -1, SameSite: SameSiteLax}
+	s, err = c.Serialize()
+	require.NoError(t, err)
+	assert.Equal(t, "session=; Max-Age=0; SameSite=Lax", s)
+
+	// Test: Invalid cookie name
+	c = &Cookie{Name: "", Value: "abc"}
+	_, err = c.Serialize()
+	require.Error(t, err)
+
+	// Test: Invalid cookie value
+	c = &Cookie{Name: "session", Value: "a;b"}
+	_, err = c.Serialize()
+	require.Error(t, err)
+
+	// Test: Invalid header injection via path
+	c = &Cookie{Name: "session", Value: "abc", Path: "/\r\nX-Evil: 1"}
+	_, err = c.Serialize()
+	require.Error(t, err)
+}
@@ -0,0 +1,173 @@
+package cors
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/server"
+)
+
+// Options configures the CORS middleware, based on the Fetch Standard Section 3.2.
+type Options struct {
+	// AllowedOrigins are exact origins (e.g., "https://example.com"), patterns
+	// with a single wildcard (e.g., "https://*.example.com"), or "*" for any.
+	AllowedOrigins []string
+	// AllowedMethods defaults to the CORS-safelisted GET, HEAD, and POST.
+	AllowedMethods []string
+	// AllowedHeaders are request headers allowed in preflight, "*" allows any.
+	AllowedHeaders []string
+	// ExposedHeaders are response headers the browser may expose to scripts.
+	ExposedHeaders []string
+	// AllowCredentials lets the browser send cookies and expose the response to
+	// listed origins. It has no effect with "*", which must not be trusted with
+	// credentials, based on the Fetch Standard Section 3.2.5.
+	AllowCredentials bool
+	// MaxAge is how long a preflight result may be cached, zero omits the header.
+	MaxAge time.Duration
+}
+
+// CORS is a middleware answering preflight requests and decorating actual
+// responses with Access-Control-* headers for allowed origins.
+type CORS struct {
+	opts Options
+}
+
+func New(opts Options) *CORS {
+	// Keep copies, so the caller's slices are neither normalized nor shared
+	opts.AllowedOrigins = slices.Clone(opts.AllowedOrigins)
+	opts.AllowedMethods = slices.Clone(opts.AllowedMethods)
+	opts.AllowedHeaders = slices.Clone(opts.AllowedHeaders)
+	opts.ExposedHeaders = slices.Clone(opts.ExposedHeaders)
+	if len(opts.AllowedMethods) == 0 {
+		opts.AllowedMethods = []string{"GET", "HEAD", "POST"}
+	}
+	for i, method := range opts.AllowedMethods {
+		opts.AllowedMethods[i] = strings.ToUpper(method)
+	}
+	for i, header := range opts.AllowedHeaders {
+		opts.AllowedHeaders[i] = strings.ToLower(header)
+	}
+	return &CORS{opts: opts}
+}
+
+// Wrap returns a handler that applies CORS before calling next.
+func (c *CORS) Wrap(next server.Handler) server.Handler {
+	return func(w *response.Writer, req *request.Request) {
+		// Responses differ per Origin unless any origin gets the same "*" answer
+		if !c.isWildcard() {
+			w.SetHeader("Vary", "Origin")
+		}
+		origin, found := req.Headers.Get("origin")
+		if !found {
+			next(w, req) // not a CORS request
+			return
+		}
+
+		reqMethod, isPreflight := req.Headers.Get("access-control-request-method")
+		if req.RequestLine.Method == "OPTIONS" && isPreflight {
+			c.preflight(w, req, origin, reqMethod)
+			return
+		}
+
+		if c.isOriginAllowed(origin) {
+			c.setOriginHeaders(w, origin)
+			if len(c.opts.ExposedHeaders) > 0 {
+				w.SetHeader("Access-Control-Expose-Headers", strings.Join(c.opts.ExposedHeaders, ", "))
+			}
+		}
+		next(w, req)
+	}
+}
+
+// preflight answers a preflight request without calling the wrapped handler.
+// A disallowed preflight gets no CORS headers, which the browser treats as failure.
+func (c *CORS) preflight(w *response.Writer, req *request.Request, origin, reqMethod string) {
+	h := headers.NewHeaders()
+	h.Set("Vary", "Access-Control-Request-Method, Access-Control-Request-Headers")
+	h.Set("Connection", "close")
+
+	reqHeaders := parseList(req.Headers["access-control-request-headers"])
+	if c.isOriginAllowed(origin) && c.isMethodAllowed(reqMethod) && c.areHeadersAllowed(reqHeaders) {
+		c.setOriginHeaders(w, origin)
+		h.Set("Access-Control-Allow-Methods", strings.Join(c.opts.AllowedMethods, ", "))
+		if len(reqHeaders) > 0 {
+			// Echo the requested headers, which also covers the "*" configuration
+			h.Set("Access-Control-Allow-Headers", strings.Join(reqHeaders, ", "))
+		}
+		if c.opts.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.opts.MaxAge.Seconds())))
+		}
+	}
+	w.WriteStatusLine(response.StatusNoContent)
+	w.WriteHeaders(h)
+}
+
+func (c *CORS) setOriginHeaders(w *response.Writer, origin string) {
+	if c.isWildcard() {
+		w.SetHeader("Access-Control-Allow-Origin", "*")
+		return
+	}
+	// Credentialed requests must get the exact origin, never "*"
+	w.SetHeader("Access-Control-Allow-Origin", origin)
+	if c.opts.AllowCredentials {
+		w.SetHeader("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// isWildcard reports whether every origin gets the same "*" response, without credentials.
+func (c *CORS) isWildcard() bool {
+	return slices.Contains(c.opts.AllowedOrigins, "*")
+}
+
+func (c *CORS) isOriginAllowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range c.opts.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		prefix, suffix, found := strings.Cut(strings.ToLower(allowed), "*")
+		if !found || len(origin) <= len(prefix)+len(suffix) {
+			continue
+		}
+		if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			// The wildcard stands for subdomain labels only, not scheme or port
+			middle := origin[len(prefix) : len(origin)-len(suffix)]
+			if !strings.ContainsAny(middle, "/:") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *CORS) isMethodAllowed(method string) bool {
+	return slices.Contains(c.opts.AllowedMethods, strings.ToUpper(method))
+}
+
+func (c *CORS) areHeadersAllowed(reqHeaders []string) bool {
+	if slices.Contains(c.opts.AllowedHeaders, "*") {
+		return true
+	}
+	for _, header := range reqHeaders {
+		if !slices.Contains(c.opts.AllowedHeaders, header) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseList splits a comma-separated header value into lowercase elements.
+func parseList(s string) []string {
+	list := make([]string, 0)
+	for part := range strings.SplitSeq(s, ",") {
+		if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
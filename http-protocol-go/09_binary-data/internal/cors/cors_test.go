@@ -0,0 +1,128 @@
+package cors
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// okHandler responds with 200 and a fixed body.
+func okHandler(w *response.Writer, req *request.Request) {
+	body := []byte("ok")
+	w.WriteStatusLine(response.StatusOK)
+	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	w.WriteBody(body)
+}
+
+// serveString runs the handler against a raw request and returns the raw response.
+func serveString(t *testing.T, handler server.Handler, raw string) string {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader(raw))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	handler(response.NewWriter(&buf), req)
+	return buf.String()
+}
+
+func TestCORS(t *testing.T) {
+	handler := New(Options{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowedHeaders:   []string{"Content-Type", "X-Token"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}).Wrap(okHandler)
+
+	// Test: Non-CORS request passes through with Vary
+	resp := serveString(t, handler, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\nok"))
+	assert.Contains(t, resp, "vary: Origin\r\n")
+	assert.NotContains(t, resp, "access-control-allow-origin")
+
+	// Test: Actual request from an exact allowed origin
+	resp = serveString(t, handler, "GET / HTTP/1.1\r\nHost: localhost\r\nOrigin: https://app.example.com\r\n\r\n")
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\nok"))
+	assert.Contains(t, resp, "access-control-allow-origin: https://app.example.com\r\n")
+	assert.Contains(t, resp, "access-control-allow-credentials: true\r\n")
+	assert.Contains(t, resp, "access-control-expose-headers: X-Request-Id\r\n")
+
+	// Test: Actual request from a pattern allowed origin
+	resp = serveString(t, handler, "GET / HTTP/1.1\r\nHost: localhost\r\nOrigin: https://a.b.example.org\r\n\r\n")
+	assert.Contains(t, resp, "access-control-allow-origin: https://a.b.example.org\r\n")
+
+	// Test: Actual request from a disallowed origin still reaches the handler
+	resp = serveString(t, handler, "GET / HTTP/1.1\r\nHost: localhost\r\nOrigin: https://evil.com\r\n\r\n")
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\nok"))
+	assert.NotContains(t, resp, "access-control-allow-origin")
+
+	// Test: Pattern does not match across scheme or port
+	resp = serveString(t, handler, "GET / HTTP/1.1\r\nHost: localhost\r\nOrigin: https://evil.com:1/.example.org\r\n\r\n")
+	assert.NotContains(t, resp, "access-control-allow-origin")
+
+	// Test: Allowed preflight
+	resp = serveString(t, handler, "OPTIONS /items HTTP/1.1\r\nHost: localhost\r\n"+
+		"Origin: https://app.example.com\r\n"+
+		"Access-Control-Request-Method: PUT\r\n"+
+		"Access-Control-Request-Headers: content-type, x-token\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 204 No Content\r\n"))
+	assert.Contains(t, resp, "access-control-allow-origin: https://app.example.com\r\n")
+	assert.Contains(t, resp, "access-control-allow-methods: GET, PUT\r\n")
+	assert.Contains(t, resp, "access-control-allow-headers: content-type, x-token\r\n")
+	assert.Contains(t, resp, "access-control-max-age: 600\r\n")
+	assert.Contains(t, resp, "vary: Origin, Access-Control-Request-Method, Access-Control-Request-Headers\r\n")
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\n"))
+
+	// Test: Preflight with disallowed method
+	resp = serveString(t, handler, "OPTIONS /items HTTP/1.1\r\nHost: localhost\r\n"+
+		"Origin: https://app.example.com\r\n"+
+		"Access-Control-Request-Method: DELETE\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 204 No Content\r\n"))
+	assert.NotContains(t, resp, "access-control-allow-origin")
+
+	// Test: Preflight with disallowed header
+	resp = serveString(t, handler, "OPTIONS /items HTTP/1.1\r\nHost: localhost\r\n"+
+		"Origin: https://app.example.com\r\n"+
+		"Access-Control-Request-Method: GET\r\n"+
+		"Access-Control-Request-Headers: x-secret\r\n\r\n")
+	assert.NotContains(t, resp, "access-control-allow-origin")
+
+	// Test: Wildcard origin without credentials
+	handler = New(Options{AllowedOrigins: []string{"*"}}).Wrap(okHandler)
+	resp = serveString(t, handler, "GET / HTTP/1.1\r\nHost: localhost\r\nOrigin: https://any.com\r\n\r\n")
+	assert.Contains(t, resp, "access-control-allow-origin: *\r\n")
+	assert.NotContains(t, resp, "vary")
+
+	// Test: Wildcard origin never sends credentials nor echoes the origin
+	handler = New(Options{AllowedOrigins: []string{"*"}, AllowCredentials: true}).Wrap(okHandler)
+	resp = serveString(t, handler, "GET / HTTP/1.1\r\nHost: localhost\r\nOrigin: https://evil.com\r\n\r\n")
+	assert.Contains(t, resp, "access-control-allow-origin: *\r\n")
+	assert.NotContains(t, resp, "https://evil.com")
+	assert.NotContains(t, resp, "access-control-allow-credentials")
+	resp = serveString(t, handler, "OPTIONS / HTTP/1.1\r\nHost: localhost\r\n"+
+		"Origin: https://evil.com\r\n"+
+		"Access-Control-Request-Method: GET\r\n\r\n")
+	assert.Contains(t, resp, "access-control-allow-origin: *\r\n")
+	assert.NotContains(t, resp, "access-control-allow-credentials")
+
+	// Test: Options given are left as they are, and later changes have no effect
+	methods := []string{"get", "put"}
+	headers := []string{"Content-Type", "X-Token"}
+	handler = New(Options{AllowedOrigins: []string{"*"}, AllowedMethods: methods, AllowedHeaders: headers}).Wrap(okHandler)
+	assert.Equal(t, []string{"get", "put"}, methods)
+	assert.Equal(t, []string{"Content-Type", "X-Token"}, headers)
+	methods[0], headers[0] = "delete", "x-other"
+	resp = serveString(t, handler, "OPTIONS / HTTP/1.1\r\nHost: localhost\r\n"+
+		"Origin: https://any.com\r\n"+
+		"Access-Control-Request-Method: GET\r\n"+
+		"Access-Control-Request-Headers: content-type\r\n\r\n")
+	assert.Contains(t, resp, "access-control-allow-methods: GET, PUT\r\n")
+	assert.Contains(t, resp, "access-control-allow-headers: content-type\r\n")
+}
@@ -75,3 +75,52 @@ func TestHeadersParse(t *testing.T) {
 	assert.Equal(t, 0, n)
 	assert.False(t, done)
 }
+
+func TestTrailers(t *testing.T) {
+	// Test: Declared trailers are lowercased and trimmed
+	h := NewHeaders()
+	h.Set("Trailer", "X-Content-SHA256, X-Content-Length ,")
+	declared, err := h.DeclaredTrailers()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x-content-sha256", "x-content-length"}, declared)
+
+	// Test: No Trailer header
+	declared, err = NewHeaders().DeclaredTrailers()
+	require.NoError(t, err)
+	assert.Empty(t, declared)
+
+	// Test: Forbidden field declared
+	h = NewHeaders()
+	h.Set("Trailer", "X-Sum, Content-Length")
+	_, err = h.DeclaredTrailers()
+	require.Error(t, err)
+
+	// Test: Received trailers must be declared and allowed
+	trailers := NewHeaders()
+	trailers.Set("X-Sum", "abc")
+	require.NoError(t, ValidateTrailers([]string{"x-sum"}, trailers))
+	require.Error(t, ValidateTrailers([]string{}, trailers))
+	trailers.Set("Host", "example.com")
+	require.Error(t, ValidateTrailers([]string{"x-sum", "host"}, trailers))
+}
+
+func TestVerifyContentSHA256(t *testing.T) {
+	body := []byte("hello")
+	sum := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	// Test: Matching hash and length
+	trailers := NewHeaders()
+	trailers.Set(ContentSHA256Trailer, sum)
+	trailers.Set(ContentLengthTrailer, "5")
+	require.NoError(t, VerifyContentSHA256(body, trailers))
+
+	// Test: Hash mismatch
+	require.Error(t, VerifyContentSHA256([]byte("hellO"), trailers))
+
+	// Test: Length mismatch
+	trailers[ContentLengthTrailer] = "6"
+	require.Error(t, VerifyContentSHA256(body, trailers))
+
+	// Test: Missing hash trailer
+	require.Error(t, VerifyContentSHA256(body, NewHeaders()))
+}
@@ -0,0 +1,97 @@
+package headers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// forbiddenTrailers are fields that must not be sent in a trailer section, since
+// they are needed before the content is processed, based on RFC 9110 Section 6.5.1.
+var forbiddenTrailers = []string{
+	// message framing
+	"content-length", "transfer-encoding", "trailer",
+	// routing and request modifiers
+	"host", "cache-control", "expect", "max-forwards", "pragma", "range", "te",
+	"if-match", "if-none-match", "if-modified-since", "if-unmodified-since", "if-range",
+	// authentication and cookies
+	"authorization", "proxy-authorization", "www-authenticate", "proxy-authenticate",
+	"cookie", "set-cookie",
+	// response control data
+	"age", "date", "expires", "location", "retry-after", "vary", "warning",
+	// content processing
+	"content-encoding", "content-type", "content-range",
+}
+
+// Integrity trailers sent by our chunked handlers, e.g., httpbinHandler.
+const (
+	ContentSHA256Trailer = "x-content-sha256"
+	ContentLengthTrailer = "x-content-length"
+)
+
+// IsForbiddenTrailer reports whether the field must not be sent as a trailer.
+func IsForbiddenTrailer(key string) bool {
+	return slices.Contains(forbiddenTrailers, strings.ToLower(key))
+}
+
+// DeclaredTrailers returns the lowercase field names listed in the Trailer
+// header, and an error if any of them is forbidden in trailers.
+func (h Headers) DeclaredTrailers() ([]string, error) {
+	declared := make([]string, 0)
+	val, found := h.Get("trailer")
+	if !found {
+		return declared, nil
+	}
+	for name := range strings.SplitSeq(val, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		key, err := parseHeaderKey([]byte(name))
+		if err != nil {
+			return nil, err
+		}
+		if IsForbiddenTrailer(key) {
+			return nil, fmt.Errorf("forbidden trailer field declared: %s", key)
+		}
+		declared = append(declared, key)
+	}
+	return declared, nil
+}
+
+// ValidateTrailers checks that every received trailer field was declared,
+// and that none of them is forbidden.
+func ValidateTrailers(declared []string, trailers Headers) error {
+	for key := range trailers {
+		if IsForbiddenTrailer(key) {
+			return fmt.Errorf("forbidden trailer field: %s", key)
+		}
+		if !slices.Contains(declared, key) {
+			return fmt.Errorf("undeclared trailer field: %s", key)
+		}
+	}
+	return nil
+}
+
+// VerifyContentSHA256 checks the body against the X-Content-SHA256 trailer,
+// and the X-Content-Length trailer if present.
+func VerifyContentSHA256(body []byte, trailers Headers) error {
+	want, found := trailers.Get(ContentSHA256Trailer)
+	if !found {
+		return fmt.Errorf("missing %s trailer", ContentSHA256Trailer)
+	}
+	if length, found := trailers.Get(ContentLengthTrailer); found {
+		num, err := strconv.Atoi(length)
+		if err != nil || num != len(body) {
+			return fmt.Errorf("content length mismatch: trailer says %s, received %d", length, len(body))
+		}
+	}
+	sum := sha256.Sum256(body)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, want) {
+		return fmt.Errorf("content sha256 mismatch: trailer says %s, received %s", want, got)
+	}
+	return nil
+}
@@ -0,0 +1,647 @@
+package http2
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/client"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+)
+
+// Handler is the signature of server.Handler, which cannot be imported here.
+type Handler func(w *response.Writer, req *request.Request)
+
+// ConnOptions configures a connection served by ServeConn.
+type ConnOptions struct {
+	// Done, once closed, makes the connection send GOAWAY, wait for the
+	// handlers in flight, and close.
+	Done <-chan struct{}
+	// Upgrade is a request that asked to upgrade to h2c and was answered with
+	// 101, which becomes stream 1. Settings are from its HTTP2-Settings header.
+	Upgrade  *request.Request
+	Settings []Setting
+	// Logger logs errors of the connection, defaults to slog.Default().
+	Logger *slog.Logger
+	// MaxBodySize caps request bodies, which are buffered whole, defaults to
+	// 10 MiB. Larger ones are answered with 413 and the stream is reset.
+	MaxBodySize int
+}
+
+const (
+	maxConcurrentStreams = 100      // SETTINGS_MAX_CONCURRENT_STREAMS we advertise
+	defaultMaxBodySize   = 10 << 20 // of a request
+)
+
+var errStreamClosed = errors.New("stream closed")
+
+// serverConn is the server side of an HTTP/2 connection. Frames are read by a
+// single loop, and each stream is handled in its own goroutine once its
+// request is complete. The handler writes an HTTP/1.1 response to a buffer,
+// which is then translated to frames, so responses are not streamed.
+type serverConn struct {
+	conn    net.Conn
+	r       io.Reader
+	handler Handler
+	dec     *Decoder // only used by the read loop
+	enc     Encoder
+	wmu     sync.Mutex // serializes frame writes
+	stop    chan struct{}
+	logger  *slog.Logger
+	// maxBodySize caps each request body
+	maxBodySize int
+
+	// header block spanning HEADERS and CONTINUATION frames, only used by the read loop
+	continuation *Frame
+
+	mu            sync.Mutex
+	cond          *sync.Cond // broadcast when send windows grow, or streams or the connection end
+	streams       map[uint32]*stream
+	lastStreamID  uint32 // highest stream opened by the peer
+	sendWindow    int32  // of the connection
+	initialWindow int32  // peer SETTINGS_INITIAL_WINDOW_SIZE, for new streams
+	maxFrameSize  uint32 // peer SETTINGS_MAX_FRAME_SIZE
+	running       int    // handlers in flight
+	goingAway     bool
+	closed        bool
+}
+
+type streamState int
+
+const (
+	stateOpen             streamState = iota // receiving the request
+	stateHalfClosedRemote                    // request received, sending the response
+)
+
+type stream struct {
+	id         uint32
+	state      streamState // only used by the read loop
+	req        *request.Request
+	sendWindow int32
+	recvWindow int  // only used by the read loop
+	reset      bool // by either side, nothing more is sent
+}
+
+// ServeConn serves HTTP/2 on the connection, reading from r, which may hold
+// bytes already read from the connection, until the peer closes it or a
+// connection error. The client preface must not have been consumed yet.
+func ServeConn(conn net.Conn, r io.Reader, handler Handler, opts ConnOptions) error {
+	sc := &serverConn{
+		conn:          conn,
+		r:             r,
+		handler:       handler,
+		dec:           NewDecoder(),
+		stop:          make(chan struct{}),
+		streams:       make(map[uint32]*stream),
+		sendWindow:    defaultWindowSize,
+		initialWindow: defaultWindowSize,
+		maxFrameSize:  defaultMaxFrameSize,
+		logger:        opts.Logger,
+		maxBodySize:   opts.MaxBodySize,
+	}
+	if sc.logger == nil {
+		sc.logger = slog.Default()
+	}
+	if sc.maxBodySize <= 0 {
+		sc.maxBodySize = defaultMaxBodySize
+	}
+	sc.cond = sync.NewCond(&sc.mu)
+	for _, s := range opts.Settings {
+		sc.applySetting(s)
+	}
+	err := sc.serve(opts)
+
+	// Release writers waiting on flow control, then wait for the handlers
+	sc.mu.Lock()
+	sc.closed = true
+	close(sc.stop)
+	sc.cond.Broadcast()
+	for sc.running > 0 {
+		sc.cond.Wait()
+	}
+	sc.mu.Unlock()
+	return err
+}
+
+func (sc *serverConn) serve(opts ConnOptions) error {
+	// The server preface is a SETTINGS frame, based on RFC 9113 Section 3.4
+	settings := AppendSettings(nil, Setting{SettingMaxConcurrentStreams, maxConcurrentStreams})
+	if err := sc.writeFrame(&Frame{Type: FrameSettings, Payload: settings}); err != nil {
+		return err
+	}
+	preface := make([]byte, len(ClientPreface))
+	if _, err := io.ReadFull(sc.r, preface); err != nil {
+		return err
+	}
+	if string(preface) != ClientPreface {
+		return fmt.Errorf("invalid client preface %q", preface)
+	}
+	if opts.Upgrade != nil {
+		// The upgrade request was fully read, so stream 1 is already half-closed
+		st := &stream{id: 1, req: opts.Upgrade, sendWindow: sc.initialWindow}
+		sc.mu.Lock()
+		sc.streams[1] = st
+		sc.lastStreamID = 1
+		sc.mu.Unlock()
+		sc.dispatch(st)
+	}
+	if opts.Done != nil {
+		go sc.watch(opts.Done)
+	}
+
+	for first := true; ; first = false {
+		f, err := ReadFrame(sc.r, defaultMaxFrameSize)
+		if err == nil && first && (f.Type != FrameSettings || f.Has(FlagAck)) {
+			err = ConnError{ErrCodeProtocol, "client preface must end with SETTINGS"}
+		}
+		if err == nil {
+			err = sc.processFrame(f)
+		}
+		var streamErr StreamError
+		var connErr ConnError
+		switch {
+		case err == nil:
+		case errors.As(err, &streamErr):
+			sc.resetStream(streamErr)
+		case errors.As(err, &connErr):
+			sc.goAway(connErr.Code, connErr.Reason)
+			return err
+		case errors.Is(err, io.EOF), errors.Is(err, net.ErrClosed), errors.Is(err, io.ErrClosedPipe):
+			return nil // closed by the peer, or by watch
+		default:
+			return err
+		}
+	}
+}
+
+// watch shuts the connection down gracefully once done is closed.
+func (sc *serverConn) watch(done <-chan struct{}) {
+	select {
+	case <-done:
+	case <-sc.stop:
+		return
+	}
+	sc.goAway(ErrCodeNo, "server shutting down")
+	sc.mu.Lock()
+	for sc.running > 0 && !sc.closed {
+		sc.cond.Wait()
+	}
+	sc.mu.Unlock()
+	sc.conn.Close() // ends the read loop
+}
+
+func (sc *serverConn) processFrame(f *Frame) error {
+	// A header block must not be interleaved with other frames, see RFC 9113 Section 4.3
+	if sc.continuation != nil && (f.Type != FrameContinuation || f.StreamID != sc.continuation.StreamID) {
+		return ConnError{ErrCodeProtocol, "expected CONTINUATION"}
+	}
+	switch f.Type {
+	case FrameData:
+		return sc.processData(f)
+	case FrameHeaders:
+		return sc.processHeaders(f)
+	case FrameContinuation:
+		return sc.processContinuation(f)
+	case FramePriority:
+		if f.StreamID == 0 {
+			return ConnError{ErrCodeProtocol, "PRIORITY on stream 0"}
+		}
+		if len(f.Payload) != 5 {
+			return StreamError{f.StreamID, ErrCodeFrameSize, "PRIORITY payload must be 5 bytes"}
+		}
+		return nil // deprecated by RFC 9113, and we do not prioritize
+	case FrameRSTStream:
+		return sc.processRSTStream(f)
+	case FrameSettings:
+		return sc.processSettings(f)
+	case FramePushPromise:
+		return ConnError{ErrCodeProtocol, "clients cannot push"}
+	case FramePing:
+		return sc.processPing(f)
+	case FrameGoAway:
+		if f.StreamID != 0 {
+			return ConnError{ErrCodeProtocol, "GOAWAY on a stream"}
+		}
+		return nil // finish the streams in flight, the peer then closes the connection
+	case FrameWindowUpdate:
+		return sc.processWindowUpdate(f)
+	default:
+		return nil // unknown frame types must be ignored
+	}
+}
+
+func (sc *serverConn) processHeaders(f *Frame) error {
+	if f.StreamID == 0 {
+		return ConnError{ErrCodeProtocol, "HEADERS on stream 0"}
+	}
+	block, err := f.data()
+	if err != nil {
+		return err
+	}
+	if !f.Has(FlagEndHeaders) {
+		sc.continuation = &Frame{Type: FrameHeaders, Flags: f.Flags, StreamID: f.StreamID, Payload: slices.Clone(block)}
+		return nil
+	}
+	return sc.processHeaderBlock(f.StreamID, f.Has(FlagEndStream), block)
+}
+
+func (sc *serverConn) processContinuation(f *Frame) error {
+	c := sc.continuation
+	if c == nil {
+		return ConnError{ErrCodeProtocol, "unexpected CONTINUATION"}
+	}
+	c.Payload = append(c.Payload, f.Payload...)
+	if len(c.Payload) > maxHeaderListSize {
+		return ConnError{ErrCodeEnhanceYourCalm, "header block too large"}
+	}
+	if !f.Has(FlagEndHeaders) {
+		return nil
+	}
+	sc.continuation = nil
+	return sc.processHeaderBlock(c.StreamID, c.Has(FlagEndStream), c.Payload)
+}
+
+// processHeaderBlock opens a stream with the request headers, or ends an open
+// stream with trailers, based on RFC 9113 Section 8.1.
+func (sc *serverConn) processHeaderBlock(id uint32, endStream bool, block []byte) error {
+	// Decode even if the stream is refused, to keep the HPACK state in sync
+	fields, err := sc.dec.Decode(block)
+	if err != nil {
+		return ConnError{ErrCodeCompression, err.Error()}
+	}
+
+	sc.mu.Lock()
+	st := sc.streams[id]
+	lastStreamID, goingAway, active := sc.lastStreamID, sc.goingAway, len(sc.streams)
+	sc.mu.Unlock()
+	if st != nil {
+		if st.state != stateOpen {
+			return StreamError{id, ErrCodeStreamClosed, "HEADERS on a half-closed stream"}
+		}
+		if !endStream {
+			return StreamError{id, ErrCodeProtocol, "trailers without END_STREAM"}
+		}
+		if st.req.Trailers, err = newTrailers(st.req, fields); err != nil {
+			return StreamError{id, ErrCodeProtocol, err.Error()}
+		}
+		return sc.endStream(st)
+	}
+
+	if id%2 == 0 {
+		return ConnError{ErrCodeProtocol, "client streams must be odd"}
+	}
+	if id <= lastStreamID {
+		return ConnError{ErrCodeStreamClosed, "HEADERS on a closed stream"}
+	}
+	sc.mu.Lock()
+	sc.lastStreamID = id
+	sc.mu.Unlock()
+	if goingAway || active >= maxConcurrentStreams {
+		return StreamError{id, ErrCodeRefusedStream, "too many streams"}
+	}
+	req, err := newRequest(fields)
+	if err != nil {
+		return StreamError{id, ErrCodeProtocol, err.Error()}
+	}
+	req.RemoteAddr = sc.conn.RemoteAddr().String()
+	st = &stream{id: id, state: stateOpen, req: req, recvWindow: defaultWindowSize}
+	sc.mu.Lock()
+	st.sendWindow = sc.initialWindow
+	sc.streams[id] = st
+	sc.mu.Unlock()
+	if endStream {
+		return sc.endStream(st)
+	}
+	return nil
+}
+
+func (sc *serverConn) processData(f *Frame) error {
+	if f.StreamID == 0 {
+		return ConnError{ErrCodeProtocol, "DATA on stream 0"}
+	}
+	data, err := f.data()
+	if err != nil {
+		return err
+	}
+	// The connection window is replenished right away, since each stream is
+	// bounded by its own window below, even for frames that are discarded
+	if err := sc.windowUpdate(0, len(f.Payload)); err != nil {
+		return err
+	}
+
+	sc.mu.Lock()
+	st := sc.streams[f.StreamID]
+	lastStreamID := sc.lastStreamID
+	sc.mu.Unlock()
+	if st == nil || st.state != stateOpen {
+		if f.StreamID > lastStreamID {
+			return ConnError{ErrCodeProtocol, "DATA on an idle stream"}
+		}
+		return StreamError{f.StreamID, ErrCodeStreamClosed, "DATA on a closed stream"}
+	}
+	if len(st.req.Body)+len(data) > sc.maxBodySize {
+		// A complete response ends the stream early, then the client is told to
+		// stop sending, based on RFC 9113 Section 8.1
+		sc.writeHeaders(st, []HeaderField{{":status", strconv.Itoa(int(response.StatusContentTooLarge))}}, true)
+		return StreamError{f.StreamID, ErrCodeNo, "request body too large"}
+	}
+	st.req.Body = append(st.req.Body, data...)
+	if f.Has(FlagEndStream) {
+		return sc.endStream(st)
+	}
+	// Replenish the stream window only up to the rest of the body allowed, so
+	// flow control stops a client from sending past the cap
+	st.recvWindow -= len(f.Payload)
+	increment := min(len(f.Payload), sc.maxBodySize-len(st.req.Body)-st.recvWindow)
+	if increment <= 0 {
+		return nil
+	}
+	st.recvWindow += increment
+	return sc.windowUpdate(f.StreamID, increment)
+}
+
+// endStream checks the complete request, and hands it to the handler.
+func (sc *serverConn) endStream(st *stream) error {
+	// A Content-Length that does not match makes the request malformed, see RFC 9113 Section 8.1.1
+	if cl, found := st.req.Headers.Get("content-length"); found && cl != strconv.Itoa(len(st.req.Body)) {
+		return StreamError{st.id, ErrCodeProtocol, "content-length does not match the body"}
+	}
+	sc.dispatch(st)
+	return nil
+}
+
+func (sc *serverConn) dispatch(st *stream) {
+	st.state = stateHalfClosedRemote
+	sc.mu.Lock()
+	sc.running++
+	sc.mu.Unlock()
+	go sc.runHandler(st)
+}
+
+func (sc *serverConn) processRSTStream(f *Frame) error {
+	if f.StreamID == 0 {
+		return ConnError{ErrCodeProtocol, "RST_STREAM on stream 0"}
+	}
+	if len(f.Payload) != 4 {
+		return ConnError{ErrCodeFrameSize, "RST_STREAM payload must be 4 bytes"}
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if f.StreamID > sc.lastStreamID {
+		return ConnError{ErrCodeProtocol, "RST_STREAM on an idle stream"}
+	}
+	sc.closeStream(f.StreamID)
+	return nil
+}
+
+func (sc *serverConn) processSettings(f *Frame) error {
+	if f.StreamID != 0 {
+		return ConnError{ErrCodeProtocol, "SETTINGS on a stream"}
+	}
+	if f.Has(FlagAck) {
+		if len(f.Payload) != 0 {
+			return ConnError{ErrCodeFrameSize, "SETTINGS ack with a payload"}
+		}
+		return nil
+	}
+	settings, err := ParseSettings(f.Payload)
+	if err != nil {
+		return err
+	}
+	sc.mu.Lock()
+	for _, s := range settings {
+		if err := sc.applySetting(s); err != nil {
+			sc.mu.Unlock()
+			return err
+		}
+	}
+	sc.cond.Broadcast()
+	sc.mu.Unlock()
+	return sc.writeFrame(&Frame{Type: FrameSettings, Flags: FlagAck})
+}
+
+// applySetting applies a validated setting of the peer. Settings we do not use,
+// e.g., SETTINGS_HEADER_TABLE_SIZE since the encoder has no dynamic table,
+// are ignored. Caller must hold the lock, unless the connection is not served yet.
+func (sc *serverConn) applySetting(s Setting) error {
+	switch s.ID {
+	case SettingInitialWindowSize:
+		// Changes apply to the windows of all streams, see RFC 9113 Section 6.9.2
+		delta := int64(s.Value) - int64(sc.initialWindow)
+		for _, st := range sc.streams {
+			if int64(st.sendWindow)+delta > maxWindowSize {
+				return ConnError{ErrCodeFlowControl, "stream window overflow"}
+			}
+			st.sendWindow += int32(delta)
+		}
+		sc.initialWindow = int32(s.Value)
+	case SettingMaxFrameSize:
+		sc.maxFrameSize = s.Value
+	}
+	return nil
+}
+
+func (sc *serverConn) processPing(f *Frame) error {
+	if f.StreamID != 0 {
+		return ConnError{ErrCodeProtocol, "PING on a stream"}
+	}
+	if len(f.Payload) != 8 {
+		return ConnError{ErrCodeFrameSize, "PING payload must be 8 bytes"}
+	}
+	if f.Has(FlagAck) {
+		return nil
+	}
+	return sc.writeFrame(&Frame{Type: FramePing, Flags: FlagAck, Payload: f.Payload})
+}
+
+func (sc *serverConn) processWindowUpdate(f *Frame) error {
+	if len(f.Payload) != 4 {
+		return ConnError{ErrCodeFrameSize, "WINDOW_UPDATE payload must be 4 bytes"}
+	}
+	increment := int64(binary.BigEndian.Uint32(f.Payload) & maxWindowSize)
+	if increment == 0 {
+		if f.StreamID == 0 {
+			return ConnError{ErrCodeProtocol, "WINDOW_UPDATE of 0"}
+		}
+		return StreamError{f.StreamID, ErrCodeProtocol, "WINDOW_UPDATE of 0"}
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	defer sc.cond.Broadcast()
+	if f.StreamID == 0 {
+		if int64(sc.sendWindow)+increment > maxWindowSize {
+			return ConnError{ErrCodeFlowControl, "connection window overflow"}
+		}
+		sc.sendWindow += int32(increment)
+		return nil
+	}
+	st := sc.streams[f.StreamID]
+	if st == nil {
+		if f.StreamID > sc.lastStreamID {
+			return ConnError{ErrCodeProtocol, "WINDOW_UPDATE on an idle stream"}
+		}
+		return nil // may arrive after the stream ended
+	}
+	if int64(st.sendWindow)+increment > maxWindowSize {
+		return StreamError{f.StreamID, ErrCodeFlowControl, "stream window overflow"}
+	}
+	st.sendWindow += int32(increment)
+	return nil
+}
+
+// runHandler runs the handler of the stream, and sends its response.
+func (sc *serverConn) runHandler(st *stream) {
+	defer func() {
+		sc.mu.Lock()
+		sc.closeStream(st.id)
+		sc.running--
+		sc.mu.Unlock()
+	}()
+
+	method := st.req.RequestLine.Method
+	var buf bytes.Buffer
+	w := response.NewWriter(&buf)
+	if method == "HEAD" {
+		w.DiscardBody()
+	}
+	// The response is buffered, but interim ones must arrive ahead of it
+	w.OnInterim(func(statusCode response.StatusCode, h headers.Headers) error {
+		return sc.writeHeaders(st, interimFields(statusCode, h), false)
+	})
+	sc.handler(w, st.req)
+
+	// The handler output is already in memory, so its body is not capped
+	resp, err := client.ReadResponseLimit(bufio.NewReader(&buf), method, 0)
+	if err != nil {
+		sc.logger.Error("translating HTTP/2 response", slog.Uint64("stream", uint64(st.id)), slog.Any("error", err))
+		sc.writeHeaders(st, []HeaderField{{":status", "500"}}, true)
+		return
+	}
+	endStream := len(resp.Body) == 0 && len(resp.Trailers) == 0
+	if err := sc.writeHeaders(st, responseFields(resp, w.Cookies()), endStream); err != nil || endStream {
+		return
+	}
+	if len(resp.Body) > 0 {
+		if err := sc.writeData(st, resp.Body, len(resp.Trailers) == 0); err != nil {
+			return
+		}
+	}
+	if len(resp.Trailers) > 0 {
+		sc.writeHeaders(st, trailerFields(resp.Trailers), true)
+	}
+}
+
+// closeStream forgets the stream, and stops its writer. Caller must hold the lock.
+func (sc *serverConn) closeStream(id uint32) {
+	if st, ok := sc.streams[id]; ok {
+		st.reset = true
+		delete(sc.streams, id)
+	}
+	sc.cond.Broadcast()
+}
+
+// writeHeaders sends the header list, split into CONTINUATION frames if larger than a frame.
+func (sc *serverConn) writeHeaders(st *stream, fields []HeaderField, endStream bool) error {
+	block := sc.enc.Encode(nil, fields)
+	sc.mu.Lock()
+	if st.reset || sc.closed {
+		sc.mu.Unlock()
+		return errStreamClosed
+	}
+	maxFrameSize := int(sc.maxFrameSize)
+	sc.mu.Unlock()
+
+	// Hold the write lock for the whole block, no frame may be interleaved
+	sc.wmu.Lock()
+	defer sc.wmu.Unlock()
+	f := &Frame{Type: FrameHeaders, StreamID: st.id}
+	if endStream {
+		f.Flags = FlagEndStream
+	}
+	for {
+		n := min(len(block), maxFrameSize)
+		f.Payload, block = block[:n], block[n:]
+		if len(block) == 0 {
+			f.Flags |= FlagEndHeaders
+		}
+		if err := WriteFrame(sc.conn, f); err != nil {
+			return err
+		}
+		if len(block) == 0 {
+			return nil
+		}
+		f = &Frame{Type: FrameContinuation, StreamID: st.id}
+	}
+}
+
+// writeData sends the body in DATA frames, waiting for the flow control
+// windows of the stream and connection, based on RFC 9113 Section 5.2.
+func (sc *serverConn) writeData(st *stream, body []byte, endStream bool) error {
+	for len(body) > 0 {
+		sc.mu.Lock()
+		for !st.reset && !sc.closed && (sc.sendWindow <= 0 || st.sendWindow <= 0) {
+			sc.cond.Wait()
+		}
+		if st.reset || sc.closed {
+			sc.mu.Unlock()
+			return errStreamClosed
+		}
+		n := min(len(body), int(sc.maxFrameSize), int(sc.sendWindow), int(st.sendWindow))
+		sc.sendWindow -= int32(n)
+		st.sendWindow -= int32(n)
+		sc.mu.Unlock()
+
+		f := &Frame{Type: FrameData, StreamID: st.id, Payload: body[:n]}
+		body = body[n:]
+		if endStream && len(body) == 0 {
+			f.Flags = FlagEndStream
+		}
+		if err := sc.writeFrame(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// windowUpdate gives back n bytes of receive window to the peer, for the stream or the connection if 0.
+func (sc *serverConn) windowUpdate(id uint32, n int) error {
+	if n == 0 {
+		return nil
+	}
+	return sc.writeFrame(&Frame{Type: FrameWindowUpdate, StreamID: id, Payload: binary.BigEndian.AppendUint32(nil, uint32(n))})
+}
+
+func (sc *serverConn) resetStream(e StreamError) {
+	sc.mu.Lock()
+	sc.closeStream(e.StreamID)
+	sc.mu.Unlock()
+	sc.writeFrame(&Frame{Type: FrameRSTStream, StreamID: e.StreamID, Payload: binary.BigEndian.AppendUint32(nil, uint32(e.Code))})
+}
+
+// goAway tells the peer the highest stream we process, and why we stop, based on RFC 9113 Section 6.8.
+func (sc *serverConn) goAway(code ErrCode, reason string) {
+	sc.mu.Lock()
+	sc.goingAway = true
+	lastStreamID := sc.lastStreamID
+	sc.mu.Unlock()
+	payload := binary.BigEndian.AppendUint32(nil, lastStreamID)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(code))
+	sc.writeFrame(&Frame{Type: FrameGoAway, Payload: append(payload, reason...)})
+}
+
+func (sc *serverConn) writeFrame(f *Frame) error {
+	sc.wmu.Lock()
+	defer sc.wmu.Unlock()
+	return WriteFrame(sc.conn, f)
+}
@@ -0,0 +1,558 @@
+package http2
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/cookie"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient drives a connection served by ServeConn, frame by frame.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	frames chan *Frame
+	served chan error // result of ServeConn
+	enc    Encoder
+	dec    *Decoder
+}
+
+// newTestClient serves a connection with the handler, and exchanges the
+// prefaces with the given client settings.
+func newTestClient(t *testing.T, handler Handler, opts ConnOptions, settings ...Setting) *testClient {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	c := &testClient{
+		t:      t,
+		conn:   clientConn,
+		frames: make(chan *Frame, 100),
+		served: make(chan error, 1),
+		dec:    NewDecoder(),
+	}
+	t.Cleanup(func() { clientConn.Close() })
+	go func() {
+		c.served <- ServeConn(serverConn, serverConn, handler, opts)
+		serverConn.Close()
+	}()
+	go func() {
+		defer close(c.frames)
+		for {
+			f, err := ReadFrame(clientConn, maxAllowedFrameSize)
+			if err != nil {
+				return
+			}
+			c.frames <- f
+		}
+	}()
+
+	_, err := clientConn.Write([]byte(ClientPreface))
+	require.NoError(t, err)
+	c.write(&Frame{Type: FrameSettings, Payload: AppendSettings(nil, settings...)})
+	f := c.next()
+	require.Equal(t, FrameSettings, f.Type)
+	require.False(t, f.Has(FlagAck))
+	return c
+}
+
+func (c *testClient) write(f *Frame) {
+	c.t.Helper()
+	require.NoError(c.t, WriteFrame(c.conn, f))
+}
+
+// next returns the next frame, skipping SETTINGS acks and WINDOW_UPDATE frames.
+func (c *testClient) next() *Frame {
+	c.t.Helper()
+	for {
+		f := c.nextAny()
+		if (f.Type == FrameSettings && f.Has(FlagAck)) || f.Type == FrameWindowUpdate {
+			continue
+		}
+		return f
+	}
+}
+
+func (c *testClient) nextAny() *Frame {
+	c.t.Helper()
+	select {
+	case f, ok := <-c.frames:
+		require.True(c.t, ok, "connection closed")
+		return f
+	case <-time.After(2 * time.Second):
+		require.FailNow(c.t, "timeout waiting for a frame")
+		return nil
+	}
+}
+
+// noFrame asserts that nothing but housekeeping frames arrive for a while.
+func (c *testClient) noFrame() {
+	c.t.Helper()
+	timeout := time.After(100 * time.Millisecond)
+	for {
+		select {
+		case f := <-c.frames:
+			if f.Type == FrameWindowUpdate || f.Type == FrameSettings {
+				continue
+			}
+			require.FailNow(c.t, "unexpected frame", "%+v", f)
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func (c *testClient) headers(id uint32, endStream bool, fields ...HeaderField) {
+	c.t.Helper()
+	f := &Frame{Type: FrameHeaders, Flags: FlagEndHeaders, StreamID: id, Payload: c.enc.Encode(nil, fields)}
+	if endStream {
+		f.Flags |= FlagEndStream
+	}
+	c.write(f)
+}
+
+func (c *testClient) get(id uint32, path string, extra ...HeaderField) {
+	c.t.Helper()
+	fields := []HeaderField{{":method", "GET"}, {":scheme", "http"}, {":authority", "localhost"}, {":path", path}}
+	c.headers(id, true, append(fields, extra...)...)
+}
+
+// testResponse is a response read from a stream.
+type testResponse struct {
+	interim  []map[string][]string
+	fields   map[string][]string
+	body     string
+	trailers map[string][]string
+}
+
+func (c *testClient) decode(f *Frame) map[string][]string {
+	c.t.Helper()
+	fields, err := c.dec.Decode(f.Payload)
+	require.NoError(c.t, err)
+	m := make(map[string][]string)
+	for _, field := range fields {
+		m[field.Name] = append(m[field.Name], field.Value)
+	}
+	return m
+}
+
+// responses reads frames until n streams ended, which must be responses.
+func (c *testClient) responses(n int) map[uint32]*testResponse {
+	c.t.Helper()
+	resps := make(map[uint32]*testResponse)
+	for ended := 0; ended < n; {
+		f := c.next()
+		resp := resps[f.StreamID]
+		switch f.Type {
+		case FrameHeaders:
+			require.True(c.t, f.Has(FlagEndHeaders))
+			if resp == nil {
+				resp = &testResponse{}
+				resps[f.StreamID] = resp
+			}
+			fields := c.decode(f)
+			switch {
+			case resp.fields == nil && strings.HasPrefix(strings.Join(fields[":status"], ""), "1"):
+				resp.interim = append(resp.interim, fields)
+			case resp.fields == nil:
+				resp.fields = fields
+			default:
+				resp.trailers = fields
+			}
+		case FrameData:
+			require.True(c.t, resp != nil && resp.fields != nil, "DATA before HEADERS")
+			resp.body += string(f.Payload)
+		default:
+			require.FailNow(c.t, "unexpected frame", "%+v", f)
+		}
+		if f.Has(FlagEndStream) {
+			ended++
+		}
+	}
+	return resps
+}
+
+func (c *testClient) response(id uint32) *testResponse {
+	c.t.Helper()
+	resp := c.responses(1)[id]
+	require.NotNil(c.t, resp)
+	return resp
+}
+
+// expectGoAway reads frames until a GOAWAY, and returns its error code.
+func (c *testClient) expectGoAway() ErrCode {
+	c.t.Helper()
+	for {
+		f := c.next()
+		if f.Type == FrameGoAway {
+			return ErrCode(binary.BigEndian.Uint32(f.Payload[4:]))
+		}
+	}
+}
+
+func (c *testClient) expectRST(id uint32) ErrCode {
+	c.t.Helper()
+	f := c.next()
+	require.Equal(c.t, FrameRSTStream, f.Type)
+	require.Equal(c.t, id, f.StreamID)
+	return ErrCode(binary.BigEndian.Uint32(f.Payload))
+}
+
+// textHandler responds with the body, or the request summary if empty.
+func textHandler(body string) Handler {
+	return func(w *response.Writer, req *request.Request) {
+		body := body
+		if body == "" {
+			cookies, _ := req.Headers.Get("cookie")
+			host, _ := req.Host()
+			body = fmt.Sprintf("%s %s %s host=%s cookie=%s body=%s",
+				req.RequestLine.Method, req.RequestLine.RequestTarget, req.RequestLine.HTTPVersion, host, cookies, req.Body)
+		}
+		w.WriteStatusLine(response.StatusOK)
+		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		w.WriteBody([]byte(body))
+	}
+}
+
+func TestServeConn(t *testing.T) {
+	c := newTestClient(t, textHandler(""), ConnOptions{})
+
+	// Test: Request is presented to the handler, connection-specific fields are dropped
+	c.get(1, "/path?q=1", HeaderField{"cookie", "a=1"}, HeaderField{"cookie", "b=2"})
+	resp := c.response(1)
+	assert.Equal(t, []string{"200"}, resp.fields[":status"])
+	assert.Equal(t, []string{"text/plain"}, resp.fields["content-type"])
+	assert.NotContains(t, resp.fields, "connection")
+	assert.Equal(t, "GET /path?q=1 2 host=localhost cookie=a=1; b=2 body=", resp.body)
+
+	// Test: HEAD keeps the headers of GET, but has no body
+	c.headers(3, true, HeaderField{":method", "HEAD"}, HeaderField{":scheme", "http"},
+		HeaderField{":authority", "localhost"}, HeaderField{":path", "/"})
+	f := c.next()
+	assert.Equal(t, FrameHeaders, f.Type)
+	assert.True(t, f.Has(FlagEndStream))
+	assert.Equal(t, []string{"37"}, c.decode(f)["content-length"])
+
+	// Test: Body over several DATA frames, with padding, replenishing the windows
+	c.headers(5, false, HeaderField{":method", "POST"}, HeaderField{":scheme", "http"},
+		HeaderField{":authority", "localhost"}, HeaderField{":path", "/submit"}, HeaderField{"content-length", "11"})
+	c.write(&Frame{Type: FrameData, StreamID: 5, Payload: []byte("hello ")})
+	c.write(&Frame{Type: FrameData, Flags: FlagPadded | FlagEndStream, StreamID: 5, Payload: []byte("\x03world\x00\x00\x00")})
+	updates := 0
+	for updates < 3 { // connection twice, and stream once since the last frame ends it
+		f := c.nextAny()
+		require.Equal(t, FrameWindowUpdate, f.Type)
+		updates++
+	}
+	resp = c.response(5)
+	assert.Equal(t, "POST /submit 2 host=localhost cookie= body=hello world", resp.body)
+
+	// Test: PING is acknowledged with the same payload
+	c.write(&Frame{Type: FramePing, Payload: []byte("12345678")})
+	f = c.next()
+	assert.Equal(t, FramePing, f.Type)
+	assert.True(t, f.Has(FlagAck))
+	assert.Equal(t, []byte("12345678"), f.Payload)
+
+	// Test: Header block continued over CONTINUATION frames
+	block := c.enc.Encode(nil, []HeaderField{{":method", "GET"}, {":scheme", "http"}, {":authority", "localhost"}, {":path", "/cont"}})
+	c.write(&Frame{Type: FrameHeaders, Flags: FlagEndStream, StreamID: 7, Payload: block[:3]})
+	c.write(&Frame{Type: FrameContinuation, StreamID: 7, Payload: block[3:5]})
+	c.write(&Frame{Type: FrameContinuation, Flags: FlagEndHeaders, StreamID: 7, Payload: block[5:]})
+	resp = c.response(7)
+	assert.Equal(t, "GET /cont 2 host=localhost cookie= body=", resp.body)
+
+	// Test: Connection is closed cleanly by the client
+	c.conn.Close()
+	assert.NoError(t, <-c.served)
+}
+
+func TestServeConnTrailers(t *testing.T) {
+	handler := func(w *response.Writer, req *request.Request) {
+		sum, _ := req.Trailers.Get("x-sum")
+		h := headers.NewHeaders()
+		h.Set("Transfer-Encoding", "chunked")
+		h.Set("Trailer", "X-Echo")
+		w.WriteStatusLine(response.StatusOK)
+		w.WriteHeaders(h)
+		w.WriteChunkedBody([]byte("chunk"))
+		w.WriteChunkedBodyDone()
+		t := headers.NewHeaders()
+		t.Set("X-Echo", sum)
+		w.WriteTrailer(t)
+	}
+	c := newTestClient(t, handler, ConnOptions{})
+
+	// Test: Trailers in both directions
+	c.headers(1, false, HeaderField{":method", "POST"}, HeaderField{":scheme", "http"},
+		HeaderField{":authority", "localhost"}, HeaderField{":path", "/"}, HeaderField{"trailer", "x-sum"})
+	c.write(&Frame{Type: FrameData, StreamID: 1, Payload: []byte("data")})
+	c.headers(1, true, HeaderField{"x-sum", "abc"})
+	resp := c.response(1)
+	assert.Equal(t, "chunk", resp.body)
+	assert.NotContains(t, resp.fields, "transfer-encoding")
+	assert.Equal(t, []string{"X-Echo"}, resp.fields["trailer"])
+	assert.Equal(t, map[string][]string{"x-echo": {"abc"}}, resp.trailers)
+
+	// Test: Undeclared request trailers
+	c.headers(3, false, HeaderField{":method", "POST"}, HeaderField{":scheme", "http"},
+		HeaderField{":authority", "localhost"}, HeaderField{":path", "/"})
+	c.headers(3, true, HeaderField{"x-sum", "abc"})
+	assert.Equal(t, ErrCodeProtocol, c.expectRST(3))
+}
+
+func TestServeConnCookies(t *testing.T) {
+	handler := func(w *response.Writer, req *request.Request) {
+		w.SetCookie(&cookie.Cookie{Name: "a", Value: "1", Expires: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)})
+		w.SetCookie(&cookie.Cookie{Name: "b", Value: "2"})
+		w.WriteStatusLine(response.StatusNoContent)
+		w.WriteHeaders(headers.NewHeaders())
+	}
+	c := newTestClient(t, handler, ConnOptions{})
+
+	// Test: Each Set-Cookie is its own field, since Expires contains a comma
+	c.get(1, "/")
+	resp := c.response(1)
+	assert.Equal(t, []string{"204"}, resp.fields[":status"])
+	assert.Equal(t, []string{"a=1; Expires=Tue, 01 Jan 2030 00:00:00 GMT", "b=2"}, resp.fields["set-cookie"])
+}
+
+func TestServeConnInterim(t *testing.T) {
+	release := make(chan struct{})
+	handler := func(w *response.Writer, req *request.Request) {
+		hints := headers.NewHeaders()
+		hints.Set("Link", "</style.css>; rel=preload; as=style")
+		hints.Set("Connection", "keep-alive")
+		w.WriteInterim(response.StatusEarlyHints, hints)
+		<-release
+		w.WriteStatusLine(response.StatusNoContent)
+		w.WriteHeaders(headers.NewHeaders())
+	}
+	c := newTestClient(t, handler, ConnOptions{})
+
+	// Test: Early hints are a HEADERS frame sent while the handler runs, without connection-specific fields
+	c.get(1, "/")
+	f := c.next()
+	require.Equal(t, FrameHeaders, f.Type)
+	assert.False(t, f.Has(FlagEndStream))
+	assert.Equal(t, map[string][]string{":status": {"103"}, "link": {"</style.css>; rel=preload; as=style"}}, c.decode(f))
+
+	// Test: Final response follows once the handler returns
+	close(release)
+	resp := c.response(1)
+	assert.Empty(t, resp.interim)
+	assert.Equal(t, []string{"204"}, resp.fields[":status"])
+}
+
+func TestServeConnFlowControl(t *testing.T) {
+	c := newTestClient(t, textHandler("0123456789abcdefghijklmno"), ConnOptions{},
+		Setting{SettingInitialWindowSize, 10})
+
+	// Test: DATA is sent up to the stream window, then waits for WINDOW_UPDATE
+	c.get(1, "/")
+	f := c.next()
+	require.Equal(t, FrameHeaders, f.Type)
+	f = c.next()
+	require.Equal(t, FrameData, f.Type)
+	assert.Equal(t, "0123456789", string(f.Payload))
+	c.noFrame()
+	c.write(&Frame{Type: FrameWindowUpdate, StreamID: 1, Payload: binary.BigEndian.AppendUint32(nil, 5)})
+	f = c.next()
+	assert.Equal(t, "abcde", string(f.Payload))
+	assert.False(t, f.Has(FlagEndStream))
+
+	// Test: Increasing SETTINGS_INITIAL_WINDOW_SIZE applies to open streams
+	c.write(&Frame{Type: FrameSettings, Payload: AppendSettings(nil, Setting{SettingInitialWindowSize, 20})})
+	f = c.next()
+	assert.Equal(t, "fghijklmno", string(f.Payload))
+	assert.True(t, f.Has(FlagEndStream))
+
+	// Test: Window overflow of a stream, whose handler is still running
+	release := make(chan struct{})
+	defer close(release)
+	c = newTestClient(t, func(w *response.Writer, req *request.Request) { <-release }, ConnOptions{})
+	c.get(1, "/")
+	c.write(&Frame{Type: FrameWindowUpdate, StreamID: 1, Payload: binary.BigEndian.AppendUint32(nil, maxWindowSize)})
+	assert.Equal(t, ErrCodeFlowControl, c.expectRST(1))
+}
+
+func TestServeConnLargeBody(t *testing.T) {
+	body := strings.Repeat("a", 11<<20)
+	c := newTestClient(t, textHandler(body), ConnOptions{}, Setting{SettingInitialWindowSize, maxWindowSize})
+	c.write(&Frame{Type: FrameWindowUpdate, Payload: binary.BigEndian.AppendUint32(nil, maxWindowSize-defaultWindowSize)})
+
+	// Test: Responses larger than the client body cap are sent whole
+	c.get(1, "/")
+	resp := c.response(1)
+	assert.Equal(t, []string{"200"}, resp.fields[":status"])
+	assert.Equal(t, len(body), len(resp.body))
+}
+
+func TestServeConnMaxBodySize(t *testing.T) {
+	c := newTestClient(t, textHandler(""), ConnOptions{MaxBodySize: 100000}, Setting{SettingInitialWindowSize, maxWindowSize})
+	c.write(&Frame{Type: FrameWindowUpdate, Payload: binary.BigEndian.AppendUint32(nil, maxWindowSize-defaultWindowSize)})
+	post := func(id uint32) {
+		c.headers(id, false, HeaderField{":method", "POST"}, HeaderField{":scheme", "http"},
+			HeaderField{":authority", "localhost"}, HeaderField{":path", "/"})
+	}
+
+	// Test: Body up to the cap, with the stream window never granting more than it
+	post(1)
+	for i := range 10 {
+		f := &Frame{Type: FrameData, StreamID: 1, Payload: []byte(strings.Repeat("a", 10000))}
+		if i == 9 {
+			f.Flags = FlagEndStream
+		}
+		c.write(f)
+	}
+	granted := defaultWindowSize
+	var body int
+	for ended := false; !ended; {
+		f := c.nextAny()
+		switch {
+		case f.Type == FrameWindowUpdate && f.StreamID == 1:
+			granted += int(binary.BigEndian.Uint32(f.Payload))
+		case f.Type == FrameData:
+			body += len(f.Payload)
+		}
+		ended = f.StreamID == 1 && f.Has(FlagEndStream)
+	}
+	assert.LessOrEqual(t, granted, 100000)
+	assert.Equal(t, len("POST / 2 host=localhost cookie= body=")+100000, body)
+
+	// Test: Larger body is answered with 413, then the stream is reset
+	post(3)
+	for range 10 {
+		c.write(&Frame{Type: FrameData, StreamID: 3, Payload: []byte(strings.Repeat("a", 10000))})
+	}
+	c.write(&Frame{Type: FrameData, StreamID: 3, Payload: []byte("a")})
+	f := c.next()
+	require.Equal(t, FrameHeaders, f.Type)
+	assert.True(t, f.Has(FlagEndStream))
+	assert.Equal(t, []string{"413"}, c.decode(f)[":status"])
+	assert.Equal(t, ErrCodeNo, c.expectRST(3))
+}
+
+func TestServeConnMultiplexing(t *testing.T) {
+	release := make(chan struct{})
+	handler := func(w *response.Writer, req *request.Request) {
+		if req.RequestLine.RequestTarget == "/slow" {
+			<-release
+		}
+		textHandler(req.RequestLine.RequestTarget)(w, req)
+	}
+	c := newTestClient(t, handler, ConnOptions{})
+
+	// Test: A slow stream does not block the others
+	c.get(1, "/slow")
+	c.get(3, "/fast")
+	resp := c.response(3)
+	assert.Equal(t, "/fast", resp.body)
+	close(release)
+	resp = c.response(1)
+	assert.Equal(t, "/slow", resp.body)
+}
+
+func TestServeConnErrors(t *testing.T) {
+	valid := []HeaderField{{":method", "GET"}, {":scheme", "http"}, {":authority", "localhost"}, {":path", "/"}}
+
+	// Test: Malformed requests reset the stream only
+	c := newTestClient(t, textHandler("ok"), ConnOptions{})
+	for i, fields := range [][]HeaderField{
+		{{":method", "GET"}, {":path", "/"}},                                           // missing :scheme
+		append(valid, HeaderField{"Upper", "x"}),                                       // uppercase name
+		append(valid, HeaderField{"connection", "close"}),                              // connection-specific
+		append(valid, HeaderField{"te", "gzip"}),                                       // te other than trailers
+		append([]HeaderField{{"accept", "*/*"}}, valid...),                             // pseudo-header after regular
+		append(valid, HeaderField{":status", "200"}),                                   // response pseudo-header
+		{{":method", "CONNECT"}, {":authority", "example.com:443"}, {":path", "/"}},    // CONNECT with :path
+		{{":method", "GET"}, {":scheme", "http"}, {":path", "/"}, {":path", "/other"}}, // duplicate pseudo-header
+	} {
+		id := uint32(2*i + 1)
+		c.headers(id, true, fields...)
+		assert.Equal(t, ErrCodeProtocol, c.expectRST(id), fields)
+	}
+	c.headers(17, false, append(valid, HeaderField{"content-length", "3"})...)
+	c.write(&Frame{Type: FrameData, Flags: FlagEndStream, StreamID: 17, Payload: []byte("toolong")})
+	assert.Equal(t, ErrCodeProtocol, c.expectRST(17))
+	c.get(19, "/")
+	assert.Equal(t, "ok", c.response(19).body)
+
+	// Test: Connection errors end the connection with GOAWAY
+	for name, frames := range map[string][]*Frame{
+		"DATA on stream 0":           {{Type: FrameData, Payload: []byte("x")}},
+		"even stream":                {{Type: FrameHeaders, Flags: FlagEndHeaders | FlagEndStream, StreamID: 2, Payload: (&Encoder{}).Encode(nil, valid)}},
+		"PING of 4 bytes":            {{Type: FramePing, Payload: []byte("1234")}},
+		"frame over the limit":       {{Type: FrameData, StreamID: 1, Payload: make([]byte, defaultMaxFrameSize+1)}},
+		"interleaved header block":   {{Type: FrameHeaders, StreamID: 1, Payload: []byte{0x82}}, {Type: FramePing, Payload: make([]byte, 8)}},
+		"invalid header compression": {{Type: FrameHeaders, Flags: FlagEndHeaders, StreamID: 1, Payload: []byte{0x80}}},
+		"push from client":           {{Type: FramePushPromise, StreamID: 1, Payload: make([]byte, 4)}},
+	} {
+		c := newTestClient(t, textHandler("ok"), ConnOptions{})
+		for _, f := range frames {
+			WriteFrame(c.conn, f) // the server may close the connection before reading all of it
+		}
+		code := c.expectGoAway()
+		assert.NotEqual(t, ErrCodeNo, code, name)
+		assert.Error(t, <-c.served, name)
+	}
+}
+
+func TestServeConnUpgrade(t *testing.T) {
+	req, err := request.RequestFromReader(strings.NewReader("GET /upgraded HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+
+	// Test: Upgrade request is answered on stream 1, with the settings of HTTP2-Settings
+	c := newTestClient(t, textHandler("1"), ConnOptions{
+		Upgrade:  req,
+		Settings: []Setting{{SettingMaxFrameSize, 1 << 15}},
+	})
+	resp := c.response(1)
+	assert.Equal(t, []string{"200"}, resp.fields[":status"])
+	assert.Equal(t, "1", resp.body)
+
+	// Test: Stream 1 is taken, so the next stream is 3
+	c.get(3, "/")
+	assert.Equal(t, "1", c.response(3).body)
+	c.get(1, "/")
+	for {
+		// A reset if stream 1 is still being closed, otherwise a connection error
+		f := c.next()
+		if f.Type == FrameRSTStream {
+			assert.Equal(t, ErrCodeStreamClosed, ErrCode(binary.BigEndian.Uint32(f.Payload)))
+			break
+		}
+		if f.Type == FrameGoAway {
+			assert.Equal(t, ErrCodeStreamClosed, ErrCode(binary.BigEndian.Uint32(f.Payload[4:])))
+			break
+		}
+	}
+}
+
+func TestServeConnShutdown(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan struct{})
+	handler := func(w *response.Writer, req *request.Request) {
+		<-release
+		textHandler("bye")(w, req)
+	}
+	c := newTestClient(t, handler, ConnOptions{Done: done})
+
+	// Test: GOAWAY is sent, the stream in flight is still answered, then the connection closes
+	c.get(1, "/")
+	c.noFrame() // make sure the stream was received before shutting down
+	close(done)
+	assert.Equal(t, ErrCodeNo, c.expectGoAway())
+	c.get(3, "/")
+	assert.Equal(t, ErrCodeRefusedStream, c.expectRST(3))
+	close(release)
+	assert.Equal(t, "bye", c.response(1).body)
+	assert.NoError(t, <-c.served)
+}
@@ -0,0 +1,229 @@
+package http2
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ClientPreface starts every HTTP/2 connection, based on RFC 9113 Section 3.4.
+const ClientPreface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// FrameType identifies the format of a frame, based on RFC 9113 Section 6.
+type FrameType uint8
+
+const (
+	FrameData         FrameType = 0x0
+	FrameHeaders      FrameType = 0x1
+	FramePriority     FrameType = 0x2
+	FrameRSTStream    FrameType = 0x3
+	FrameSettings     FrameType = 0x4
+	FramePushPromise  FrameType = 0x5
+	FramePing         FrameType = 0x6
+	FrameGoAway       FrameType = 0x7
+	FrameWindowUpdate FrameType = 0x8
+	FrameContinuation FrameType = 0x9
+)
+
+// Frame flags, whose meaning depends on the frame type.
+const (
+	FlagEndStream  uint8 = 0x1 // DATA and HEADERS
+	FlagAck        uint8 = 0x1 // SETTINGS and PING
+	FlagEndHeaders uint8 = 0x4 // HEADERS and CONTINUATION
+	FlagPadded     uint8 = 0x8 // DATA and HEADERS
+	FlagPriority   uint8 = 0x20
+)
+
+const (
+	frameHeaderLen      = 9
+	defaultMaxFrameSize = 1 << 14 // SETTINGS_MAX_FRAME_SIZE we advertise, the default
+	maxAllowedFrameSize = 1<<24 - 1
+	defaultWindowSize   = 1<<16 - 1
+	maxWindowSize       = 1<<31 - 1
+)
+
+// Frame is a frame with its payload as is, i.e., with any padding.
+type Frame struct {
+	Type     FrameType
+	Flags    uint8
+	StreamID uint32
+	Payload  []byte
+}
+
+func (f *Frame) Has(flag uint8) bool {
+	return f.Flags&flag != 0
+}
+
+// ReadFrame reads a frame, whose payload must be at most maxSize bytes.
+func ReadFrame(r io.Reader, maxSize uint32) (*Frame, error) {
+	var hdr [frameHeaderLen]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	length := uint32(hdr[0])<<16 | uint32(hdr[1])<<8 | uint32(hdr[2])
+	if length > maxSize {
+		return nil, ConnError{ErrCodeFrameSize, fmt.Sprintf("frame of %d bytes over the limit", length)}
+	}
+	f := &Frame{
+		Type:     FrameType(hdr[3]),
+		Flags:    hdr[4],
+		StreamID: binary.BigEndian.Uint32(hdr[5:]) & maxWindowSize, // ignore the reserved bit
+		Payload:  make([]byte, length),
+	}
+	if _, err := io.ReadFull(r, f.Payload); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// WriteFrame writes the frame in a single write call.
+func WriteFrame(w io.Writer, f *Frame) error {
+	buf := make([]byte, frameHeaderLen, frameHeaderLen+len(f.Payload))
+	length := len(f.Payload)
+	buf[0], buf[1], buf[2] = byte(length>>16), byte(length>>8), byte(length)
+	buf[3] = byte(f.Type)
+	buf[4] = f.Flags
+	binary.BigEndian.PutUint32(buf[5:], f.StreamID)
+	_, err := w.Write(append(buf, f.Payload...))
+	return err
+}
+
+// data returns the payload of a DATA or HEADERS frame without padding and
+// priority fields, based on RFC 9113 Section 6.1 and 6.2.
+func (f *Frame) data() ([]byte, error) {
+	p := f.Payload
+	padLen := 0
+	if f.Has(FlagPadded) {
+		if len(p) < 1 {
+			return nil, ConnError{ErrCodeFrameSize, "missing pad length"}
+		}
+		padLen = int(p[0])
+		p = p[1:]
+	}
+	if f.Type == FrameHeaders && f.Has(FlagPriority) {
+		if len(p) < 5 {
+			return nil, ConnError{ErrCodeFrameSize, "missing priority fields"}
+		}
+		p = p[5:]
+	}
+	if padLen > len(p) {
+		return nil, ConnError{ErrCodeProtocol, "padding longer than the payload"}
+	}
+	return p[:len(p)-padLen], nil
+}
+
+// SettingID identifies a setting, based on RFC 9113 Section 6.5.2.
+type SettingID uint16
+
+const (
+	SettingHeaderTableSize      SettingID = 0x1
+	SettingEnablePush           SettingID = 0x2
+	SettingMaxConcurrentStreams SettingID = 0x3
+	SettingInitialWindowSize    SettingID = 0x4
+	SettingMaxFrameSize         SettingID = 0x5
+	SettingMaxHeaderListSize    SettingID = 0x6
+)
+
+type Setting struct {
+	ID    SettingID
+	Value uint32
+}
+
+// ParseSettings parses the payload of a SETTINGS frame, and validates the
+// values of known settings. Unknown settings are kept, to be ignored.
+func ParseSettings(payload []byte) ([]Setting, error) {
+	if len(payload)%6 != 0 {
+		return nil, ConnError{ErrCodeFrameSize, "settings payload not a multiple of 6"}
+	}
+	settings := make([]Setting, 0, len(payload)/6)
+	for i := 0; i < len(payload); i += 6 {
+		s := Setting{
+			ID:    SettingID(binary.BigEndian.Uint16(payload[i:])),
+			Value: binary.BigEndian.Uint32(payload[i+2:]),
+		}
+		switch {
+		case s.ID == SettingEnablePush && s.Value > 1:
+			return nil, ConnError{ErrCodeProtocol, "invalid SETTINGS_ENABLE_PUSH"}
+		case s.ID == SettingInitialWindowSize && s.Value > maxWindowSize:
+			return nil, ConnError{ErrCodeFlowControl, "invalid SETTINGS_INITIAL_WINDOW_SIZE"}
+		case s.ID == SettingMaxFrameSize && (s.Value < defaultMaxFrameSize || s.Value > maxAllowedFrameSize):
+			return nil, ConnError{ErrCodeProtocol, "invalid SETTINGS_MAX_FRAME_SIZE"}
+		}
+		settings = append(settings, s)
+	}
+	return settings, nil
+}
+
+// AppendSettings appends the payload of a SETTINGS frame to dst.
+func AppendSettings(dst []byte, settings ...Setting) []byte {
+	for _, s := range settings {
+		dst = binary.BigEndian.AppendUint16(dst, uint16(s.ID))
+		dst = binary.BigEndian.AppendUint32(dst, s.Value)
+	}
+	return dst
+}
+
+// ErrCode is the reason of a stream or connection error, based on RFC 9113 Section 7.
+type ErrCode uint32
+
+const (
+	ErrCodeNo                 ErrCode = 0x0
+	ErrCodeProtocol           ErrCode = 0x1
+	ErrCodeInternal           ErrCode = 0x2
+	ErrCodeFlowControl        ErrCode = 0x3
+	ErrCodeSettingsTimeout    ErrCode = 0x4
+	ErrCodeStreamClosed       ErrCode = 0x5
+	ErrCodeFrameSize          ErrCode = 0x6
+	ErrCodeRefusedStream      ErrCode = 0x7
+	ErrCodeCancel             ErrCode = 0x8
+	ErrCodeCompression        ErrCode = 0x9
+	ErrCodeConnect            ErrCode = 0xa
+	ErrCodeEnhanceYourCalm    ErrCode = 0xb
+	ErrCodeInadequateSecurity ErrCode = 0xc
+	ErrCodeHTTP11Required     ErrCode = 0xd
+)
+
+var errCodeNames = map[ErrCode]string{
+	ErrCodeNo:                 "NO_ERROR",
+	ErrCodeProtocol:           "PROTOCOL_ERROR",
+	ErrCodeInternal:           "INTERNAL_ERROR",
+	ErrCodeFlowControl:        "FLOW_CONTROL_ERROR",
+	ErrCodeSettingsTimeout:    "SETTINGS_TIMEOUT",
+	ErrCodeStreamClosed:       "STREAM_CLOSED",
+	ErrCodeFrameSize:          "FRAME_SIZE_ERROR",
+	ErrCodeRefusedStream:      "REFUSED_STREAM",
+	ErrCodeCancel:             "CANCEL",
+	ErrCodeCompression:        "COMPRESSION_ERROR",
+	ErrCodeConnect:            "CONNECT_ERROR",
+	ErrCodeEnhanceYourCalm:    "ENHANCE_YOUR_CALM",
+	ErrCodeInadequateSecurity: "INADEQUATE_SECURITY",
+	ErrCodeHTTP11Required:     "HTTP_1_1_REQUIRED",
+}
+
+func (c ErrCode) String() string {
+	if name, ok := errCodeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown error code 0x%x", uint32(c))
+}
+
+// ConnError is an error that terminates the connection with a GOAWAY.
+type ConnError struct {
+	Code   ErrCode
+	Reason string
+}
+
+func (e ConnError) Error() string {
+	return fmt.Sprintf("connection error %v: %s", e.Code, e.Reason)
+}
+
+// StreamError is an error that terminates a stream with a RST_STREAM.
+type StreamError struct {
+	StreamID uint32
+	Code     ErrCode
+	Reason   string
+}
+
+func (e StreamError) Error() string {
+	return fmt.Sprintf("stream %d error %v: %s", e.StreamID, e.Code, e.Reason)
+}
@@ -0,0 +1,311 @@
+package http2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// HeaderField is a name-value pair of a header list, based on RFC 7541 Section 1.3.
+type HeaderField struct {
+	Name  string
+	Value string
+}
+
+// size is the size of the field in a dynamic table, based on RFC 7541 Section 4.1.
+func (f HeaderField) size() uint32 {
+	return uint32(len(f.Name) + len(f.Value) + 32)
+}
+
+// staticTable is the predefined table of RFC 7541 Appendix A, indexed from 1.
+var staticTable = []HeaderField{
+	{":authority", ""},
+	{":method", "GET"},
+	{":method", "POST"},
+	{":path", "/"},
+	{":path", "/index.html"},
+	{":scheme", "http"},
+	{":scheme", "https"},
+	{":status", "200"},
+	{":status", "204"},
+	{":status", "206"},
+	{":status", "304"},
+	{":status", "400"},
+	{":status", "404"},
+	{":status", "500"},
+	{"accept-charset", ""},
+	{"accept-encoding", "gzip, deflate"},
+	{"accept-language", ""},
+	{"accept-ranges", ""},
+	{"accept", ""},
+	{"access-control-allow-origin", ""},
+	{"age", ""},
+	{"allow", ""},
+	{"authorization", ""},
+	{"cache-control", ""},
+	{"content-disposition", ""},
+	{"content-encoding", ""},
+	{"content-language", ""},
+	{"content-length", ""},
+	{"content-location", ""},
+	{"content-range", ""},
+	{"content-type", ""},
+	{"cookie", ""},
+	{"date", ""},
+	{"etag", ""},
+	{"expect", ""},
+	{"expires", ""},
+	{"from", ""},
+	{"host", ""},
+	{"if-match", ""},
+	{"if-modified-since", ""},
+	{"if-none-match", ""},
+	{"if-range", ""},
+	{"if-unmodified-since", ""},
+	{"last-modified", ""},
+	{"link", ""},
+	{"location", ""},
+	{"max-forwards", ""},
+	{"proxy-authenticate", ""},
+	{"proxy-authorization", ""},
+	{"range", ""},
+	{"referer", ""},
+	{"refresh", ""},
+	{"retry-after", ""},
+	{"server", ""},
+	{"set-cookie", ""},
+	{"strict-transport-security", ""},
+	{"transfer-encoding", ""},
+	{"user-agent", ""},
+	{"vary", ""},
+	{"via", ""},
+	{"www-authenticate", ""},
+}
+
+const (
+	defaultHeaderTableSize = 4096    // SETTINGS_HEADER_TABLE_SIZE we advertise, the default
+	maxHeaderListSize      = 1 << 20 // bound on a decoded header list, against decompression bombs
+)
+
+var errCompression = errors.New("hpack: invalid header block")
+
+// Decoder decodes header blocks, keeping the dynamic table across blocks of
+// a connection, based on RFC 7541 Section 3. It is not safe for concurrent use.
+type Decoder struct {
+	dynamic []HeaderField // oldest first
+	size    uint32        // of the dynamic table
+	maxSize uint32        // set by table size updates, at most allowedMaxSize
+	// allowedMaxSize is the SETTINGS_HEADER_TABLE_SIZE we advertised
+	allowedMaxSize uint32
+}
+
+func NewDecoder() *Decoder {
+	return &Decoder{maxSize: defaultHeaderTableSize, allowedMaxSize: defaultHeaderTableSize}
+}
+
+// Decode decodes a complete header block into its header list.
+func (d *Decoder) Decode(block []byte) ([]HeaderField, error) {
+	fields := make([]HeaderField, 0)
+	var listSize uint32
+	for len(block) > 0 {
+		b := block[0]
+		var field HeaderField
+		var err error
+		switch {
+		case b&0x80 != 0: // indexed, Section 6.1
+			var idx uint64
+			idx, block, err = readInt(block, 7)
+			if err != nil {
+				return nil, err
+			}
+			field, err = d.at(idx)
+		case b&0xc0 == 0x40: // literal with incremental indexing, Section 6.2.1
+			field, block, err = d.readLiteral(block, 6)
+			if err == nil {
+				d.add(field)
+			}
+		case b&0xe0 == 0x20: // dynamic table size update, Section 6.3
+			// Updates may only come first in a block, see Section 4.2
+			if len(fields) > 0 {
+				return nil, fmt.Errorf("%w: table size update after a field", errCompression)
+			}
+			var size uint64
+			size, block, err = readInt(block, 5)
+			if err != nil {
+				return nil, err
+			}
+			if size > uint64(d.allowedMaxSize) {
+				return nil, fmt.Errorf("%w: table size %d over the limit", errCompression, size)
+			}
+			d.maxSize = uint32(size)
+			d.evict()
+			continue
+		default: // literal without indexing or never indexed, Section 6.2.2 and 6.2.3
+			field, block, err = d.readLiteral(block, 4)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if listSize += field.size(); listSize > maxHeaderListSize {
+			return nil, fmt.Errorf("%w: header list too large", errCompression)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// at returns the field at the index of the static and dynamic tables, based on RFC 7541 Section 2.3.3.
+func (d *Decoder) at(idx uint64) (HeaderField, error) {
+	if idx == 0 {
+		return HeaderField{}, fmt.Errorf("%w: index 0", errCompression)
+	}
+	if idx <= uint64(len(staticTable)) {
+		return staticTable[idx-1], nil
+	}
+	idx -= uint64(len(staticTable))
+	if idx > uint64(len(d.dynamic)) {
+		return HeaderField{}, fmt.Errorf("%w: index out of range", errCompression)
+	}
+	return d.dynamic[len(d.dynamic)-int(idx)], nil // newest entries have the lowest index
+}
+
+// readLiteral reads a literal field whose name index has the given prefix length.
+func (d *Decoder) readLiteral(block []byte, prefix uint8) (HeaderField, []byte, error) {
+	idx, block, err := readInt(block, prefix)
+	if err != nil {
+		return HeaderField{}, nil, err
+	}
+	var field HeaderField
+	if idx > 0 {
+		name, err := d.at(idx)
+		if err != nil {
+			return HeaderField{}, nil, err
+		}
+		field.Name = name.Name
+	} else if field.Name, block, err = readString(block); err != nil {
+		return HeaderField{}, nil, err
+	}
+	if field.Value, block, err = readString(block); err != nil {
+		return HeaderField{}, nil, err
+	}
+	return field, block, nil
+}
+
+// add inserts the field in the dynamic table, based on RFC 7541 Section 4.4.
+func (d *Decoder) add(f HeaderField) {
+	d.dynamic = append(d.dynamic, f)
+	d.size += f.size()
+	d.evict() // a field larger than the table empties it, including itself
+}
+
+func (d *Decoder) evict() {
+	for d.size > d.maxSize && len(d.dynamic) > 0 {
+		d.size -= d.dynamic[0].size()
+		d.dynamic = d.dynamic[1:]
+	}
+}
+
+// Encoder encodes header lists. It never adds to the dynamic table, so blocks
+// can be encoded in any order, at the cost of a lower compression ratio.
+type Encoder struct{}
+
+// Encode appends the header block of the fields to dst.
+func (e *Encoder) Encode(dst []byte, fields []HeaderField) []byte {
+	for _, f := range fields {
+		nameIdx := 0
+		for i, s := range staticTable {
+			if s.Name != f.Name {
+				continue
+			}
+			if s.Value == f.Value {
+				nameIdx = -(i + 1) // full match
+				break
+			}
+			if nameIdx == 0 {
+				nameIdx = i + 1
+			}
+		}
+		if nameIdx < 0 {
+			dst = appendInt(dst, 0x80, 7, uint64(-nameIdx))
+			continue
+		}
+		dst = appendInt(dst, 0x00, 4, uint64(nameIdx)) // literal without indexing
+		if nameIdx == 0 {
+			dst = appendString(dst, f.Name)
+		}
+		dst = appendString(dst, f.Value)
+	}
+	return dst
+}
+
+// readInt reads an integer with an N-bit prefix, based on RFC 7541 Section 5.1.
+func readInt(block []byte, prefix uint8) (uint64, []byte, error) {
+	if len(block) == 0 {
+		return 0, nil, fmt.Errorf("%w: truncated integer", errCompression)
+	}
+	mask := uint64(1)<<prefix - 1
+	n := uint64(block[0]) & mask
+	block = block[1:]
+	if n < mask {
+		return n, block, nil
+	}
+	for shift := uint(0); len(block) > 0; shift += 7 {
+		if shift > 56 {
+			return 0, nil, fmt.Errorf("%w: integer overflow", errCompression)
+		}
+		b := block[0]
+		block = block[1:]
+		n += uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return n, block, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("%w: truncated integer", errCompression)
+}
+
+// appendInt appends an integer with an N-bit prefix, the other bits of the first octet set to flags.
+func appendInt(dst []byte, flags byte, prefix uint8, n uint64) []byte {
+	mask := uint64(1)<<prefix - 1
+	if n < mask {
+		return append(dst, flags|byte(n))
+	}
+	dst = append(dst, flags|byte(mask))
+	for n -= mask; n >= 0x80; n >>= 7 {
+		dst = append(dst, byte(n&0x7f)|0x80)
+	}
+	return append(dst, byte(n))
+}
+
+// readString reads a string literal, based on RFC 7541 Section 5.2.
+func readString(block []byte) (string, []byte, error) {
+	if len(block) == 0 {
+		return "", nil, fmt.Errorf("%w: truncated string", errCompression)
+	}
+	huffman := block[0]&0x80 != 0
+	n, block, err := readInt(block, 7)
+	if err != nil {
+		return "", nil, err
+	}
+	if n > uint64(len(block)) {
+		return "", nil, fmt.Errorf("%w: truncated string", errCompression)
+	}
+	data := block[:n]
+	block = block[n:]
+	if !huffman {
+		return string(data), block, nil
+	}
+	decoded, err := huffmanDecode(data)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(decoded), block, nil
+}
+
+// appendString appends a string literal, Huffman encoded if that is shorter.
+func appendString(dst []byte, s string) []byte {
+	if n := huffmanEncodedLen(s); n < len(s) {
+		dst = appendInt(dst, 0x80, 7, uint64(n))
+		return huffmanEncode(dst, s)
+	}
+	dst = appendInt(dst, 0x00, 7, uint64(len(s)))
+	return append(dst, s...)
+}
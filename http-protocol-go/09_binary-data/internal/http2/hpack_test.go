@@ -0,0 +1,119 @@
+package http2
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	require.NoError(t, err)
+	return b
+}
+
+func TestInteger(t *testing.T) {
+	// Test: Examples of RFC 7541 Appendix C.1
+	assert.Equal(t, []byte{0x0a}, appendInt(nil, 0, 5, 10))
+	assert.Equal(t, []byte{0x1f, 0x9a, 0x0a}, appendInt(nil, 0, 5, 1337))
+	assert.Equal(t, []byte{0x2a}, appendInt(nil, 0, 8, 42))
+	n, rest, err := readInt([]byte{0xff, 0x9a, 0x0a, 0x01}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1337), n)
+	assert.Equal(t, []byte{0x01}, rest)
+
+	// Test: Truncated and overflowing integers
+	_, _, err = readInt([]byte{0x1f, 0x9a}, 5)
+	require.Error(t, err)
+	_, _, err = readInt(mustHex(t, "1f ffffffffffffffffff01"), 5)
+	require.Error(t, err)
+}
+
+func TestHuffman(t *testing.T) {
+	// Test: Examples of RFC 7541 Appendix C.4
+	assert.Equal(t, mustHex(t, "f1e3 c2e5 f23a 6ba0 ab90 f4ff"), huffmanEncode(nil, "www.example.com"))
+	assert.Equal(t, mustHex(t, "a8eb 1064 9cbf"), huffmanEncode(nil, "no-cache"))
+	decoded, err := huffmanDecode(mustHex(t, "25a8 49e9 5ba9 7d7f"))
+	require.NoError(t, err)
+	assert.Equal(t, "custom-key", string(decoded))
+
+	// Test: Round trip of every octet
+	var all strings.Builder
+	for i := range 256 {
+		all.WriteByte(byte(i))
+	}
+	decoded, err = huffmanDecode(huffmanEncode(nil, all.String()))
+	require.NoError(t, err)
+	assert.Equal(t, all.String(), string(decoded))
+
+	// Test: Padding longer than 7 bits, or not all ones
+	_, err = huffmanDecode(mustHex(t, "f1e3 c2e5 f23a 6ba0 ab90 f4ff ff"))
+	require.Error(t, err)
+	_, err = huffmanDecode(mustHex(t, "a8eb 1064 9cbe"))
+	require.Error(t, err)
+}
+
+func TestDecoder(t *testing.T) {
+	// Test: Requests of RFC 7541 Appendix C.3, without Huffman coding
+	d := NewDecoder()
+	fields, err := d.Decode(mustHex(t, "8286 8441 0f77 7777 2e65 7861 6d70 6c65 2e63 6f6d"))
+	require.NoError(t, err)
+	assert.Equal(t, []HeaderField{
+		{":method", "GET"}, {":scheme", "http"}, {":path", "/"}, {":authority", "www.example.com"},
+	}, fields)
+	assert.Equal(t, uint32(57), d.size)
+	fields, err = d.Decode(mustHex(t, "8286 84be 5808 6e6f 2d63 6163 6865"))
+	require.NoError(t, err)
+	assert.Equal(t, HeaderField{"cache-control", "no-cache"}, fields[4])
+	assert.Equal(t, uint32(110), d.size)
+	fields, err = d.Decode(mustHex(t, "8287 85bf 400a 6375 7374 6f6d 2d6b 6579 0c63 7573 746f 6d2d 7661 6c75 65"))
+	require.NoError(t, err)
+	assert.Equal(t, []HeaderField{
+		{":method", "GET"}, {":scheme", "https"}, {":path", "/index.html"},
+		{":authority", "www.example.com"}, {"custom-key", "custom-value"},
+	}, fields)
+	assert.Equal(t, uint32(164), d.size)
+
+	// Test: Requests of RFC 7541 Appendix C.4, with Huffman coding
+	d = NewDecoder()
+	_, err = d.Decode(mustHex(t, "8286 8441 8cf1 e3c2 e5f2 3a6b a0ab 90f4 ff"))
+	require.NoError(t, err)
+	_, err = d.Decode(mustHex(t, "8286 84be 5886 a8eb 1064 9cbf"))
+	require.NoError(t, err)
+	fields, err = d.Decode(mustHex(t, "8287 85bf 4088 25a8 49e9 5ba9 7d7f 8925 a849 e95b b8e8 b4bf"))
+	require.NoError(t, err)
+	assert.Equal(t, HeaderField{"custom-key", "custom-value"}, fields[4])
+	assert.Equal(t, uint32(164), d.size)
+
+	// Test: Table size update evicts entries, and must come first
+	_, err = d.Decode([]byte{0x20})
+	require.NoError(t, err)
+	assert.Empty(t, d.dynamic)
+	_, err = d.Decode([]byte{0x82, 0x20})
+	require.Error(t, err)
+	_, err = d.Decode(mustHex(t, "3fe2 1f")) // 4097, over the advertised size
+	require.Error(t, err)
+
+	// Test: Invalid indices and truncated strings
+	for _, block := range []string{"80", "be", "0f 77", "40 05 61"} {
+		_, err = NewDecoder().Decode(mustHex(t, block))
+		require.Error(t, err, block)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	// Test: Static table matches are indexed, other fields are literals
+	var e Encoder
+	fields := []HeaderField{
+		{":status", "200"}, {"content-type", "text/html"}, {"x-custom", "value"}, {"set-cookie", "a=b"},
+	}
+	block := e.Encode(nil, fields)
+	assert.Equal(t, byte(0x88), block[0])
+	decoded, err := NewDecoder().Decode(block)
+	require.NoError(t, err)
+	assert.Equal(t, fields, decoded)
+}
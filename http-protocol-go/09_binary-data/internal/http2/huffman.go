@@ -0,0 +1,87 @@
+package http2
+
+import "fmt"
+
+// huffmanNode is a node of the decoding tree, a leaf if it has no children.
+type huffmanNode struct {
+	children [2]*huffmanNode
+	sym      byte
+}
+
+var huffmanRoot = buildHuffmanTree()
+
+func buildHuffmanTree() *huffmanNode {
+	root := &huffmanNode{}
+	for sym, code := range huffmanCodes {
+		n := root
+		for i := int(huffmanCodeLens[sym]) - 1; i >= 0; i-- {
+			bit := (code >> i) & 1
+			if n.children[bit] == nil {
+				n.children[bit] = &huffmanNode{}
+			}
+			n = n.children[bit]
+		}
+		n.sym = byte(sym)
+	}
+	return root
+}
+
+func (n *huffmanNode) leaf() bool {
+	return n.children[0] == nil && n.children[1] == nil
+}
+
+// huffmanDecode decodes a Huffman encoded string, based on RFC 7541 Section 5.2.
+// Padding must be at most 7 bits and all ones, i.e., a prefix of EOS.
+func huffmanDecode(src []byte) ([]byte, error) {
+	dst := make([]byte, 0, len(src)*8/5)
+	n := huffmanRoot
+	depth, ones := 0, true // of the code read so far
+	for _, b := range src {
+		for i := 7; i >= 0; i-- {
+			bit := (b >> i) & 1
+			n = n.children[bit]
+			if n == nil {
+				return nil, fmt.Errorf("%w: invalid huffman code", errCompression)
+			}
+			depth++
+			ones = ones && bit == 1
+			if n.leaf() {
+				dst = append(dst, n.sym)
+				n, depth, ones = huffmanRoot, 0, true
+			}
+		}
+	}
+	if depth > 7 || !ones {
+		return nil, fmt.Errorf("%w: invalid huffman padding", errCompression)
+	}
+	return dst, nil
+}
+
+// huffmanEncode appends the Huffman encoding of s to dst, padded with ones.
+func huffmanEncode(dst []byte, s string) []byte {
+	var acc uint64 // pending bits, only the lowest nbits matter
+	var nbits uint
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		acc = acc<<huffmanCodeLens[c] | uint64(huffmanCodes[c])
+		nbits += uint(huffmanCodeLens[c])
+		for nbits >= 8 {
+			nbits -= 8
+			dst = append(dst, byte(acc>>nbits))
+		}
+	}
+	if nbits > 0 {
+		pad := 8 - nbits
+		dst = append(dst, byte(acc<<pad|(1<<pad-1)))
+	}
+	return dst
+}
+
+// huffmanEncodedLen returns the length of the Huffman encoding of s.
+func huffmanEncodedLen(s string) int {
+	var nbits int
+	for i := 0; i < len(s); i++ {
+		nbits += int(huffmanCodeLens[s[i]])
+	}
+	return (nbits + 7) / 8
+}
@@ -0,0 +1,191 @@
+package http2
+
+import (
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/client"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+)
+
+// connectionSpecific are fields that must not appear in HTTP/2 messages,
+// based on RFC 9113 Section 8.2.2.
+var connectionSpecific = []string{"connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade"}
+
+// newRequest builds a request from the header list of a stream, based on RFC 9113 Section 8.3.1.
+// The request line version is "2", and :authority is used as the Host header if missing.
+func newRequest(fields []HeaderField) (*request.Request, error) {
+	req := &request.Request{
+		Headers:  headers.NewHeaders(),
+		Body:     make([]byte, 0),
+		Trailers: headers.NewHeaders(),
+	}
+	req.RequestLine.HTTPVersion = "2"
+	pseudo := make(map[string]string)
+	cookies := make([]string, 0)
+	for _, f := range fields {
+		if strings.HasPrefix(f.Name, ":") {
+			if len(req.Headers) > 0 || len(cookies) > 0 {
+				return nil, fmt.Errorf("pseudo-header %s after regular fields", f.Name)
+			}
+			switch f.Name {
+			case ":method", ":scheme", ":authority", ":path":
+			default:
+				return nil, fmt.Errorf("invalid request pseudo-header %s", f.Name)
+			}
+			if _, dup := pseudo[f.Name]; dup {
+				return nil, fmt.Errorf("duplicate pseudo-header %s", f.Name)
+			}
+			pseudo[f.Name] = f.Value
+			continue
+		}
+		if err := validateField(f); err != nil {
+			return nil, err
+		}
+		// Cookies may be split into several fields for compression, see Section 8.2.3
+		if f.Name == "cookie" {
+			cookies = append(cookies, f.Value)
+			continue
+		}
+		req.Headers.Set(f.Name, f.Value)
+	}
+	if len(cookies) > 0 {
+		req.Headers.Set("cookie", strings.Join(cookies, "; "))
+	}
+
+	method, path := pseudo[":method"], pseudo[":path"]
+	if method == "" {
+		return nil, fmt.Errorf("missing :method")
+	}
+	if method == "CONNECT" {
+		// Only the authority identifies the target of a tunnel, see Section 8.5
+		if pseudo[":authority"] == "" || path != "" || pseudo[":scheme"] != "" {
+			return nil, fmt.Errorf("malformed CONNECT request")
+		}
+		path = pseudo[":authority"]
+	} else if path == "" || pseudo[":scheme"] == "" {
+		return nil, fmt.Errorf("missing :scheme or :path")
+	}
+	req.RequestLine.Method = method
+	req.RequestLine.RequestTarget = path
+	if authority := pseudo[":authority"]; authority != "" {
+		if _, found := req.Headers.Get("host"); !found {
+			req.Headers.Set("host", authority)
+		}
+	}
+	return req, nil
+}
+
+// newTrailers builds the trailers of a request from the header list ending a
+// stream, which must have been declared in the Trailer header.
+func newTrailers(req *request.Request, fields []HeaderField) (headers.Headers, error) {
+	trailers := headers.NewHeaders()
+	for _, f := range fields {
+		if strings.HasPrefix(f.Name, ":") {
+			return nil, fmt.Errorf("pseudo-header %s in trailers", f.Name)
+		}
+		if err := validateField(f); err != nil {
+			return nil, err
+		}
+		trailers.Set(f.Name, f.Value)
+	}
+	declared, err := req.Headers.DeclaredTrailers()
+	if err != nil {
+		return nil, err
+	}
+	return trailers, headers.ValidateTrailers(declared, trailers)
+}
+
+// validateField checks a regular field of a request, based on RFC 9113 Section 8.2.
+func validateField(f HeaderField) error {
+	if f.Name != strings.ToLower(f.Name) {
+		return fmt.Errorf("uppercase field name %s", f.Name)
+	}
+	if slices.Contains(connectionSpecific, f.Name) {
+		return fmt.Errorf("connection-specific field %s", f.Name)
+	}
+	if f.Name == "te" && f.Value != "trailers" {
+		return fmt.Errorf("invalid te value %s", f.Value)
+	}
+	if strings.ContainsAny(f.Value, "\r\n\x00") {
+		return fmt.Errorf("invalid characters in field %s", f.Name)
+	}
+	return nil
+}
+
+// responseFields returns the header list of a response written by a handler.
+// Cookies are given separately, since each Set-Cookie must be its own field.
+func responseFields(resp *client.Response, cookies []string) []HeaderField {
+	fields := []HeaderField{{":status", strconv.Itoa(int(resp.StatusLine.StatusCode))}}
+	for _, key := range slices.Sorted(maps.Keys(resp.Headers)) {
+		if slices.Contains(connectionSpecific, key) || (key == "set-cookie" && len(cookies) > 0) {
+			continue
+		}
+		fields = append(fields, HeaderField{key, resp.Headers[key]})
+	}
+	for _, c := range cookies {
+		fields = append(fields, HeaderField{"set-cookie", c})
+	}
+	return fields
+}
+
+// interimFields returns the header list of an interim response, which is sent
+// as a HEADERS frame ahead of the final one, based on RFC 9113 Section 8.1.
+func interimFields(statusCode response.StatusCode, h headers.Headers) []HeaderField {
+	fields := []HeaderField{{":status", strconv.Itoa(int(statusCode))}}
+	for _, key := range slices.Sorted(maps.Keys(h)) {
+		if !slices.Contains(connectionSpecific, key) {
+			fields = append(fields, HeaderField{key, h[key]})
+		}
+	}
+	return fields
+}
+
+// trailerFields returns the header list of response trailers.
+func trailerFields(trailers headers.Headers) []HeaderField {
+	fields := make([]HeaderField, 0, len(trailers))
+	for _, key := range slices.Sorted(maps.Keys(trailers)) {
+		fields = append(fields, HeaderField{key, trailers[key]})
+	}
+	return fields
+}
+
+// UpgradeSettings returns the settings of a request asking to upgrade to h2c,
+// based on RFC 7540 Section 3.2. RFC 9113 deprecated this upgrade, but
+// clients such as curl still use it.
+func UpgradeSettings(req *request.Request) ([]Setting, bool) {
+	upgrade, _ := req.Headers.Get("upgrade")
+	connection, _ := req.Headers.Get("connection")
+	if !hasToken(upgrade, "h2c") || !hasToken(connection, "upgrade") || !hasToken(connection, "http2-settings") {
+		return nil, false
+	}
+	val, found := req.Headers.Get("http2-settings")
+	if !found || strings.Contains(val, ",") {
+		return nil, false // exactly one is required
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.TrimSpace(val), "="))
+	if err != nil {
+		return nil, false
+	}
+	settings, err := ParseSettings(payload)
+	if err != nil {
+		return nil, false
+	}
+	return settings, true
+}
+
+// hasToken reports whether the comma-separated list contains the token, case-insensitively.
+func hasToken(list, token string) bool {
+	for elem := range strings.SplitSeq(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(elem), token) {
+			return true
+		}
+	}
+	return false
+}
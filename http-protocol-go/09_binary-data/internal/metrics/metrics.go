@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+)
+
+// Metrics records server statistics, and exposes them in the Prometheus text
+// exposition format (version 0.0.4).
+type Metrics struct {
+	mu          sync.Mutex
+	requests    map[requestKey]uint64
+	durations   histogram
+	bytesIn     atomic.Uint64
+	bytesOut    atomic.Uint64
+	parseErrors atomic.Uint64
+	activeConns atomic.Int64
+}
+
+type requestKey struct {
+	method string
+	code   int
+}
+
+type histogram struct {
+	buckets []float64 // upper bounds in seconds, ascending
+	counts  []uint64  // cumulative is computed on output, these are per bucket
+	sum     float64
+	count   uint64
+}
+
+// defaultBuckets are latency bounds in seconds, same as the Prometheus client defaults.
+var defaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// knownMethods are the methods kept as label values, others are reported as
+// "OTHER" so clients cannot blow up the number of series.
+var knownMethods = []string{"CONNECT", "DELETE", "GET", "HEAD", "OPTIONS", "PATCH", "POST", "PUT", "TRACE"}
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+func New() *Metrics {
+	return &Metrics{
+		requests: make(map[requestKey]uint64),
+		durations: histogram{
+			buckets: defaultBuckets,
+			counts:  make([]uint64, len(defaultBuckets)),
+		},
+	}
+}
+
+// ConnOpened records a connection being handled.
+func (m *Metrics) ConnOpened() {
+	m.activeConns.Add(1)
+}
+
+// ConnClosed records a connection that is done being handled.
+func (m *Metrics) ConnClosed() {
+	m.activeConns.Add(-1)
+}
+
+// ObserveRequest records a handled request, and the bytes read and written for it.
+func (m *Metrics) ObserveRequest(method string, code response.StatusCode, duration time.Duration, bytesIn, bytesOut int64) {
+	if !slices.Contains(knownMethods, method) {
+		method = "OTHER"
+	}
+	m.bytesIn.Add(uint64(max(bytesIn, 0)))
+	m.bytesOut.Add(uint64(max(bytesOut, 0)))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{method: method, code: int(code)}]++
+	seconds := duration.Seconds()
+	for i, bound := range m.durations.buckets {
+		if seconds <= bound {
+			m.durations.counts[i]++
+			break
+		}
+	}
+	m.durations.sum += seconds
+	m.durations.count++
+}
+
+// ObserveParseError records a request that could not be parsed.
+func (m *Metrics) ObserveParseError(bytesIn, bytesOut int64) {
+	m.parseErrors.Add(1)
+	m.bytesIn.Add(uint64(max(bytesIn, 0)))
+	m.bytesOut.Add(uint64(max(bytesOut, 0)))
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+
+	m.mu.Lock()
+	keys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b requestKey) int {
+		if a.method != b.method {
+			return cmp.Compare(a.method, b.method)
+		}
+		return a.code - b.code
+	})
+	writeHeader(&b, "http_requests_total", "counter", "Total number of HTTP requests by method and status code.")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "http_requests_total{method=%q,code=\"%d\"} %d\n", key.method, key.code, m.requests[key])
+	}
+
+	writeHeader(&b, "http_request_duration_seconds", "histogram", "Time from accepting a connection to finishing its response.")
+	cumulative := uint64(0)
+	for i, bound := range m.durations.buckets {
+		cumulative += m.durations.counts[i]
+		fmt.Fprintf(&b, "http_request_duration_seconds_bucket{le=%q} %d\n", formatFloat(bound), cumulative)
+	}
+	fmt.Fprintf(&b, "http_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.durations.count)
+	fmt.Fprintf(&b, "http_request_duration_seconds_sum %s\n", formatFloat(m.durations.sum))
+	fmt.Fprintf(&b, "http_request_duration_seconds_count %d\n", m.durations.count)
+	m.mu.Unlock()
+
+	writeHeader(&b, "http_request_bytes_total", "counter", "Total bytes read from clients.")
+	fmt.Fprintf(&b, "http_request_bytes_total %d\n", m.bytesIn.Load())
+	writeHeader(&b, "http_response_bytes_total", "counter", "Total bytes written to clients.")
+	fmt.Fprintf(&b, "http_response_bytes_total %d\n", m.bytesOut.Load())
+	writeHeader(&b, "http_parse_errors_total", "counter", "Total number of requests that could not be parsed.")
+	fmt.Fprintf(&b, "http_parse_errors_total %d\n", m.parseErrors.Load())
+	writeHeader(&b, "http_active_connections", "gauge", "Number of connections being handled.")
+	fmt.Fprintf(&b, "http_active_connections %d\n", m.activeConns.Load())
+
+	return b.WriteTo(w)
+}
+
+// Serve is a handler responding with all metrics.
+func (m *Metrics) Serve(w *response.Writer, req *request.Request) {
+	var body bytes.Buffer
+	m.WriteTo(&body)
+	h := headers.NewHeaders()
+	h.Set("Content-Length", strconv.Itoa(body.Len()))
+	h.Set("Connection", "close")
+	h.Set("Content-Type", contentType)
+	w.WriteStatusLine(response.StatusOK)
+	w.WriteHeaders(h)
+	w.WriteBody(body.Bytes())
+}
+
+func writeHeader(b *bytes.Buffer, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTo(t *testing.T) {
+	m := New()
+	m.ConnOpened()
+	m.ConnOpened()
+	m.ConnClosed()
+	m.ObserveRequest("GET", response.StatusOK, 3*time.Millisecond, 100, 1000)
+	m.ObserveRequest("GET", response.StatusOK, 30*time.Millisecond, 100, 1000)
+	m.ObserveRequest("GET", response.StatusNotFound, 2*time.Second, 50, 200)
+	m.ObserveRequest("BREW", response.StatusMethodNotAllowed, 20*time.Second, 50, 200)
+	m.ObserveParseError(10, 90)
+
+	var buf bytes.Buffer
+	_, err := m.WriteTo(&buf)
+	require.NoError(t, err)
+	out := buf.String()
+
+	// Test: Requests by method and status, with unknown methods folded
+	assert.Contains(t, out, "# TYPE http_requests_total counter\n")
+	assert.Contains(t, out, "http_requests_total{method=\"GET\",code=\"200\"} 2\n")
+	assert.Contains(t, out, "http_requests_total{method=\"GET\",code=\"404\"} 1\n")
+	assert.Contains(t, out, "http_requests_total{method=\"OTHER\",code=\"405\"} 1\n")
+
+	// Test: Cumulative latency histogram
+	assert.Contains(t, out, "# TYPE http_request_duration_seconds histogram\n")
+	assert.Contains(t, out, "http_request_duration_seconds_bucket{le=\"0.005\"} 1\n")
+	assert.Contains(t, out, "http_request_duration_seconds_bucket{le=\"0.05\"} 2\n")
+	assert.Contains(t, out, "http_request_duration_seconds_bucket{le=\"2.5\"} 3\n")
+	assert.Contains(t, out, "http_request_duration_seconds_bucket{le=\"10\"} 3\n")
+	assert.Contains(t, out, "http_request_duration_seconds_bucket{le=\"+Inf\"} 4\n")
+	assert.Contains(t, out, "http_request_duration_seconds_sum 22.033\n")
+	assert.Contains(t, out, "http_request_duration_seconds_count 4\n")
+
+	// Test: Bytes, parse errors, and connections
+	assert.Contains(t, out, "http_request_bytes_total 310\n")
+	assert.Contains(t, out, "http_response_bytes_total 2490\n")
+	assert.Contains(t, out, "http_parse_errors_total 1\n")
+	assert.Contains(t, out, "# TYPE http_active_connections gauge\nhttp_active_connections 1\n")
+}
@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/server"
+)
+
+// Options configures the token bucket of each client.
+type Options struct {
+	Rate  float64 // tokens refilled per second
+	Burst int     // bucket capacity, i.e., requests allowed at once
+	// KeyHeader, if set, keys clients by the first address in this header
+	// (e.g., "X-Forwarded-For") instead of the remote IP. Only use it behind
+	// a trusted proxy, since clients can send any value.
+	KeyHeader string
+	// IdleTimeout is how long an unused bucket is kept, defaults to 10 minutes.
+	IdleTimeout time.Duration
+}
+
+// Limiter is a middleware limiting requests per client with token buckets.
+type Limiter struct {
+	opts      Options
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time // replaceable clock for tests
+}
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// Result is the outcome of taking a token from a client bucket.
+type Result struct {
+	Allowed    bool
+	Remaining  int           // whole tokens left in the bucket
+	RetryAfter time.Duration // until a token is available, zero if allowed
+	Reset      time.Duration // until the bucket is full again
+}
+
+const defaultIdleTimeout = 10 * time.Minute
+
+func New(opts Options) *Limiter {
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = defaultIdleTimeout
+	}
+	return &Limiter{
+		opts:    opts,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of the key, if there is one.
+func (l *Limiter) Allow(key string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.opts.Burst), lastSeen: now}
+		l.buckets[key] = b
+	}
+	// Refill based on the time elapsed since the last request
+	elapsed := now.Sub(b.lastSeen).Seconds()
+	b.tokens = math.Min(float64(l.opts.Burst), b.tokens+elapsed*l.opts.Rate)
+	b.lastSeen = now
+
+	result := Result{Allowed: b.tokens >= 1}
+	if result.Allowed {
+		b.tokens--
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = l.duration(float64(l.opts.Burst) - b.tokens)
+	return result
+}
+
+// Len returns the number of buckets currently tracked.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// sweep evicts idle buckets, at most once per idle timeout. An evicted bucket
+// would have been refilled anyway, so this does not change any outcome.
+func (l *Limiter) sweep(now time.Time) {
+	if l.lastSweep.IsZero() {
+		l.lastSweep = now
+	}
+	if now.Sub(l.lastSweep) < l.opts.IdleTimeout {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= l.opts.IdleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// duration returns how long it takes to refill the given amount of tokens.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	if tokens <= 0 || l.opts.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.opts.Rate * float64(time.Second))
+}
+
+// Wrap returns a handler that responds 429 to clients out of tokens, and
+// otherwise calls next. Both carry RateLimit-* headers, based on
+// draft-ietf-httpapi-ratelimit-headers. Clients without an IP, e.g., over a
+// unix socket, are not limited unless KeyHeader identifies them.
+func (l *Limiter) Wrap(next server.Handler) server.Handler {
+	return func(w *response.Writer, req *request.Request) {
+		key := l.key(req)
+		if key == "" {
+			next(w, req)
+			return
+		}
+		result := l.Allow(key)
+		w.SetHeader("RateLimit-Limit", strconv.Itoa(l.opts.Burst))
+		w.SetHeader("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.SetHeader("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if result.Allowed {
+			next(w, req)
+			return
+		}
+
+		body := []byte("Too many requests, please slow down")
+		h := response.GetDefaultHeaders(len(body))
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+		w.WriteStatusLine(response.StatusTooManyRequests)
+		w.WriteHeaders(h)
+		w.WriteBody(body)
+	}
+}
+
+// key returns the client identifier of the request, or an empty string if there is none.
+func (l *Limiter) key(req *request.Request) string {
+	if l.opts.KeyHeader != "" {
+		if val, ok := req.Headers.Get(l.opts.KeyHeader); ok {
+			first, _, _ := strings.Cut(val, ",")
+			if first = strings.TrimSpace(first); first != "" {
+				return first
+			}
+		}
+	}
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil && net.ParseIP(host) != nil {
+		return host
+	}
+	return ""
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced clock.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// okHandler responds with 200 and a fixed body.
+func okHandler(w *response.Writer, req *request.Request) {
+	body := []byte("ok")
+	w.WriteStatusLine(response.StatusOK)
+	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	w.WriteBody(body)
+}
+
+// serveString runs the handler against a raw request from the remote address.
+func serveString(t *testing.T, handler server.Handler, remoteAddr, raw string) string {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader(raw))
+	require.NoError(t, err)
+	req.RemoteAddr = remoteAddr
+	var buf bytes.Buffer
+	handler(response.NewWriter(&buf), req)
+	return buf.String()
+}
+
+func TestAllow(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	l := New(Options{Rate: 2, Burst: 3, IdleTimeout: time.Minute})
+	l.now = clock.Now
+
+	// Test: Burst is allowed, then limited
+	for i := 2; i >= 0; i-- {
+		result := l.Allow("a")
+		require.True(t, result.Allowed)
+		assert.Equal(t, i, result.Remaining)
+	}
+	result := l.Allow("a")
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, result.Reset)
+
+	// Test: Other keys have their own bucket
+	assert.True(t, l.Allow("b").Allowed)
+
+	// Test: Tokens are refilled over time, up to the burst
+	clock.Advance(500 * time.Millisecond)
+	assert.True(t, l.Allow("a").Allowed)
+	assert.False(t, l.Allow("a").Allowed)
+	clock.Advance(time.Hour)
+	assert.Equal(t, 2, l.Allow("a").Remaining)
+
+	// Test: Idle buckets are evicted
+	assert.Equal(t, 1, l.Len())
+	clock.Advance(time.Minute)
+	l.Allow("c")
+	assert.Equal(t, 1, l.Len())
+}
+
+func TestWrap(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	l := New(Options{Rate: 0.5, Burst: 1})
+	l.now = clock.Now
+	handler := l.Wrap(okHandler)
+
+	// Test: Allowed request carries RateLimit headers
+	resp := serveString(t, handler, "10.0.0.1:1234", "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\nok"))
+	assert.Contains(t, resp, "ratelimit-limit: 1\r\n")
+	assert.Contains(t, resp, "ratelimit-remaining: 0\r\n")
+	assert.Contains(t, resp, "ratelimit-reset: 2\r\n")
+
+	// Test: Limited request from same IP but different port
+	resp = serveString(t, handler, "10.0.0.1:5678", "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 429 Too Many Requests\r\n"))
+	assert.Contains(t, resp, "retry-after: 2\r\n")
+
+	// Test: Keyed by header instead of remote address
+	l = New(Options{Rate: 1, Burst: 1, KeyHeader: "X-Forwarded-For"})
+	l.now = clock.Now
+	handler = l.Wrap(okHandler)
+	resp = serveString(t, handler, "10.0.0.1:1", "GET / HTTP/1.1\r\nHost: localhost\r\nX-Forwarded-For: 1.1.1.1, 10.0.0.1\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 200 OK\r\n"))
+	resp = serveString(t, handler, "10.0.0.1:1", "GET / HTTP/1.1\r\nHost: localhost\r\nX-Forwarded-For: 2.2.2.2, 10.0.0.1\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 200 OK\r\n"))
+	resp = serveString(t, handler, "10.0.0.2:1", "GET / HTTP/1.1\r\nHost: localhost\r\nX-Forwarded-For: 1.1.1.1\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 429 Too Many Requests\r\n"))
+
+	// Test: Clients without an IP, e.g., over a unix socket, do not share one bucket
+	for range 3 {
+		resp = serveString(t, handler, "@", "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+		assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 200 OK\r\n"))
+		assert.NotContains(t, resp, "ratelimit-limit")
+	}
+	resp = serveString(t, handler, "@", "GET / HTTP/1.1\r\nHost: localhost\r\nX-Forwarded-For: 1.1.1.1\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 429 Too Many Requests\r\n"))
+}
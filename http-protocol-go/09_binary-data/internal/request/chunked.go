@@ -0,0 +1,93 @@
+package request
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+)
+
+// startChunkedBody validates the framing headers of a chunked body, based on RFC 9112 Section 6.
+func (r *Request) startChunkedBody() error {
+	te, _ := r.Headers.Get("transfer-encoding")
+	if !strings.EqualFold(strings.TrimSpace(te), "chunked") {
+		return fmt.Errorf("unsupported transfer-encoding: %s", te)
+	}
+	// Both framing headers at once is a classic request smuggling vector
+	if _, found := r.Headers.Get("content-length"); found {
+		return fmt.Errorf("both transfer-encoding and content-length are present")
+	}
+	declared, err := r.Headers.DeclaredTrailers()
+	if err != nil {
+		return err
+	}
+	r.declared = declared
+	r.state = isChunkSize
+	return nil
+}
+
+// parseChunkSize parses the chunk size line, based on RFC 9112 Section 7.1.
+func (r *Request) parseChunkSize(data []byte) (int, error) {
+	idx := bytes.Index(data, []byte("\r\n"))
+	if idx == -1 {
+		return 0, nil // need more data
+	}
+	line := string(data[:idx])
+	sizeStr, _, _ := strings.Cut(line, ";") // ignore chunk extensions
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeStr), 16, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid chunk size: %s", line)
+	}
+	if size == 0 {
+		r.state = isTrailers // last chunk
+	} else {
+		r.chunkRemaining = size
+		r.state = isChunkData
+	}
+	return idx + 2, nil
+}
+
+func (r *Request) parseChunkData(data []byte) (int, error) {
+	n := int(min(int64(len(data)), r.chunkRemaining))
+	r.Body = append(r.Body, data[:n]...)
+	r.chunkRemaining -= int64(n)
+	if r.chunkRemaining == 0 {
+		r.state = isChunkDataEnd
+	}
+	return n, nil
+}
+
+func (r *Request) parseChunkDataEnd(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, nil // need more data
+	}
+	if !bytes.HasPrefix(data, []byte("\r\n")) {
+		return 0, fmt.Errorf("missing CRLF after chunk data")
+	}
+	r.state = isChunkSize
+	return 2, nil
+}
+
+// parseTrailers parses the trailer section, and rejects trailer fields that
+// were not declared in the Trailer header or are forbidden.
+func (r *Request) parseTrailers(data []byte) (int, error) {
+	bytesParsed, done, err := r.Trailers.Parse(data)
+	if err != nil {
+		return 0, err
+	}
+	if done {
+		if err := headers.ValidateTrailers(r.declared, r.Trailers); err != nil {
+			return 0, err
+		}
+		r.state = isDone
+	}
+	return bytesParsed, nil
+}
+
+// VerifyContentSHA256 checks the body against the integrity trailers, see
+// headers.VerifyContentSHA256. It is optional, since most clients send none.
+func (r *Request) VerifyContentSHA256() error {
+	return headers.VerifyContentSHA256(r.Body, r.Trailers)
+}
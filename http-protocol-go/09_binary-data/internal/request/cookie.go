@@ -0,0 +1,24 @@
+package request
+
+import "github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/cookie"
+
+// Cookies parses the cookies sent with the request in the Cookie header. As
+// with cookie.Parse, the valid ones are returned even along with an error.
+func (r *Request) Cookies() ([]*cookie.Cookie, error) {
+	val, found := r.Headers.Get("cookie")
+	if !found {
+		return []*cookie.Cookie{}, nil
+	}
+	return cookie.Parse(val)
+}
+
+// Cookie returns the named cookie sent with the request, if any.
+func (r *Request) Cookie(name string) (*cookie.Cookie, bool) {
+	cookies, _ := r.Cookies() // a malformed pair does not hide the others
+	for _, c := range cookies {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
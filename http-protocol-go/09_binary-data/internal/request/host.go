@@ -0,0 +1,64 @@
+package request
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Host returns the validated Host header value, based on RFC 9112 Section 3.2.
+// A request must carry exactly one Host header, though its value may be empty.
+func (r *Request) Host() (string, error) {
+	val, found := r.Headers.Get("host")
+	if !found {
+		return "", fmt.Errorf("missing host header")
+	}
+	// Duplicate headers are folded with commas, which are never valid in a host
+	if strings.Contains(val, ",") {
+		return "", fmt.Errorf("multiple host headers: %s", val)
+	}
+	if err := validateHost(val); err != nil {
+		return "", err
+	}
+	return strings.ToLower(val), nil
+}
+
+// validateHost validates the `uri-host [ ":" port ]` grammar, based on RFC 3986 Section 3.2.
+func validateHost(s string) error {
+	if s == "" {
+		return nil // allowed when the target URI has no authority component
+	}
+	host, port := s, ""
+	if strings.HasPrefix(s, "[") { // IP-literal
+		end := strings.Index(s, "]")
+		if end == -1 {
+			return fmt.Errorf("invalid host: %s", s)
+		}
+		if net.ParseIP(s[1:end]) == nil {
+			return fmt.Errorf("invalid host: %s", s)
+		}
+		host, port = "", s[end+1:]
+		if port != "" && !strings.HasPrefix(port, ":") {
+			return fmt.Errorf("invalid host: %s", s)
+		}
+		port = strings.TrimPrefix(port, ":")
+	} else if i := strings.LastIndex(s, ":"); i != -1 {
+		host, port = s[:i], s[i+1:]
+	}
+	if port != "" {
+		if num, err := strconv.Atoi(port); err != nil || num < 0 || num > 65535 {
+			return fmt.Errorf("invalid port in host: %s", s)
+		}
+	}
+	for _, r := range host {
+		// reg-name is made of unreserved, pct-encoded, and sub-delims characters
+		if (r < 'a' || r > 'z') &&
+			(r < 'A' || r > 'Z') &&
+			(r < '0' || r > '9') &&
+			!strings.ContainsRune("-._~%!$&'()*+;=", r) {
+			return fmt.Errorf("invalid characters in host: %s", s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package request
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NegotiateType returns the offered media type the client prefers in its
+// Accept header, based on RFC 9110 Section 12.5.1, e.g., "application/json".
+// Offers are in the order of preference of the server, which breaks ties.
+// Without the header any offer is acceptable, so the first one is returned.
+// False means nothing offered is acceptable, e.g., for a 406 response.
+func (r *Request) NegotiateType(offers ...string) (string, bool) {
+	return r.negotiate("accept", offers, matchMediaType)
+}
+
+// NegotiateLanguage returns the offered language tag the client prefers in its
+// Accept-Language header, based on RFC 9110 Section 12.5.4. Ranges match
+// tags by prefix, e.g., "en" matches "en-US", based on RFC 4647 Section 3.3.1.
+func (r *Request) NegotiateLanguage(offers ...string) (string, bool) {
+	return r.negotiate("accept-language", offers, matchLanguage)
+}
+
+// NegotiateCharset returns the offered charset the client prefers in its
+// Accept-Charset header, based on RFC 9110 Section 12.5.2.
+func (r *Request) NegotiateCharset(offers ...string) (string, bool) {
+	return r.negotiate("accept-charset", offers, func(rng, offer string) int {
+		if rng == "*" {
+			return 0
+		}
+		if strings.EqualFold(rng, offer) {
+			return 1
+		}
+		return -1
+	})
+}
+
+// acceptRange is an element of an Accept-* header, with its quality value.
+type acceptRange struct {
+	value string // the range, including its parameters but not the weight
+	q     float64
+}
+
+// negotiate picks the offer with the highest quality. The quality of an offer
+// is the one of the most specific range matching it, where match returns the
+// specificity, or -1 if the range does not match.
+func (r *Request) negotiate(header string, offers []string, match func(rng, offer string) int) (string, bool) {
+	if len(offers) == 0 {
+		return "", false
+	}
+	val, found := r.Headers.Get(header)
+	if !found {
+		return offers[0], true
+	}
+	ranges := parseAccept(val)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+		for _, rng := range ranges {
+			if s := match(rng.value, offer); s > specificity {
+				q, specificity = rng.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// parseAccept parses the comma-separated ranges with their optional weight,
+// based on RFC 9110 Section 12.4.2. Ranges with an invalid weight are ignored.
+func parseAccept(val string) []acceptRange {
+	ranges := make([]acceptRange, 0)
+	for elem := range strings.SplitSeq(val, ",") {
+		params := strings.Split(elem, ";")
+		rng := acceptRange{value: strings.TrimSpace(params[0]), q: 1}
+		if rng.value == "" {
+			continue
+		}
+		valid := true
+		for _, p := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if !strings.EqualFold(name, "q") {
+				rng.value += ";" + strings.TrimSpace(p) // a media type parameter
+				continue
+			}
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 || q > 1 || len(value) > 5 {
+				valid = false
+			}
+			rng.q = q
+			break // any parameter after the weight is an extension, see Section 12.5.1
+		}
+		if valid {
+			ranges = append(ranges, rng)
+		}
+	}
+	return ranges
+}
+
+// matchMediaType returns how specifically the media range matches the media
+// type: 0 for "*/*", 1 for "type/*", 2 for "type/subtype", and one more
+// per parameter of the range, which the type must have too.
+func matchMediaType(rng, offer string) int {
+	rngType, rngParams := splitMediaType(rng)
+	offerType, offerParams := splitMediaType(offer)
+	specificity := 0
+	switch {
+	case rngType == "*/*":
+	case strings.HasSuffix(rngType, "/*"):
+		if !strings.HasPrefix(offerType, strings.TrimSuffix(rngType, "*")) {
+			return -1
+		}
+		specificity = 1
+	case rngType == offerType:
+		specificity = 2
+	default:
+		return -1
+	}
+	for name, value := range rngParams {
+		if offerParams[name] != value {
+			return -1
+		}
+		specificity++
+	}
+	return specificity
+}
+
+// splitMediaType returns the lowercase type/subtype and parameters of a media type.
+func splitMediaType(s string) (string, map[string]string) {
+	parts := strings.Split(s, ";")
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		name, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+		params[strings.ToLower(name)] = strings.Trim(value, `"`)
+	}
+	return strings.ToLower(strings.TrimSpace(parts[0])), params
+}
+
+// matchLanguage returns how specifically the language range matches the tag,
+// i.e., the length of the range, or 0 for "*".
+func matchLanguage(rng, offer string) int {
+	if rng == "*" {
+		return 0
+	}
+	rng, offer = strings.ToLower(rng), strings.ToLower(offer)
+	if offer == rng || strings.HasPrefix(offer, rng+"-") {
+		return len(rng)
+	}
+	return -1
+}
@@ -0,0 +1,81 @@
+package request
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requestWith parses a GET request with the extra header lines.
+func requestWith(t *testing.T, extra string) *Request {
+	t.Helper()
+	r, err := RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost\r\n" + extra + "\r\n"))
+	require.NoError(t, err)
+	return r
+}
+
+func TestNegotiateType(t *testing.T) {
+	// Test: Missing header accepts the first offer
+	got, ok := requestWith(t, "").NegotiateType("text/html", "application/json")
+	assert.True(t, ok)
+	assert.Equal(t, "text/html", got)
+
+	// Test: Highest quality wins, the more specific range gives the quality
+	r := requestWith(t, "Accept: text/*;q=0.5, application/json, */*;q=0.1, text/html;q=0\r\n")
+	got, ok = r.NegotiateType("text/html", "text/plain", "application/json")
+	assert.True(t, ok)
+	assert.Equal(t, "application/json", got)
+	got, _ = r.NegotiateType("text/html", "text/plain")
+	assert.Equal(t, "text/plain", got)
+	got, _ = r.NegotiateType("text/html", "image/png")
+	assert.Equal(t, "image/png", got)
+
+	// Test: Ties are broken by the order of offers, case-insensitively
+	got, _ = requestWith(t, "Accept: TEXT/HTML, application/json\r\n").NegotiateType("application/json", "text/html")
+	assert.Equal(t, "application/json", got)
+
+	// Test: Media type parameters must match, extensions after the weight are ignored
+	r = requestWith(t, "Accept: text/plain;charset=utf-8;q=0.8;ext=1, text/plain;q=0.2\r\n")
+	got, _ = r.NegotiateType("text/plain", "text/plain; charset=utf-8")
+	assert.Equal(t, "text/plain; charset=utf-8", got)
+
+	// Test: Nothing acceptable
+	_, ok = requestWith(t, "Accept: image/*\r\n").NegotiateType("text/html", "application/json")
+	assert.False(t, ok)
+	_, ok = requestWith(t, "Accept: text/html;q=0\r\n").NegotiateType("text/html")
+	assert.False(t, ok)
+	_, ok = requestWith(t, "").NegotiateType()
+	assert.False(t, ok)
+
+	// Test: Invalid weights are ignored
+	_, ok = requestWith(t, "Accept: text/html;q=2, text/html;q=0.0001, text/html;q=x\r\n").NegotiateType("text/html")
+	assert.False(t, ok)
+}
+
+func TestNegotiateLanguage(t *testing.T) {
+	// Test: Prefix ranges, with the longest match giving the quality
+	r := requestWith(t, "Accept-Language: en;q=0.8, en-GB;q=0.3, fr, *;q=0.1\r\n")
+	got, ok := r.NegotiateLanguage("en-US", "en-GB")
+	assert.True(t, ok)
+	assert.Equal(t, "en-US", got)
+	got, _ = r.NegotiateLanguage("de", "fr-CA")
+	assert.Equal(t, "fr-CA", got)
+	got, _ = r.NegotiateLanguage("de")
+	assert.Equal(t, "de", got)
+
+	// Test: A range does not match a longer tag that is not a subtag
+	_, ok = requestWith(t, "Accept-Language: en\r\n").NegotiateLanguage("eng")
+	assert.False(t, ok)
+}
+
+func TestNegotiateCharset(t *testing.T) {
+	// Test: Exact match over wildcard
+	r := requestWith(t, "Accept-Charset: iso-8859-1;q=0.5, UTF-8, *;q=0\r\n")
+	got, ok := r.NegotiateCharset("iso-8859-1", "utf-8")
+	assert.True(t, ok)
+	assert.Equal(t, "utf-8", got)
+	_, ok = r.NegotiateCharset("us-ascii")
+	assert.False(t, ok)
+}
@@ -1,6 +1,7 @@
 package request
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -14,6 +15,11 @@ type Request struct {
 	RequestLine RequestLine
 	Headers     headers.Headers
 	Body        []byte
+	Trailers    headers.Headers // only sent with chunked bodies
+	RemoteAddr  string          // address of the client, set by the server
+
+	chunkRemaining int64    // bytes left in the current chunk
+	declared       []string // trailer fields declared in the Trailer header
 }
 type parseState int
 
@@ -23,16 +29,54 @@ const (
 	isRequestLine
 	isHeaders
 	isBody
+	isChunkSize
+	isChunkData
+	isChunkDataEnd
+	isTrailers
 )
 
+// ErrIncomplete means the connection was closed before the request was complete.
+var ErrIncomplete = errors.New("incomplete request")
+
+// ParseError is the error of a malformed or incomplete request, telling which
+// part of the request was being parsed, e.g., "headers". Errors reading from
+// the connection, e.g., timeouts, are returned as is.
+type ParseError struct {
+	Part string
+	Err  error
+}
+
+func (e *ParseError) Error() string { return e.Err.Error() }
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// part names the part of the request parsed in the state.
+func (s parseState) part() string {
+	switch s {
+	case isRequestLine:
+		return "request-line"
+	case isHeaders:
+		return "headers"
+	case isBody:
+		return "body"
+	case isChunkSize, isChunkData, isChunkDataEnd:
+		return "chunked-body"
+	case isTrailers:
+		return "trailers"
+	default:
+		return "done"
+	}
+}
+
 // RequestFromReader reads an HTTP request from the provided io.Reader.
+// Malformed or incomplete requests give a *ParseError.
 func RequestFromReader(reader io.Reader) (*Request, error) {
 	buffer := make([]byte, bufferSize) // buffer to read data into
 	readToIndex := 0                   // keep track how much data we've read
 	request := &Request{
-		state:   isRequestLine,        // initialize the request parse state
-		Headers: headers.NewHeaders(), // initialize headers
-		Body:    make([]byte, 0),      // initialize body
+		state:    isRequestLine,        // initialize the request parse state
+		Headers:  headers.NewHeaders(), // initialize headers
+		Body:     make([]byte, 0),      // initialize body
+		Trailers: headers.NewHeaders(), // initialize trailers
 	}
 	for request.state != isDone {
 		// Grow the buffer if full
@@ -47,7 +91,8 @@ func RequestFromReader(reader io.Reader) (*Request, error) {
 		if bytesRead == 0 && err != nil {
 			if err == io.EOF {
 				if request.state != isDone {
-					return nil, fmt.Errorf("incomplete request, currently in state: %v", request.state)
+					err = fmt.Errorf("%w, currently in state: %v", ErrIncomplete, request.state)
+					return nil, &ParseError{Part: request.state.part(), Err: err}
 				}
 				break
 			}
@@ -58,7 +103,7 @@ func RequestFromReader(reader io.Reader) (*Request, error) {
 		// Parse data we've read so far
 		bytesParsed, err := request.parse(buffer[:readToIndex])
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Part: request.state.part(), Err: err}
 		}
 
 		// Remove data that has been parsed to keep buffer small
@@ -83,11 +128,12 @@ func (r *Request) parse(data []byte) (int, error) {
 		// - This parts could lost if we don't parse it again, e.g., if ONLY one
 		//   parse call is done every read AND next read is EOF.
 		// - Thus we need to handle multiple parse calls gracefully here.
+		prevState := r.state
 		bytesParsed, err := r.parseSingle(data[totalBytesParsed:])
 		if err != nil {
 			return 0, err
 		}
-		if bytesParsed == 0 {
+		if bytesParsed == 0 && r.state == prevState {
 			break // need more data to read
 		}
 		totalBytesParsed += bytesParsed
@@ -120,6 +166,10 @@ func (r *Request) parseSingle(data []byte) (int, error) {
 		}
 		return bytesParsed, nil
 	case isBody:
+		// Chunked transfer coding takes precedence, see chunked.go
+		if _, found := r.Headers.Get("transfer-encoding"); found {
+			return 0, r.startChunkedBody()
+		}
 		// Validate content-length header
 		val, found := r.Headers.Get("content-length")
 		if !found {
@@ -139,6 +189,14 @@ func (r *Request) parseSingle(data []byte) (int, error) {
 			r.state = isDone // move to the final state
 		}
 		return len(data), nil
+	case isChunkSize:
+		return r.parseChunkSize(data)
+	case isChunkData:
+		return r.parseChunkData(data)
+	case isChunkDataEnd:
+		return r.parseChunkDataEnd(data)
+	case isTrailers:
+		return r.parseTrailers(data)
 	default:
 		return 0, fmt.Errorf("unknown parse state: %d", r.state)
 	}
@@ -3,6 +3,8 @@ package request
 import (
 	"bytes"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -33,7 +35,7 @@ func parseRequestLine(data []byte) (*RequestLine, int, error) {
 	if err != nil {
 		return nil, 0, err
 	}
-	target, err := parseRequestTarget(parts[1])
+	target, err := parseRequestTarget(method, parts[1])
 	if err != nil {
 		return nil, 0, err
 	}
@@ -65,14 +67,30 @@ func parseMethod(s string) (string, error) {
 }
 
 // parseRequestTarget validates the request target, based on RFC 9112 Section 3.2.
-func parseRequestTarget(s string) (string, error) {
+func parseRequestTarget(method, s string) (string, error) {
 	// TODO: only accept non-empty string
 	if s == "" {
 		return "", fmt.Errorf("invalid request target: %s", s)
 	}
+	if method == "CONNECT" {
+		return s, validateAuthorityForm(s)
+	}
 	return s, nil
 }
 
+// validateAuthorityForm validates the target of a CONNECT request, which is
+// only the host and port of the tunnel destination, based on RFC 9112 Section 3.2.3.
+func validateAuthorityForm(s string) error {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil || host == "" || strings.Contains(s, "@") {
+		return fmt.Errorf("invalid authority-form target: %s", s)
+	}
+	if num, err := strconv.Atoi(port); err != nil || num < 1 || num > 65535 {
+		return fmt.Errorf("invalid port in authority-form target: %s", s)
+	}
+	return validateHost(s)
+}
+
 // parseHTTPVersion validates the HTTP version, based on RFC 9112 Section 2.3.
 func parseHTTPVersion(s string) (string, error) {
 	// TODO: only accept HTTP/1.1
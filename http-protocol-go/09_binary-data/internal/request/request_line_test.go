@@ -68,3 +68,26 @@ func TestParseRequestLine(t *testing.T) {
 	_, err = RequestFromReader(reader)
 	require.Error(t, err)
 }
+
+func TestParseConnectRequestLine(t *testing.T) {
+	// Test: CONNECT with authority-form
+	for _, target := range []string{"example.com:443", "127.0.0.1:8443", "[::1]:443"} {
+		r, err := RequestFromReader(&chunkReader{
+			data:            "CONNECT " + target + " HTTP/1.1\r\nHost: " + target + "\r\n\r\n",
+			numBytesPerRead: 3,
+		})
+		require.NoError(t, err, target)
+		assert.Equal(t, "CONNECT", r.RequestLine.Method)
+		assert.Equal(t, target, r.RequestLine.RequestTarget)
+	}
+
+	// Test: CONNECT with any other form, or a missing or invalid port
+	for _, target := range []string{"/", "*", "http://example.com:443/", "example.com", "example.com:", "example.com:0",
+		"example.com:99999", "user@example.com:443", ":443", "exa/mple.com:443"} {
+		_, err := RequestFromReader(&chunkReader{
+			data:            "CONNECT " + target + " HTTP/1.1\r\nHost: example.com:443\r\n\r\n",
+			numBytesPerRead: 1024,
+		})
+		require.Error(t, err, target)
+	}
+}
@@ -154,3 +154,237 @@ func TestParseBody(t *testing.T) {
 	require.NotNil(t, r)
 	assert.Empty(t, r.Body)
 }
+
+func TestParseCookies(t *testing.T) {
+	// Test: Cookie header present
+	reader := &chunkReader{
+		data:            "GET / HTTP/1.1\r\nHost: localhost:42069\r\nCookie: session=abc123; theme=dark\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err := RequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	c, found := r.Cookie("theme")
+	require.True(t, found)
+	assert.Equal(t, "dark", c.Value)
+	_, found = r.Cookie("missing")
+	assert.False(t, found)
+
+	// Test: No Cookie header
+	reader = &chunkReader{
+		data:            "GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err = RequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	cookies, err := r.Cookies()
+	require.NoError(t, err)
+	assert.Empty(t, cookies)
+
+	// Test: Malformed pairs do not hide valid cookies
+	reader = &chunkReader{
+		data:            "GET / HTTP/1.1\r\nHost: localhost:42069\r\nCookie: junk; session=abc; bad name=1; theme=dark\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err = RequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	cookies, err = r.Cookies()
+	require.Error(t, err)
+	assert.Len(t, cookies, 2)
+	c, found = r.Cookie("session")
+	require.True(t, found)
+	assert.Equal(t, "abc", c.Value)
+	c, found = r.Cookie("theme")
+	require.True(t, found)
+	assert.Equal(t, "dark", c.Value)
+	_, found = r.Cookie("junk")
+	assert.False(t, found)
+}
+
+func TestParseHost(t *testing.T) {
+	// Test: Valid host with port
+	reader := &chunkReader{
+		data:            "GET / HTTP/1.1\r\nHost: LocalHost:42069\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err := RequestFromReader(reader)
+	require.NoError(t, err)
+	host, err := r.Host()
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:42069", host)
+
+	// Test: Valid IPv6 host
+	reader = &chunkReader{
+		data:            "GET / HTTP/1.1\r\nHost: [::1]:8080\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err = RequestFromReader(reader)
+	require.NoError(t, err)
+	host, err = r.Host()
+	require.NoError(t, err)
+	assert.Equal(t, "[::1]:8080", host)
+
+	// Test: Missing host
+	reader = &chunkReader{
+		data:            "GET / HTTP/1.1\r\nAccept: */*\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err = RequestFromReader(reader)
+	require.NoError(t, err)
+	_, err = r.Host()
+	require.Error(t, err)
+
+	// Test: Duplicate host
+	reader = &chunkReader{
+		data:            "GET / HTTP/1.1\r\nHost: a.com\r\nHost: b.com\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err = RequestFromReader(reader)
+	require.NoError(t, err)
+	_, err = r.Host()
+	require.Error(t, err)
+
+	// Test: Invalid host characters and port
+	for _, invalid := range []string{"exa mple.com", "example.com:http", "[::1", "example.com:70000"} {
+		reader = &chunkReader{
+			data:            "GET / HTTP/1.1\r\nHost: " + invalid + "\r\n\r\n",
+			numBytesPerRead: 3,
+		}
+		r, err = RequestFromReader(reader)
+		require.NoError(t, err)
+		_, err = r.Host()
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestParseChunkedBody(t *testing.T) {
+	// Test: Chunked body with extension
+	reader := &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"5;name=value\r\nhello\r\n" +
+			"6\r\n world\r\n" +
+			"0\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err := RequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "hello world", string(r.Body))
+	assert.Empty(t, r.Trailers)
+
+	// Test: Declared trailers are verified
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"Trailer: X-Content-SHA256, X-Content-Length\r\n" +
+			"\r\n" +
+			"5\r\nhello\r\n" +
+			"0\r\n" +
+			"X-Content-SHA256: 2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824\r\n" +
+			"X-Content-Length: 5\r\n" +
+			"\r\n",
+		numBytesPerRead: 7,
+	}
+	r, err = RequestFromReader(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(r.Body))
+	assert.Equal(t, "5", r.Trailers["x-content-length"])
+	require.NoError(t, r.VerifyContentSHA256())
+	r.Body = []byte("jello")
+	require.Error(t, r.VerifyContentSHA256())
+
+	// Test: Undeclared trailer
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"0\r\nX-Sum: abc\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReader(reader)
+	require.Error(t, err)
+
+	// Test: Forbidden trailer declared
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"Trailer: Content-Length\r\n" +
+			"\r\n" +
+			"0\r\nContent-Length: 5\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReader(reader)
+	require.Error(t, err)
+
+	// Test: Both Transfer-Encoding and Content-Length
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"Content-Length: 5\r\n" +
+			"\r\n" +
+			"0\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReader(reader)
+	require.Error(t, err)
+
+	// Test: Missing CRLF after chunk data
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"2\r\nhello\r\n0\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReader(reader)
+	require.Error(t, err)
+
+	// Test: Body cut short before the last chunk
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"5\r\nhel",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReader(reader)
+	require.Error(t, err)
+}
+
+func TestParseError(t *testing.T) {
+	cases := []struct {
+		data string
+		part string
+	}{
+		{"GET /\r\n\r\n", "request-line"},
+		{"GET / HTTP/1.1\r\nHost localhost\r\n\r\n", "headers"},
+		{"POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: x\r\n\r\n", "body"},
+		{"POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n", "chunked-body"},
+	}
+	for _, c := range cases {
+		// Test: Malformed parts are told apart
+		_, err := RequestFromReader(&chunkReader{data: c.data, numBytesPerRead: 3})
+		var perr *ParseError
+		require.ErrorAs(t, err, &perr, c.data)
+		assert.Equal(t, c.part, perr.Part, c.data)
+		assert.NotErrorIs(t, err, ErrIncomplete, c.data)
+	}
+
+	// Test: Incomplete request
+	_, err := RequestFromReader(&chunkReader{data: "GET / HTTP/1.1\r\nHost: localhost\r\n", numBytesPerRead: 3})
+	var perr *ParseError
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, "headers", perr.Part)
+	assert.ErrorIs(t, err, ErrIncomplete)
+}
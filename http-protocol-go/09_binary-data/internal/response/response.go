@@ -9,15 +9,52 @@ import (
 type StatusCode int
 
 const (
+	StatusContinue            StatusCode = 100
+	StatusSwitchingProtocols  StatusCode = 101
+	StatusEarlyHints          StatusCode = 103
 	StatusOK                  StatusCode = 200
+	StatusNoContent           StatusCode = 204
+	StatusNotModified         StatusCode = 304
 	StatusBadRequest          StatusCode = 400
+	StatusUnauthorized        StatusCode = 401
+	StatusForbidden           StatusCode = 403
+	StatusNotFound            StatusCode = 404
+	StatusMethodNotAllowed    StatusCode = 405
+	StatusNotAcceptable       StatusCode = 406
+	StatusContentTooLarge     StatusCode = 413
+	StatusTooManyRequests     StatusCode = 429
 	StatusInternalServerError StatusCode = 500
+	StatusNotImplemented      StatusCode = 501
+	StatusBadGateway          StatusCode = 502
+	StatusServiceUnavailable  StatusCode = 503
+	StatusGatewayTimeout      StatusCode = 504
 )
 
 var statusText = map[StatusCode]string{
+	StatusContinue:            "Continue",
+	StatusSwitchingProtocols:  "Switching Protocols",
+	StatusEarlyHints:          "Early Hints",
 	StatusOK:                  "OK",
+	StatusNoContent:           "No Content",
+	StatusNotModified:         "Not Modified",
 	StatusBadRequest:          "Bad Request",
+	StatusUnauthorized:        "Unauthorized",
+	StatusForbidden:           "Forbidden",
+	StatusNotFound:            "Not Found",
+	StatusMethodNotAllowed:    "Method Not Allowed",
+	StatusNotAcceptable:       "Not Acceptable",
+	StatusContentTooLarge:     "Content Too Large",
+	StatusTooManyRequests:     "Too Many Requests",
 	StatusInternalServerError: "Internal Server Error",
+	StatusNotImplemented:      "Not Implemented",
+	StatusBadGateway:          "Bad Gateway",
+	StatusServiceUnavailable:  "Service Unavailable",
+	StatusGatewayTimeout:      "Gateway Timeout",
+}
+
+// StatusText returns the reason phrase of the status code, or an empty string if unknown.
+func StatusText(statusCode StatusCode) string {
+	return statusText[statusCode]
 }
 
 func GetDefaultHeaders(contentLen int) headers.Headers {
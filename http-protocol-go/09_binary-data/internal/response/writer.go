@@ -1,15 +1,33 @@
 package response
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"os"
 
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/cookie"
 	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
 )
 
 type Writer struct {
-	writer io.Writer
-	state  writerState
+	writer  io.Writer       // destination as given, e.g., the connection
+	out     *countingWriter // wraps writer to count bytes written
+	state   writerState
+	status  StatusCode
+	extra   headers.Headers // headers queued by middleware, written along with headers
+	cookies []string        // serialized Set-Cookie values, written along with headers
+	discard bool            // whether body bytes are dropped, e.g., for HEAD requests
+	version string          // HTTP version of the request, e.g., "1.0", empty if unknown
+
+	// interim sends interim responses instead of writing them, set by OnInterim
+	interim func(StatusCode, headers.Headers) error
+
+	conn     net.Conn      // set by EnableHijack, nil if the connection cannot be taken over
+	reader   *bufio.Reader // reads the rest of the connection
+	hijacked bool
 }
 type writerState int
 
@@ -23,67 +41,245 @@ const (
 func NewWriter(w io.Writer) *Writer {
 	return &Writer{
 		writer: w,
+		out:    &countingWriter{w: w},
 		state:  isStatusLine,
+		extra:  headers.NewHeaders(),
+	}
+}
+
+// DiscardBody makes the writer drop any body, chunk, and trailer bytes while
+// reporting them as written, so a response to a HEAD request keeps the same
+// headers (including Content-Length) as the equivalent GET response.
+func (w *Writer) DiscardBody() {
+	w.discard = true
+}
+
+// StatusCode returns the status code written, or zero if none was written yet.
+func (w *Writer) StatusCode() StatusCode {
+	return w.status
+}
+
+// BytesWritten returns the number of bytes written to the destination so far.
+func (w *Writer) BytesWritten() int64 {
+	return w.out.n
+}
+
+// EnableHijack lets the handler take the connection over with Hijack. The
+// reader may hold bytes already read from the connection.
+func (w *Writer) EnableHijack(conn net.Conn, r *bufio.Reader) {
+	w.conn = conn
+	w.reader = r
+}
+
+// Hijack hands the connection over, e.g., to tunnel bytes after a CONNECT.
+// The writer may still be used to write the response head, since it writes
+// to the same connection. The handler owns the connection until it returns,
+// then the server closes it.
+func (w *Writer) Hijack() (net.Conn, *bufio.Reader, error) {
+	if w.conn == nil {
+		return nil, nil, errors.New("connection cannot be hijacked")
+	}
+	if w.hijacked {
+		return nil, nil, errors.New("connection already hijacked")
 	}
+	w.hijacked = true
+	return w.conn, w.reader, nil
+}
+
+// SetRequestVersion tells the writer the HTTP version of the request, e.g.,
+// "1.0", since HTTP/1.0 clients do not expect interim responses.
+func (w *Writer) SetRequestVersion(version string) {
+	w.version = version
+}
+
+// OnInterim makes WriteInterim call send instead of writing to the
+// destination, e.g., to send each interim response on an HTTP/2 stream as
+// soon as it is written, while the rest of the response is buffered.
+func (w *Writer) OnInterim(send func(StatusCode, headers.Headers) error) {
+	w.interim = send
+}
+
+// WriteInterim writes an interim (1xx) response ahead of the final one, based
+// on RFC 9110 Section 15.2, e.g., 103 Early Hints with Link headers for the
+// client to preload, or 100 Continue. Any number may be written before the
+// status line. Headers queued with SetHeader and SetCookie are kept for the
+// final response. Nothing is sent to HTTP/1.0 clients, which do not expect
+// interim responses.
+func (w *Writer) WriteInterim(statusCode StatusCode, h headers.Headers) error {
+	if w.state != isStatusLine {
+		return fmt.Errorf("cannot write interim response in state %v", w.state)
+	}
+	if !isInterim(statusCode) {
+		return fmt.Errorf("not an interim status code: %d", statusCode)
+	}
+	if w.version == "1.0" {
+		return nil
+	}
+	if w.interim != nil {
+		return w.interim(statusCode, h)
+	}
+	if _, err := fmt.Fprintf(w.out, "HTTP/1.1 %d %s\r\n", statusCode, statusText[statusCode]); err != nil {
+		return err
+	}
+	for key, value := range h {
+		if _, err := fmt.Fprintf(w.out, "%s: %s\r\n", key, value); err != nil {
+			return err
+		}
+	}
+	_, err := w.out.Write([]byte("\r\n")) // end of the interim response
+	return err
+}
+
+// isInterim reports whether the status code is informational, except 101
+// Switching Protocols, which is final since it ends HTTP/1.1 on the connection.
+func isInterim(statusCode StatusCode) bool {
+	return statusCode >= 100 && statusCode < 200 && statusCode != StatusSwitchingProtocols
 }
 
 func (w *Writer) WriteStatusLine(statusCode StatusCode) error {
+	return w.WriteStatusLineReason(statusCode, statusText[statusCode])
+}
+
+// WriteStatusLineReason writes the status line with a reason phrase other
+// than the usual one, e.g., "Connection Established". Clients ignore it,
+// based on RFC 9112 Section 4.
+func (w *Writer) WriteStatusLineReason(statusCode StatusCode, reason string) error {
 	if w.state != isStatusLine {
 		return fmt.Errorf("cannot write status line in state %v", w.state)
 	}
-	statusLine := fmt.Sprintf("HTTP/1.1 %d %s\r\n", statusCode, statusText[statusCode])
-	_, err := w.writer.Write([]byte(statusLine))
+	if isInterim(statusCode) {
+		return fmt.Errorf("status %d is interim, write it with WriteInterim", statusCode)
+	}
+	statusLine := fmt.Sprintf("HTTP/1.1 %d %s\r\n", statusCode, reason)
+	_, err := w.out.Write([]byte(statusLine))
 	if err == nil {
 		w.state = isHeaders
+		w.status = statusCode
 	}
 	return err
 }
 
-func (w *Writer) WriteHeaders(headers headers.Headers) error {
+func (w *Writer) WriteHeaders(h headers.Headers) error {
 	if w.state != isHeaders {
 		return fmt.Errorf("cannot write headers in state %v", w.state)
 	}
-	for key, value := range headers {
-		if _, err := fmt.Fprintf(w.writer, "%s: %s\r\n", key, value); err != nil {
+	// Merge queued headers, repeated keys are combined as in Headers.Set
+	merged := headers.NewHeaders()
+	for key, value := range w.extra {
+		merged.Set(key, value)
+	}
+	for key, value := range h {
+		merged.Set(key, value)
+	}
+	for key, value := range merged {
+		if _, err := fmt.Fprintf(w.out, "%s: %s\r\n", key, value); err != nil {
+			return err
+		}
+	}
+	// Set-Cookie cannot be combined into a single line, based on RFC 9110 Section 5.3
+	for _, c := range w.cookies {
+		if _, err := fmt.Fprintf(w.out, "set-cookie: %s\r\n", c); err != nil {
 			return err
 		}
 	}
-	_, err := w.writer.Write([]byte("\r\n")) // end of headers
+	_, err := w.out.Write([]byte("\r\n")) // end of headers
 	if err == nil {
 		w.state = isBody
 	}
 	return err
 }
 
+// SetHeader queues a header to be merged into the headers given to
+// WriteHeaders. It lets middleware decorate responses of wrapped handlers.
+func (w *Writer) SetHeader(key, value string) error {
+	if w.state != isStatusLine && w.state != isHeaders {
+		return fmt.Errorf("cannot set header in state %v", w.state)
+	}
+	w.extra.Set(key, value)
+	return nil
+}
+
+// SetCookie queues a cookie to be sent as its own Set-Cookie line when the
+// headers are written, thus it must be called before WriteHeaders.
+func (w *Writer) SetCookie(c *cookie.Cookie) error {
+	if w.state != isStatusLine && w.state != isHeaders {
+		return fmt.Errorf("cannot set cookie in state %v", w.state)
+	}
+	val, err := c.Serialize()
+	if err != nil {
+		return err
+	}
+	w.cookies = append(w.cookies, val)
+	return nil
+}
+
+// Cookies returns the serialized values queued with SetCookie, e.g., for
+// protocols that send each Set-Cookie as its own field.
+func (w *Writer) Cookies() []string {
+	return w.cookies
+}
+
 func (w *Writer) WriteBody(p []byte) (int, error) {
 	if w.state != isBody {
 		return 0, fmt.Errorf("cannot write body in state %v", w.state)
 	}
-	return w.writer.Write(p)
+	if w.discard {
+		return len(p), nil
+	}
+	return w.out.Write(p)
+}
+
+// WriteFileBody writes the rest of the file as the body. When the underlying
+// writer is a TCP connection, the copy is delegated to the kernel (sendfile
+// on Linux), so the file content never passes through user space.
+func (w *Writer) WriteFileBody(f *os.File) (int64, error) {
+	if w.state != isBody {
+		return 0, fmt.Errorf("cannot write body in state %v", w.state)
+	}
+	if w.discard {
+		info, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size() - offset, nil
+	}
+	if conn, ok := w.writer.(*net.TCPConn); ok {
+		n, err := conn.ReadFrom(f)
+		w.out.n += n
+		return n, err
+	}
+	return io.Copy(w.out, f) // fallback, e.g., for buffers in tests
 }
 
 func (w *Writer) WriteChunkedBody(p []byte) (int, error) {
 	if w.state != isBody {
 		return 0, fmt.Errorf("cannot write chunked body in state %v", w.state)
 	}
+	if w.discard {
+		return len(p), nil
+	}
 	nTotal := 0
 
 	// Write the chunk size in hexadecimal format (%x)
-	n, err := fmt.Fprintf(w.writer, "%x\r\n", len(p))
+	n, err := fmt.Fprintf(w.out, "%x\r\n", len(p))
 	if err != nil {
 		return nTotal, err
 	}
 	nTotal += n
 
 	// Write the actual chunk data
-	n, err = w.writer.Write(p)
+	n, err = w.out.Write(p)
 	if err != nil {
 		return nTotal, err
 	}
 	nTotal += n
 
 	// End of each chunk, NOT the end of all chunks
-	n, err = w.writer.Write([]byte("\r\n"))
+	n, err = w.out.Write([]byte("\r\n"))
 	if err != nil {
 		return nTotal, err
 	}
@@ -96,7 +292,11 @@ func (w *Writer) WriteChunkedBodyDone() (int, error) {
 	if w.state != isBody {
 		return 0, fmt.Errorf("cannot write chunked body in state %v", w.state)
 	}
-	n, err := w.writer.Write([]byte("0\r\n"))
+	if w.discard {
+		w.state = isTrailer
+		return 0, nil
+	}
+	n, err := w.out.Write([]byte("0\r\n"))
 	if err == nil {
 		w.state = isTrailer
 	}
@@ -107,11 +307,25 @@ func (w *Writer) WriteTrailer(trailer headers.Headers) error {
 	if w.state != isTrailer {
 		return fmt.Errorf("cannot write trailers in state %v", w.state)
 	}
+	if w.discard {
+		return nil
+	}
 	for key, value := range trailer {
-		if _, err := fmt.Fprintf(w.writer, "%s: %s\r\n", key, value); err != nil {
+		if _, err := fmt.Fprintf(w.out, "%s: %s\r\n", key, value); err != nil {
 			return err
 		}
 	}
-	_, err := w.writer.Write([]byte("\r\n")) // end of anything
+	_, err := w.out.Write([]byte("\r\n")) // end of anything
 	return err
 }
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,206 @@
+package response
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const benchFileSize = 4 << 20 // 4 MiB, roughly a short video clip
+
+// createTempFile creates a file filled with size bytes and returns its path.
+func createTempFile(tb testing.TB, size int) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "body.bin")
+	require.NoError(tb, os.WriteFile(path, bytes.Repeat([]byte("x"), size), 0o644))
+	return path
+}
+
+// tcpPair returns a connected TCP connection whose peer drains everything it receives.
+func tcpPair(tb testing.TB) *net.TCPConn {
+	tb.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(tb, err)
+	tb.Cleanup(func() { l.Close() })
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(tb, err)
+	tb.Cleanup(func() { conn.Close() })
+	return conn.(*net.TCPConn)
+}
+
+// bodyWriter returns a writer that is ready to write the body.
+func bodyWriter(tb testing.TB, w io.Writer) *Writer {
+	tb.Helper()
+	writer := NewWriter(w)
+	require.NoError(tb, writer.WriteStatusLine(StatusOK))
+	require.NoError(tb, writer.WriteHeaders(GetDefaultHeaders(benchFileSize)))
+	return writer
+}
+
+func TestWriteFileBody(t *testing.T) {
+	path := createTempFile(t, 1000)
+
+	// Test: Fallback copy to a non-TCP writer
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var buf bytes.Buffer
+	n, err := bodyWriter(t, &buf).WriteFileBody(f)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), n)
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), bytes.Repeat([]byte("x"), 1000)))
+
+	// Test: Kernel copy to a TCP connection
+	f2, err := os.Open(path)
+	require.NoError(t, err)
+	defer f2.Close()
+	n, err = bodyWriter(t, tcpPair(t)).WriteFileBody(f2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), n)
+
+	// Test: Discarded body reports remaining size without writing
+	f3, err := os.Open(path)
+	require.NoError(t, err)
+	defer f3.Close()
+	buf.Reset()
+	writer := NewWriter(&buf)
+	writer.DiscardBody()
+	require.NoError(t, writer.WriteStatusLine(StatusOK))
+	require.NoError(t, writer.WriteHeaders(GetDefaultHeaders(1000)))
+	headerLen := buf.Len()
+	n, err = writer.WriteFileBody(f3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), n)
+	assert.Equal(t, headerLen, buf.Len())
+
+	// Test: Invalid state
+	_, err = NewWriter(&buf).WriteFileBody(f3)
+	require.Error(t, err)
+}
+
+// BenchmarkWriteBody is the baseline: read the whole file, then write it.
+func BenchmarkWriteBody(b *testing.B) {
+	path := createTempFile(b, benchFileSize)
+	conn := tcpPair(b)
+	b.ReportAllocs()
+	b.SetBytes(benchFileSize)
+	for b.Loop() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := bodyWriter(b, conn).WriteBody(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkWriteFileBody copies the file to the connection with sendfile.
+func BenchmarkWriteFileBody(b *testing.B) {
+	path := createTempFile(b, benchFileSize)
+	conn := tcpPair(b)
+	b.ReportAllocs()
+	b.SetBytes(benchFileSize)
+	for b.Loop() {
+		f, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := bodyWriter(b, conn).WriteFileBody(f); err != nil {
+			b.Fatal(err)
+		}
+		f.Close()
+	}
+}
+
+func TestHijack(t *testing.T) {
+	// Test: Not enabled, e.g., for a buffer
+	_, _, err := NewWriter(&bytes.Buffer{}).Hijack()
+	require.Error(t, err)
+
+	// Test: Connection and reader are handed over once, the head is still written to the connection
+	server, client := net.Pipe()
+	defer client.Close()
+	w := NewWriter(server)
+	br := bufio.NewReader(server)
+	w.EnableHijack(server, br)
+	conn, r, err := w.Hijack()
+	require.NoError(t, err)
+	assert.Equal(t, server, conn)
+	assert.Equal(t, br, r)
+	_, _, err = w.Hijack()
+	require.Error(t, err)
+	go func() {
+		w.WriteStatusLineReason(StatusOK, "Connection Established")
+		w.WriteHeaders(headers.NewHeaders())
+		conn.Close()
+	}()
+	data, err := io.ReadAll(client)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 200 Connection Established\r\n\r\n", string(data))
+	assert.Equal(t, StatusOK, w.StatusCode())
+}
+
+func TestWriteInterim(t *testing.T) {
+	// Test: Interim responses precede the final one, which keeps the queued headers
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.SetHeader("Vary", "Accept"))
+	require.NoError(t, w.WriteInterim(StatusContinue, headers.NewHeaders()))
+	hints := headers.NewHeaders()
+	hints.Set("Link", "</style.css>; rel=preload; as=style")
+	require.NoError(t, w.WriteInterim(StatusEarlyHints, hints))
+	assert.Zero(t, w.StatusCode())
+	require.NoError(t, w.WriteStatusLine(StatusNoContent))
+	require.NoError(t, w.WriteHeaders(headers.NewHeaders()))
+	assert.Equal(t, "HTTP/1.1 100 Continue\r\n\r\n"+
+		"HTTP/1.1 103 Early Hints\r\nlink: </style.css>; rel=preload; as=style\r\n\r\n"+
+		"HTTP/1.1 204 No Content\r\nvary: Accept\r\n\r\n", buf.String())
+	assert.Equal(t, int64(buf.Len()), w.BytesWritten())
+
+	// Test: Not after the status line
+	require.Error(t, w.WriteInterim(StatusEarlyHints, hints))
+
+	// Test: Only interim status codes, which the status line rejects
+	w = NewWriter(&bytes.Buffer{})
+	require.Error(t, w.WriteInterim(StatusOK, hints))
+	require.Error(t, w.WriteInterim(StatusSwitchingProtocols, hints))
+	require.Error(t, w.WriteStatusLine(StatusEarlyHints))
+	require.NoError(t, w.WriteStatusLine(StatusSwitchingProtocols))
+
+	// Test: Nothing is sent to HTTP/1.0 clients
+	buf.Reset()
+	w = NewWriter(&buf)
+	w.SetRequestVersion("1.0")
+	require.NoError(t, w.WriteInterim(StatusEarlyHints, hints))
+	require.NoError(t, w.WriteStatusLine(StatusNoContent))
+	assert.Equal(t, "HTTP/1.1 204 No Content\r\n", buf.String())
+
+	// Test: Sent through the callback instead of written
+	buf.Reset()
+	w = NewWriter(&buf)
+	var sent []StatusCode
+	w.OnInterim(func(statusCode StatusCode, h headers.Headers) error {
+		sent = append(sent, statusCode)
+		return nil
+	})
+	require.NoError(t, w.WriteInterim(StatusEarlyHints, hints))
+	assert.Equal(t, []StatusCode{StatusEarlyHints}, sent)
+	assert.Zero(t, buf.Len())
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/http2"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+)
+
+// WithH2C serves HTTP/2 over cleartext TCP beside HTTP/1.1, to clients that
+// either start with the HTTP/2 preface (prior knowledge) or ask to upgrade
+// with "Upgrade: h2c". Each stream is handled by the same handler.
+func WithH2C() Option {
+	return func(s *Server) {
+		s.h2c = true
+	}
+}
+
+// isHTTP2Preface reports whether the connection starts with the HTTP/2 client
+// preface, without consuming it. It peeks one byte at a time, so it never waits
+// for more bytes than a shorter HTTP/1.1 request has.
+func isHTTP2Preface(br *bufio.Reader) bool {
+	for n := 1; n <= len(http2.ClientPreface); n++ {
+		b, err := br.Peek(n)
+		if err != nil || b[n-1] != http2.ClientPreface[n-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// upgradeHTTP2 switches the connection to HTTP/2, and answers the request as stream 1.
+func (s *Server) upgradeHTTP2(conn net.Conn, r io.Reader, w *response.Writer, req *request.Request, settings []http2.Setting, logger *slog.Logger) {
+	h := headers.NewHeaders()
+	h.Set("Connection", "Upgrade")
+	h.Set("Upgrade", "h2c")
+	if err := w.WriteStatusLine(response.StatusSwitchingProtocols); err != nil {
+		return
+	}
+	if err := w.WriteHeaders(h); err != nil {
+		return
+	}
+	s.serveHTTP2(conn, r, logger, http2.ConnOptions{Upgrade: req, Settings: settings})
+}
+
+// serveHTTP2 serves the connection as HTTP/2, with each stream going through
+// the same checks, metrics, and logs as an HTTP/1.1 request.
+func (s *Server) serveHTTP2(conn net.Conn, r io.Reader, logger *slog.Logger, opts http2.ConnOptions) {
+	opts.Done = s.done
+	opts.Logger = logger
+	handler := func(w *response.Writer, req *request.Request) {
+		start := time.Now()
+		defer func() {
+			logRequest(logger, req, w, start, int64(len(req.Body)))
+			if s.metrics != nil {
+				s.metrics.ObserveRequest(req.RequestLine.Method, w.StatusCode(), time.Since(start), int64(len(req.Body)), w.BytesWritten())
+			}
+		}()
+		if _, err := req.Host(); err != nil {
+			writeError(w, response.StatusBadRequest, fmt.Sprintf("Invalid host: %v", err))
+			return
+		}
+		s.handlerFor(req)(w, req)
+	}
+	if err := http2.ServeConn(conn, r, handler, opts); err != nil {
+		logger.Error("serving HTTP/2", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/http2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readHTTP2Response reads frames until stream 1 ends, and returns its fields and body.
+func readHTTP2Response(t *testing.T, r *bufio.Reader) (map[string]string, string) {
+	t.Helper()
+	dec := http2.NewDecoder()
+	fields := make(map[string]string)
+	var body strings.Builder
+	for {
+		f, err := http2.ReadFrame(r, 1<<14)
+		require.NoError(t, err)
+		if f.StreamID != 1 {
+			continue
+		}
+		switch f.Type {
+		case http2.FrameHeaders:
+			list, err := dec.Decode(f.Payload)
+			require.NoError(t, err)
+			for _, field := range list {
+				fields[field.Name] = field.Value
+			}
+		case http2.FrameData:
+			body.Write(f.Payload)
+		}
+		if f.Has(http2.FlagEndStream) {
+			return fields, body.String()
+		}
+	}
+}
+
+func TestWithH2C(t *testing.T) {
+	s := startServer(t, namedHandler("hello"), WithH2C())
+
+	// Test: HTTP/1.1 is still served
+	resp := readResponse(t, sendRequest(t, s))
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 200 OK\r\n"))
+	assert.True(t, strings.HasSuffix(resp, "hello"))
+
+	// Test: HTTP/2 with prior knowledge
+	conn, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Write([]byte(http2.ClientPreface))
+	require.NoError(t, err)
+	require.NoError(t, http2.WriteFrame(conn, &http2.Frame{Type: http2.FrameSettings}))
+	var enc http2.Encoder
+	block := enc.Encode(nil, []http2.HeaderField{
+		{Name: ":method", Value: "GET"}, {Name: ":scheme", Value: "http"},
+		{Name: ":authority", Value: "localhost"}, {Name: ":path", Value: "/"},
+	})
+	require.NoError(t, http2.WriteFrame(conn, &http2.Frame{
+		Type: http2.FrameHeaders, Flags: http2.FlagEndHeaders | http2.FlagEndStream, StreamID: 1, Payload: block,
+	}))
+	fields, body := readHTTP2Response(t, bufio.NewReader(conn))
+	assert.Equal(t, "200", fields[":status"])
+	assert.Equal(t, "hello", body)
+
+	// Test: Upgrade from HTTP/1.1, the request is answered as stream 1
+	conn = sendRaw(t, s, "GET / HTTP/1.1\r\nHost: localhost\r\n"+
+		"Connection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAMAAABk\r\n\r\n")
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	br := bufio.NewReader(conn)
+	status, err := br.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 101 Switching Protocols\r\n", status)
+	for line := ""; line != "\r\n"; {
+		line, err = br.ReadString('\n')
+		require.NoError(t, err)
+	}
+	_, err = conn.Write([]byte(http2.ClientPreface))
+	require.NoError(t, err)
+	require.NoError(t, http2.WriteFrame(conn, &http2.Frame{Type: http2.FrameSettings}))
+	fields, body = readHTTP2Response(t, br)
+	assert.Equal(t, "200", fields[":status"])
+	assert.Equal(t, "hello", body)
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+)
+
+// HostMux dispatches requests to handlers based on the Host header, so that
+// a single server can serve several sites (virtual hosting).
+type HostMux struct {
+	mu        sync.RWMutex
+	hosts     map[string]Handler // exact hostnames, e.g., "example.com"
+	wildcards map[string]Handler // suffixes of wildcard patterns, e.g., ".example.com"
+	fallback  Handler            // used when no pattern matches, may be nil
+}
+
+func NewHostMux(fallback Handler) *HostMux {
+	return &HostMux{
+		hosts:     make(map[string]Handler),
+		wildcards: make(map[string]Handler),
+		fallback:  fallback,
+	}
+}
+
+// Handle registers the handler for the given host pattern. A pattern is either
+// an exact hostname or a wildcard like "*.example.com", which matches any
+// subdomain (but not "example.com" itself). Ports are not part of patterns.
+func (m *HostMux) Handle(pattern string, handler Handler) error {
+	if handler == nil {
+		return fmt.Errorf("nil handler for host pattern: %s", pattern)
+	}
+	host := normalizeHost(pattern)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if suffix, ok := strings.CutPrefix(host, "*"); ok {
+		if !strings.HasPrefix(suffix, ".") || len(suffix) < 2 || strings.Contains(suffix, "*") {
+			return fmt.Errorf("invalid wildcard host pattern: %s", pattern)
+		}
+		if _, exists := m.wildcards[suffix]; exists {
+			return fmt.Errorf("duplicate host pattern: %s", pattern)
+		}
+		m.wildcards[suffix] = handler
+		return nil
+	}
+	if host == "" || strings.Contains(host, "*") {
+		return fmt.Errorf("invalid host pattern: %s", pattern)
+	}
+	if _, exists := m.hosts[host]; exists {
+		return fmt.Errorf("duplicate host pattern: %s", pattern)
+	}
+	m.hosts[host] = handler
+	return nil
+}
+
+// Serve implements Handler by dispatching to the handler registered for the
+// request host. Exact matches win over wildcards, and longer wildcards win.
+func (m *HostMux) Serve(w *response.Writer, req *request.Request) {
+	if handler := m.match(req); handler != nil {
+		handler(w, req)
+		return
+	}
+	writeError(w, response.StatusNotFound, "No site is configured for this host")
+}
+
+func (m *HostMux) match(req *request.Request) Handler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	val, err := req.Host()
+	if err != nil {
+		return m.fallback
+	}
+	host := normalizeHost(val)
+	if handler, ok := m.hosts[host]; ok {
+		return handler
+	}
+	var best Handler
+	bestLen := 0
+	for suffix, handler := range m.wildcards {
+		if strings.HasSuffix(host, suffix) && len(host) > len(suffix) && len(suffix) > bestLen {
+			best, bestLen = handler, len(suffix)
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return m.fallback
+}
+
+// normalizeHost lowercases the host, and strips the port and trailing dot.
+func normalizeHost(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	} else if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1] // bare IP-literal without port
+	}
+	return strings.TrimSuffix(s, ".")
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveString runs the handler against a raw request and returns the raw response.
+func serveString(t *testing.T, handler Handler, raw string) string {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader(raw))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	handler(response.NewWriter(&buf), req)
+	return buf.String()
+}
+
+// namedHandler responds with 200 and the given name as the body.
+func namedHandler(name string) Handler {
+	return func(w *response.Writer, req *request.Request) {
+		w.WriteStatusLine(response.StatusOK)
+		w.WriteHeaders(response.GetDefaultHeaders(len(name)))
+		w.WriteBody([]byte(name))
+	}
+}
+
+func TestHostMux(t *testing.T) {
+	mux := NewHostMux(namedHandler("fallback"))
+	require.NoError(t, mux.Handle("example.com", namedHandler("exact")))
+	require.NoError(t, mux.Handle("*.example.com", namedHandler("wildcard")))
+	require.NoError(t, mux.Handle("*.api.example.com", namedHandler("api")))
+
+	// Test: Exact match, case-insensitive and ignoring port
+	resp := serveString(t, mux.Serve, "GET / HTTP/1.1\r\nHost: EXAMPLE.com:42069\r\n\r\n")
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\nexact"))
+
+	// Test: Wildcard match on subdomain
+	resp = serveString(t, mux.Serve, "GET / HTTP/1.1\r\nHost: blog.example.com\r\n\r\n")
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\nwildcard"))
+
+	// Test: Longest wildcard wins
+	resp = serveString(t, mux.Serve, "GET / HTTP/1.1\r\nHost: v1.api.example.com\r\n\r\n")
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\napi"))
+
+	// Test: Unknown host uses fallback
+	resp = serveString(t, mux.Serve, "GET / HTTP/1.1\r\nHost: other.org\r\n\r\n")
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\nfallback"))
+
+	// Test: Unknown host without fallback
+	mux = NewHostMux(nil)
+	require.NoError(t, mux.Handle("example.com", namedHandler("exact")))
+	resp = serveString(t, mux.Serve, "GET / HTTP/1.1\r\nHost: other.org\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 404 Not Found\r\n"))
+
+	// Test: Invalid and duplicate patterns
+	require.Error(t, mux.Handle("example.com", namedHandler("again")))
+	require.Error(t, mux.Handle("*example.com", namedHandler("bad")))
+	require.Error(t, mux.Handle("a.*.com", namedHandler("bad")))
+	require.Error(t, mux.Handle("", namedHandler("bad")))
+}
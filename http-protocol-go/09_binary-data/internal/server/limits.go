@@ -0,0 +1,185 @@
+package server
+
+import (
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+)
+
+// ConnLimits caps how many connections the server handles concurrently.
+// Zero values mean no limit.
+type ConnLimits struct {
+	MaxConns      int           // connections being handled at once
+	MaxQueued     int           // connections waiting for a slot once MaxConns is reached, 0 rejects right away
+	MaxConnsPerIP int           // handled and queued connections from a single client IP, if it has one (unix socket peers do not)
+	RetryAfter    time.Duration // advertised in 503 responses, defaults to 1 second
+}
+
+// ConnStats is a snapshot of the server connection counters.
+type ConnStats struct {
+	Active   int64 // connections being handled
+	Queued   int64 // connections waiting for a free slot
+	Rejected int64 // connections turned away with 503 since the server started
+}
+
+const (
+	rejectTimeout    = time.Second // bound on writing a 503 to, and draining, a rejected client
+	rejectDrainLimit = 64 << 10    // bytes of a rejected request to drain before closing
+)
+
+func WithConnLimits(limits ConnLimits) Option {
+	return func(s *Server) {
+		s.conns.limits = limits
+	}
+}
+
+// ConnStats returns the current connection counters of the server.
+func (s *Server) ConnStats() ConnStats {
+	return ConnStats{
+		Active:   s.conns.active.Load(),
+		Queued:   s.conns.queued.Load(),
+		Rejected: s.conns.rejected.Load(),
+	}
+}
+
+// connTracker enforces ConnLimits and keeps the counters for ConnStats.
+type connTracker struct {
+	limits   ConnLimits
+	slots    chan struct{} // semaphore of MaxConns handling slots
+	active   atomic.Int64
+	queued   atomic.Int64
+	rejected atomic.Int64
+	mu       sync.Mutex
+	perIP    map[string]int
+}
+
+func (t *connTracker) init() {
+	if t.limits.MaxConns > 0 {
+		t.slots = make(chan struct{}, t.limits.MaxConns)
+	}
+	if t.limits.RetryAfter <= 0 {
+		t.limits.RetryAfter = time.Second
+	}
+	t.perIP = make(map[string]int)
+}
+
+// dispatch handles the connection right away if there is a free slot, queues it
+// if the queue has room, or otherwise rejects it. It must not block the accept loop.
+func (s *Server) dispatch(conn net.Conn) {
+	ip := clientIP(conn)
+	if !s.conns.acquireIP(ip) {
+		s.reject(conn)
+		return
+	}
+	if s.conns.slots == nil {
+		go s.serve(conn, ip)
+		return
+	}
+	select {
+	case s.conns.slots <- struct{}{}:
+		go s.serve(conn, ip)
+		return
+	default:
+	}
+	// Only this goroutine increments queued, so the check cannot overshoot
+	if s.conns.queued.Load() >= int64(s.conns.limits.MaxQueued) {
+		s.conns.releaseIP(ip)
+		s.reject(conn)
+		return
+	}
+	s.conns.queued.Add(1)
+	go func() {
+		select {
+		case s.conns.slots <- struct{}{}:
+			s.conns.queued.Add(-1)
+			s.serve(conn, ip)
+		case <-s.done:
+			s.conns.queued.Add(-1)
+			s.conns.releaseIP(ip)
+			conn.Close() // server closed while waiting
+		}
+	}()
+}
+
+// serve handles the connection, then releases its slot for the next one.
+func (s *Server) serve(conn net.Conn, ip string) {
+	s.conns.active.Add(1)
+	defer func() {
+		s.conns.active.Add(-1)
+		s.conns.releaseIP(ip)
+		if s.conns.slots != nil {
+			<-s.conns.slots
+		}
+	}()
+	s.handle(conn)
+}
+
+// reject answers with 503 and Retry-After without reading the request, based on RFC 9110 Section 15.6.4.
+func (s *Server) reject(conn net.Conn) {
+	s.conns.rejected.Add(1)
+	s.connLogger(conn).Warn("connection rejected")
+	go func() {
+		defer conn.Close()
+		conn.SetWriteDeadline(time.Now().Add(rejectTimeout))
+		w := response.NewWriter(conn)
+		body := []byte("Server is busy, please retry later")
+		h := response.GetDefaultHeaders(len(body))
+		h.Set("Retry-After", strconv.Itoa(retryAfterSeconds(s.conns.limits.RetryAfter)))
+		w.WriteStatusLine(response.StatusServiceUnavailable)
+		w.WriteHeaders(h)
+		w.WriteBody(body)
+
+		// Closing with an unread request makes the kernel send a reset, which may
+		// drop the 503 before the client reads it, so drain the request first
+		if c, ok := conn.(interface{ CloseWrite() error }); ok {
+			c.CloseWrite()
+		}
+		conn.SetReadDeadline(time.Now().Add(rejectTimeout))
+		io.Copy(io.Discard, io.LimitReader(conn, rejectDrainLimit))
+	}()
+}
+
+// retryAfterSeconds rounds d up to the whole seconds of a Retry-After value, at least 1.
+func retryAfterSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
+
+func (t *connTracker) acquireIP(ip string) bool {
+	if t.limits.MaxConnsPerIP <= 0 || ip == "" {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.perIP[ip] >= t.limits.MaxConnsPerIP {
+		return false
+	}
+	t.perIP[ip]++
+	return true
+}
+
+func (t *connTracker) releaseIP(ip string) {
+	if t.limits.MaxConnsPerIP <= 0 || ip == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.perIP[ip]--; t.perIP[ip] <= 0 {
+		delete(t.perIP, ip) // keep the map small
+	}
+}
+
+// clientIP returns the IP of the remote address, or an empty string if it has
+// none, e.g., the peers of a unix socket, which all share the same address.
+func clientIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil || net.ParseIP(host) == nil {
+		return ""
+	}
+	return host
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer starts a server on an ephemeral loopback port.
+func startServer(t *testing.T, handler Handler, opts ...Option) *Server {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s, err := ServeListener(l, handler, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// sendRequest dials the server and sends a minimal GET request.
+func sendRequest(t *testing.T, s *Server) net.Conn {
+	t.Helper()
+	return sendRaw(t, s, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+}
+
+// sendRaw dials the server and sends the raw request.
+func sendRaw(t *testing.T, s *Server, raw string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	_, err = conn.Write([]byte(raw))
+	require.NoError(t, err)
+	return conn
+}
+
+// readResponse reads the connection until the server closes it.
+func readResponse(t *testing.T, conn net.Conn) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	data, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	return string(data)
+}
+
+// blockingHandler responds only once release is closed.
+func blockingHandler(release chan struct{}) Handler {
+	return func(w *response.Writer, req *request.Request) {
+		<-release
+		namedHandler("done")(w, req)
+	}
+}
+
+func TestConnLimits(t *testing.T) {
+	// Test: Reject with 503 when all slots are busy
+	release := make(chan struct{})
+	s := startServer(t, blockingHandler(release), WithConnLimits(ConnLimits{MaxConns: 1, RetryAfter: 5 * time.Second}))
+	first := sendRequest(t, s)
+	require.Eventually(t, func() bool { return s.ConnStats().Active == 1 }, time.Second, time.Millisecond)
+	resp := readResponse(t, sendRequest(t, s))
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 503 Service Unavailable\r\n"))
+	assert.Contains(t, resp, "retry-after: 5\r\n")
+	assert.Equal(t, int64(1), s.ConnStats().Rejected)
+	close(release)
+	assert.True(t, strings.HasSuffix(readResponse(t, first), "done"))
+	require.Eventually(t, func() bool { return s.ConnStats().Active == 0 }, time.Second, time.Millisecond)
+
+	// Test: Queue when all slots are busy, then handle once a slot frees up
+	release = make(chan struct{})
+	s = startServer(t, blockingHandler(release), WithConnLimits(ConnLimits{MaxConns: 1, MaxQueued: 1}))
+	first = sendRequest(t, s)
+	require.Eventually(t, func() bool { return s.ConnStats().Active == 1 }, time.Second, time.Millisecond)
+	second := sendRequest(t, s)
+	require.Eventually(t, func() bool { return s.ConnStats().Queued == 1 }, time.Second, time.Millisecond)
+	resp = readResponse(t, sendRequest(t, s)) // queue is full
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 503 Service Unavailable\r\n"))
+	assert.Contains(t, resp, "retry-after: 1\r\n")
+	close(release)
+	assert.True(t, strings.HasSuffix(readResponse(t, first), "done"))
+	assert.True(t, strings.HasSuffix(readResponse(t, second), "done"))
+	assert.Equal(t, ConnStats{Active: 0, Queued: 0, Rejected: 1}, s.ConnStats())
+
+	// Test: A promoted connection is no longer counted as queued while it is served
+	release = make(chan struct{})
+	s = startServer(t, blockingHandler(release), WithConnLimits(ConnLimits{MaxConns: 1, MaxQueued: 1}))
+	first = sendRequest(t, s)
+	require.Eventually(t, func() bool { return s.ConnStats().Active == 1 }, time.Second, time.Millisecond)
+	second = sendRequest(t, s)
+	require.Eventually(t, func() bool { return s.ConnStats().Queued == 1 }, time.Second, time.Millisecond)
+	release <- struct{}{} // finish the first request only, the second one takes its slot
+	assert.True(t, strings.HasSuffix(readResponse(t, first), "done"))
+	require.Eventually(t, func() bool { return s.ConnStats() == ConnStats{Active: 1} }, time.Second, time.Millisecond)
+	close(release)
+	assert.True(t, strings.HasSuffix(readResponse(t, second), "done"))
+
+	// Test: Reject when a single client IP has too many connections
+	release = make(chan struct{})
+	s = startServer(t, blockingHandler(release), WithConnLimits(ConnLimits{MaxConnsPerIP: 1}))
+	first = sendRequest(t, s)
+	require.Eventually(t, func() bool { return s.ConnStats().Active == 1 }, time.Second, time.Millisecond)
+	resp = readResponse(t, sendRequest(t, s))
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 503 Service Unavailable\r\n"))
+	close(release)
+	assert.True(t, strings.HasSuffix(readResponse(t, first), "done"))
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	// Test: Whole seconds are kept
+	assert.Equal(t, 5, retryAfterSeconds(5*time.Second))
+
+	// Test: Fractions round up instead of truncating
+	assert.Equal(t, 2, retryAfterSeconds(1500*time.Millisecond))
+
+	// Test: Sub-second durations advertise at least one second
+	assert.Equal(t, 1, retryAfterSeconds(100*time.Millisecond))
+	assert.Equal(t, 1, retryAfterSeconds(0))
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeListener(t *testing.T) {
+	l := NewPipeListener()
+	s, err := ServeListener(l, namedHandler("piped"))
+	require.NoError(t, err)
+
+	// Test: Request over an in-memory connection
+	conn, err := l.Dial()
+	require.NoError(t, err)
+	go conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	data, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "HTTP/1.1 200 OK\r\n"))
+	assert.True(t, strings.HasSuffix(string(data), "piped"))
+	conn.Close()
+
+	// Test: Dial fails once closed
+	require.NoError(t, s.Close())
+	_, err = l.Dial()
+	require.Error(t, err)
+
+	// Test: Invalid arguments
+	_, err = ServeListener(nil, namedHandler("x"))
+	require.Error(t, err)
+	_, err = ServeListener(NewPipeListener(), nil)
+	require.Error(t, err)
+}
+
+func TestListen(t *testing.T) {
+	// Test: Unix domain socket, replacing a stale socket file
+	path := filepath.Join(t.TempDir(), "server.sock")
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	s, err := ServeAddr("unix:"+path, namedHandler("unix"))
+	require.NoError(t, err)
+	defer s.Close()
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	data, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(data), "unix"))
+
+	// Test: A socket a server still accepts on is not taken over
+	_, err = Listen("unix:" + path)
+	require.ErrorIs(t, err, syscall.EADDRINUSE)
+	conn, err = net.Dial("unix", path)
+	require.NoError(t, err)
+	conn.Close()
+
+	// Test: Unix socket peers have no IP, so per-IP limits do not apply
+	path = filepath.Join(t.TempDir(), "limited.sock")
+	s, err = ServeAddr("unix:"+path, namedHandler("unix"), WithConnLimits(ConnLimits{MaxConnsPerIP: 1}))
+	require.NoError(t, err)
+	defer s.Close()
+	idle, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer idle.Close()
+	conn, err = net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	data, err = io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(data), "unix"))
+
+	// Test: TCP bind address
+	l, err := Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	assert.Equal(t, "tcp", l.Addr().Network())
+	l.Close()
+
+	// Test: Invalid bind addresses
+	_, err = Listen("unix:")
+	require.Error(t, err)
+	_, err = Listen("42069")
+	require.Error(t, err)
+}
+
+func TestInheritedListeners(t *testing.T) {
+	// Test: Not set
+	t.Setenv("LISTEN_PID", "")
+	listeners, err := InheritedListeners()
+	require.NoError(t, err)
+	assert.Empty(t, listeners)
+
+	// Test: Meant for another process
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	listeners, err = InheritedListeners()
+	require.NoError(t, err)
+	assert.Empty(t, listeners)
+	_, found := os.LookupEnv("LISTEN_FDS")
+	assert.False(t, found)
+
+	// Test: Invalid count
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "many")
+	_, err = InheritedListeners()
+	require.Error(t, err)
+}
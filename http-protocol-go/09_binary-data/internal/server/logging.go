@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+)
+
+// WithLogger logs accept errors, parse errors, and one access line per request
+// to logger, instead of slog.Default(). Each line of a connection carries its
+// remote address, and connections opening and closing are logged at debug level.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// NewLogger returns a logger writing to w in the format, "text" or "json",
+// from the level on, "debug", "info", "warn", or "error".
+func NewLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level: %s", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format: %s", format)
+	}
+}
+
+// connLogger returns the logger of the connection, with its remote address.
+func (s *Server) connLogger(conn net.Conn) *slog.Logger {
+	return s.logger.With(slog.String("remote_addr", conn.RemoteAddr().String()))
+}
+
+// logRequest logs the access line of an answered request.
+func logRequest(logger *slog.Logger, req *request.Request, w *response.Writer, start time.Time, bytesIn int64) {
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "request",
+		slog.String("method", req.RequestLine.Method),
+		slog.String("target", req.RequestLine.RequestTarget),
+		slog.String("proto", req.RequestLine.HTTPVersion),
+		slog.Int("status", int(w.StatusCode())),
+		slog.Int64("bytes_in", bytesIn),
+		slog.Int64("bytes_out", w.BytesWritten()),
+		slog.Duration("duration", time.Since(start)),
+	)
+}
+
+// logParseError logs a request that could not be parsed, with the kind of error:
+// the part of the request that was malformed, "incomplete" if the client
+// stopped sending before its end, or "read" if reading failed, e.g., a timeout.
+func logParseError(logger *slog.Logger, err error, bytesIn int64) {
+	kind := "read"
+	var perr *request.ParseError
+	if errors.Is(err, request.ErrIncomplete) {
+		kind = "incomplete"
+	} else if errors.As(err, &perr) {
+		kind = perr.Part
+	}
+	logger.LogAttrs(context.Background(), slog.LevelWarn, "request parse error",
+		slog.String("error_kind", kind),
+		slog.String("error", err.Error()),
+		slog.Int64("bytes_in", bytesIn),
+	)
+}
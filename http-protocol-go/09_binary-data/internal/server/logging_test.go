@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logBuffer collects JSON log lines written by the server goroutines.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// entries returns the decoded lines with the message, e.g., "request".
+func (b *logBuffer) entries(t *testing.T, msg string) []map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var entries []map[string]any
+	for line := range strings.Lines(b.buf.String()) {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		if entry["msg"] == msg {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// waitEntries waits until n lines with the message are logged, since the
+// server logs after the response is written.
+func (b *logBuffer) waitEntries(t *testing.T, msg string, n int) []map[string]any {
+	require.Eventually(t, func() bool { return len(b.entries(t, msg)) >= n }, time.Second, 5*time.Millisecond)
+	return b.entries(t, msg)
+}
+
+func TestWithLogger(t *testing.T) {
+	var buf logBuffer
+	logger, err := NewLogger(&buf, "json", "debug")
+	require.NoError(t, err)
+	s := startServer(t, namedHandler("hello"), WithLogger(logger))
+
+	// Test: Access line with the request attributes
+	conn := sendRaw(t, s, "GET /path?q=1 HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	localAddr := conn.LocalAddr().String()
+	readResponse(t, conn)
+	entry := buf.waitEntries(t, "request", 1)[0]
+	assert.Equal(t, "INFO", entry["level"])
+	assert.Equal(t, localAddr, entry["remote_addr"])
+	assert.Equal(t, "GET", entry["method"])
+	assert.Equal(t, "/path?q=1", entry["target"])
+	assert.Equal(t, "1.1", entry["proto"])
+	assert.EqualValues(t, 200, entry["status"])
+	assert.EqualValues(t, len("GET /path?q=1 HTTP/1.1\r\nHost: localhost\r\n\r\n"), entry["bytes_in"])
+	assert.Greater(t, entry["bytes_out"], float64(len("hello")))
+	assert.Contains(t, entry, "duration")
+
+	// Test: Connections are logged at debug level
+	closed := buf.waitEntries(t, "connection closed", 1)[0]
+	assert.Equal(t, "DEBUG", closed["level"])
+	assert.Equal(t, localAddr, closed["remote_addr"])
+	assert.Len(t, buf.entries(t, "connection opened"), 1)
+
+	// Test: Parse errors with their kind
+	readResponse(t, sendRaw(t, s, "GET / HTTP/1.1\r\nHost localhost\r\n\r\n"))
+	entry = buf.waitEntries(t, "request parse error", 1)[0]
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "headers", entry["error_kind"])
+	assert.NotEmpty(t, entry["error"])
+
+	conn = sendRaw(t, s, "GET / HTTP/1.1\r\n")
+	conn.(interface{ CloseWrite() error }).CloseWrite()
+	readResponse(t, conn)
+	entry = buf.waitEntries(t, "request parse error", 2)[1]
+	assert.Equal(t, "incomplete", entry["error_kind"])
+	assert.Len(t, buf.entries(t, "request"), 1) // parse errors are not access lines
+}
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	// Test: Text format filtered by level
+	logger, err := NewLogger(&buf, "text", "warn")
+	require.NoError(t, err)
+	logger.Info("hidden")
+	logger.Warn("shown", "status", 400)
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "level=WARN msg=shown status=400")
+
+	// Test: Invalid format and level
+	_, err = NewLogger(&buf, "xml", "info")
+	assert.Error(t, err)
+	_, err = NewLogger(&buf, "json", "loud")
+	assert.Error(t, err)
+}
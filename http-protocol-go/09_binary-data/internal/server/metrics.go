@@ -0,0 +1,42 @@
+package server
+
+import (
+	"io"
+	"strings"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/metrics"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+)
+
+// WithMetrics records server statistics into m, and serves them at path
+// (e.g., "/metrics") ahead of the handler.
+func WithMetrics(m *metrics.Metrics, path string) Option {
+	return func(s *Server) {
+		s.metrics = m
+		s.metricsPath = path
+	}
+}
+
+// handlerFor returns the handler for the request, which is the server
+// handler unless the request is for the metrics endpoint.
+func (s *Server) handlerFor(req *request.Request) Handler {
+	if s.metrics != nil {
+		path, _, _ := strings.Cut(req.RequestLine.RequestTarget, "?")
+		method := req.RequestLine.Method
+		if path == s.metricsPath && (method == "GET" || method == "HEAD") {
+			return s.metrics.Serve
+		}
+	}
+	return s.handler
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
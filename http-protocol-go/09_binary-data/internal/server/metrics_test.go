@@ -0,0 +1,32 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithMetrics(t *testing.T) {
+	s := startServer(t, namedHandler("hello"), WithMetrics(metrics.New(), "/metrics"))
+
+	// Test: Handler still serves other paths
+	resp := readResponse(t, sendRequest(t, s))
+	assert.True(t, strings.HasSuffix(resp, "hello"))
+
+	// Test: Metrics endpoint with recorded request
+	resp = readResponse(t, sendRaw(t, s, "GET /metrics HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 200 OK\r\n"))
+	assert.Contains(t, resp, "content-type: text/plain; version=0.0.4; charset=utf-8\r\n")
+	assert.Contains(t, resp, "http_requests_total{method=\"GET\",code=\"200\"} 1\n")
+	assert.Contains(t, resp, "http_active_connections 1\n")
+	assert.Contains(t, resp, "http_parse_errors_total 0\n")
+
+	// Test: Parse errors are recorded
+	resp = readResponse(t, sendRaw(t, s, "NOT HTTP\r\n\r\n"))
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 400 Bad Request\r\n"))
+	resp = readResponse(t, sendRaw(t, s, "GET /metrics?debug=1 HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	assert.Contains(t, resp, "http_requests_total{method=\"GET\",code=\"200\"} 2\n")
+	assert.Contains(t, resp, "http_parse_errors_total 1\n")
+}
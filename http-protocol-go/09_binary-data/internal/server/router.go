@@ -0,0 +1,136 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+)
+
+// Router dispatches requests to handlers based on method and path. It also
+// answers HEAD with the GET handler, and OPTIONS with the allowed methods.
+type Router struct {
+	mu     sync.RWMutex
+	routes map[string]map[string]Handler // pattern -> method -> handler
+}
+
+func NewRouter() *Router {
+	return &Router{routes: make(map[string]map[string]Handler)}
+}
+
+// Handle registers the handler for the given method and path pattern. A pattern
+// ending with "/" matches any path under it, e.g., "/httpbin/" matches
+// "/httpbin/get", otherwise the path must match exactly.
+func (r *Router) Handle(method, pattern string, handler Handler) error {
+	if method == "" || strings.ToUpper(method) != method {
+		return fmt.Errorf("invalid method: %s", method)
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("invalid path pattern: %s", pattern)
+	}
+	if handler == nil {
+		return fmt.Errorf("nil handler for %s %s", method, pattern)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.routes[pattern] == nil {
+		r.routes[pattern] = make(map[string]Handler)
+	}
+	if _, exists := r.routes[pattern][method]; exists {
+		return fmt.Errorf("duplicate route: %s %s", method, pattern)
+	}
+	r.routes[pattern][method] = handler
+	return nil
+}
+
+// Serve implements Handler by dispatching to the matching route.
+func (r *Router) Serve(w *response.Writer, req *request.Request) {
+	method := req.RequestLine.Method
+	target := req.RequestLine.RequestTarget
+
+	// OPTIONS with asterisk-form targets the server itself, based on RFC 9110 Section 9.3.7
+	if target == "*" {
+		if method != "OPTIONS" {
+			writeError(w, response.StatusBadRequest, "Asterisk-form is only allowed for OPTIONS")
+			return
+		}
+		writeAllow(w, response.StatusOK, r.allowed(""))
+		return
+	}
+
+	path, _, _ := strings.Cut(target, "?") // query does not take part in routing
+	r.mu.RLock()
+	pattern, found := r.match(path)
+	methods := r.routes[pattern]
+	r.mu.RUnlock()
+	if !found {
+		writeError(w, response.StatusNotFound, "Not found")
+		return
+	}
+
+	if handler, ok := methods[method]; ok {
+		handler(w, req)
+		return
+	}
+	switch method {
+	case "HEAD":
+		if handler, ok := methods["GET"]; ok {
+			w.DiscardBody() // the server does this too, but be explicit
+			handler(w, req)
+			return
+		}
+	case "OPTIONS":
+		writeAllow(w, response.StatusOK, r.allowed(pattern))
+		return
+	}
+	writeAllow(w, response.StatusMethodNotAllowed, r.allowed(pattern))
+}
+
+// match returns the route pattern for the path, preferring an exact match,
+// then the longest matching prefix pattern. Caller must hold the lock.
+func (r *Router) match(path string) (string, bool) {
+	if _, ok := r.routes[path]; ok {
+		return path, true
+	}
+	best := ""
+	for pattern := range r.routes {
+		if strings.HasSuffix(pattern, "/") && strings.HasPrefix(path, pattern) && len(pattern) > len(best) {
+			best = pattern
+		}
+	}
+	return best, best != ""
+}
+
+// allowed returns the sorted methods allowed for the pattern, or for all
+// routes if the pattern is empty. HEAD and OPTIONS are implied.
+func (r *Router) allowed(pattern string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	methods := []string{"OPTIONS"}
+	for p, routes := range r.routes {
+		if pattern != "" && p != pattern {
+			continue
+		}
+		for method := range routes {
+			methods = append(methods, method)
+			if method == "GET" {
+				methods = append(methods, "HEAD")
+			}
+		}
+	}
+	slices.Sort(methods)
+	return slices.Compact(methods)
+}
+
+func writeAllow(w *response.Writer, statusCode response.StatusCode, methods []string) {
+	h := headers.NewHeaders()
+	h.Set("Allow", strings.Join(methods, ", "))
+	h.Set("Content-Length", "0")
+	h.Set("Connection", "close")
+	w.WriteStatusLine(statusCode)
+	w.WriteHeaders(h)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter(t *testing.T) {
+	router := NewRouter()
+	require.NoError(t, router.Handle("GET", "/video", namedHandler("video")))
+	require.NoError(t, router.Handle("POST", "/video", namedHandler("upload")))
+	require.NoError(t, router.Handle("GET", "/httpbin/", namedHandler("httpbin")))
+	require.NoError(t, router.Handle("DELETE", "/admin", namedHandler("admin")))
+
+	// Test: Exact route
+	resp := serveString(t, router.Serve, "POST /video HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\nupload"))
+
+	// Test: Prefix route with query
+	resp = serveString(t, router.Serve, "GET /httpbin/get?x=1 HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\nhttpbin"))
+
+	// Test: HEAD runs GET handler without body but keeps Content-Length
+	resp = serveString(t, router.Serve, "HEAD /video HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 200 OK\r\n"))
+	assert.Contains(t, resp, "content-length: 5\r\n")
+	assert.True(t, strings.HasSuffix(resp, "\r\n\r\n"))
+
+	// Test: OPTIONS lists allowed methods for the route
+	resp = serveString(t, router.Serve, "OPTIONS /video HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 200 OK\r\n"))
+	assert.Contains(t, resp, "allow: GET, HEAD, OPTIONS, POST\r\n")
+
+	// Test: OPTIONS * lists allowed methods for the whole server
+	resp = serveString(t, router.Serve, "OPTIONS * HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Contains(t, resp, "allow: DELETE, GET, HEAD, OPTIONS, POST\r\n")
+
+	// Test: Unregistered method
+	resp = serveString(t, router.Serve, "PUT /video HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 405 Method Not Allowed\r\n"))
+	assert.Contains(t, resp, "allow: GET, HEAD, OPTIONS, POST\r\n")
+
+	// Test: HEAD is not implied without GET
+	resp = serveString(t, router.Serve, "HEAD /admin HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 405 Method Not Allowed\r\n"))
+	assert.Contains(t, resp, "allow: DELETE, OPTIONS\r\n")
+
+	// Test: Unknown path
+	resp = serveString(t, router.Serve, "GET /nope HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 404 Not Found\r\n"))
+
+	// Test: Invalid and duplicate routes
+	require.Error(t, router.Handle("GET", "/video", namedHandler("again")))
+	require.Error(t, router.Handle("get", "/lower", namedHandler("bad")))
+	require.Error(t, router.Handle("GET", "relative", namedHandler("bad")))
+}
@@ -1,42 +1,88 @@
 package server
 
 import (
+	"bufio"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"strconv"
+	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/http2"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/metrics"
 	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
 	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
 )
 
 type Server struct {
-	handler  Handler
-	listener net.Listener
-	isClosed atomic.Bool
+	handler   Handler
+	listener  net.Listener
+	isClosed  atomic.Bool
+	done      chan struct{} // closed along with the server to release waiters
+	closeOnce sync.Once
+	conns     connTracker
+
+	metrics     *metrics.Metrics // nil if metrics are disabled
+	metricsPath string
+	h2c         bool // whether HTTP/2 is served too, see WithH2C
+	logger      *slog.Logger
 }
 
 type Handler func(w *response.Writer, req *request.Request)
 
-func Serve(port int, handler Handler) (*Server, error) {
+// Option configures optional behavior of the server.
+type Option func(*Server)
+
+// Serve starts a server on the given TCP port of all interfaces.
+func Serve(port int, handler Handler, opts ...Option) (*Server, error) {
 	if port < 1 || port > 65535 {
 		return nil, fmt.Errorf("port must be between 1 and 65535, got %d", port)
 	}
-	l, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	return ServeAddr(":"+strconv.Itoa(port), handler, opts...)
+}
+
+// ServeAddr starts a server on the bind address, see Listen for its format.
+func ServeAddr(addr string, handler Handler, opts ...Option) (*Server, error) {
+	l, err := Listen(addr)
 	if err != nil {
 		return nil, err
 	}
+	return ServeListener(l, handler, opts...)
+}
+
+// ServeListener starts a server accepting connections from the listener,
+// which the server owns from now on and closes along with itself.
+func ServeListener(l net.Listener, handler Handler, opts ...Option) (*Server, error) {
+	if l == nil {
+		return nil, fmt.Errorf("listener must not be nil")
+	}
+	if handler == nil {
+		return nil, fmt.Errorf("handler must not be nil")
+	}
 	server := &Server{
 		handler:  handler,
 		listener: l,
+		done:     make(chan struct{}),
+		logger:   slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(server)
 	}
+	server.conns.init()
 	go server.listen()
 	return server, nil
 }
 
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
 func (s *Server) Close() error {
 	s.isClosed.Store(true) // mark server as closed
+	s.closeOnce.Do(func() { close(s.done) })
 	return s.listener.Close()
 }
 
@@ -47,25 +93,74 @@ func (s *Server) listen() {
 			if s.isClosed.Load() {
 				return // exit silently if server is closed
 			}
-			log.Println("Error accepting connection:", err)
+			s.logger.Error("accepting connection", slog.Any("error", err))
 			continue // continue accpting new connections even if one fails
 		}
-		go s.handle(conn)
+		s.dispatch(conn) // admit, queue, or reject based on connection limits
 	}
 }
 
 func (s *Server) handle(conn net.Conn) {
 	defer conn.Close() // ensure connection closed after handling
+	start := time.Now()
+	in := &countingReader{r: conn}
+	br := bufio.NewReader(in) // lets us peek for the HTTP/2 preface
 	w := response.NewWriter(conn)
+	logger := s.connLogger(conn)
+	logger.Debug("connection opened")
+	defer func() {
+		logger.Debug("connection closed", slog.Duration("duration", time.Since(start)))
+	}()
+	if s.metrics != nil {
+		s.metrics.ConnOpened()
+		defer s.metrics.ConnClosed()
+	}
+	if s.h2c && isHTTP2Preface(br) {
+		s.serveHTTP2(conn, br, logger, http2.ConnOptions{})
+		return
+	}
+	w.EnableHijack(conn, br) // e.g., for CONNECT tunnels
 
 	// Parse the request from connection
-	req, err := request.RequestFromReader(conn)
+	req, err := request.RequestFromReader(br)
 	if err != nil {
-		w.WriteStatusLine(response.StatusBadRequest)
-		body := fmt.Appendf(nil, "Error parsing request: %v", err)
-		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
-		w.WriteBody(body)
+		writeError(w, response.StatusBadRequest, fmt.Sprintf("Error parsing request: %v", err))
+		logParseError(logger, err, in.n)
+		if s.metrics != nil {
+			s.metrics.ObserveParseError(in.n, w.BytesWritten())
+		}
+		return
+	}
+	req.RemoteAddr = conn.RemoteAddr().String()
+	w.SetRequestVersion(req.RequestLine.HTTPVersion)
+	if s.h2c {
+		if settings, ok := http2.UpgradeSettings(req); ok {
+			s.upgradeHTTP2(conn, br, w, req, settings, logger) // stream 1 is checked and observed there
+			return
+		}
+	}
+	defer func() {
+		logRequest(logger, req, w, start, in.n)
+		if s.metrics != nil {
+			s.metrics.ObserveRequest(req.RequestLine.Method, w.StatusCode(), time.Since(start), in.n, w.BytesWritten())
+		}
+	}()
+
+	// HTTP/1.1 requests must have exactly one valid Host header
+	if _, err := req.Host(); err != nil {
+		writeError(w, response.StatusBadRequest, fmt.Sprintf("Invalid host: %v", err))
 		return
 	}
-	s.handler(w, req) // handle if no error
+	// HEAD responses never have a body, whatever the handler writes
+	if req.RequestLine.Method == "HEAD" {
+		w.DiscardBody()
+	}
+	s.handlerFor(req)(w, req) // handle if no error
+}
+
+func writeError(w *response.Writer, statusCode response.StatusCode, message string) {
+	w.WriteStatusLine(statusCode)
+	body := []byte(message)
+	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	w.WriteBody(body)
 }
@@ -0,0 +1,186 @@
+package tunnel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/headers"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/server"
+)
+
+// Options configures the CONNECT tunnels.
+type Options struct {
+	// AllowedPorts are the destination ports clients may connect to, defaults
+	// to 443 only, so the proxy cannot reach e.g. mail or database servers.
+	AllowedPorts []int
+	// DialTimeout bounds connecting to the destination, defaults to 10 seconds.
+	DialTimeout time.Duration
+	// IdleTimeout closes a tunnel without traffic in either direction, defaults to 2 minutes.
+	IdleTimeout time.Duration
+	// Logger logs tunnels that ended with an error, defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// Tunnel is a middleware answering CONNECT requests by splicing the client
+// connection with the destination, based on RFC 9110 Section 9.3.6.
+type Tunnel struct {
+	opts   Options
+	dialer net.Dialer
+}
+
+const (
+	defaultDialTimeout = 10 * time.Second
+	defaultIdleTimeout = 2 * time.Minute
+	bufferSize         = 32 << 10
+)
+
+func New(opts Options) *Tunnel {
+	if len(opts.AllowedPorts) == 0 {
+		opts.AllowedPorts = []int{443}
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = defaultDialTimeout
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = defaultIdleTimeout
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Tunnel{opts: opts, dialer: net.Dialer{Timeout: opts.DialTimeout}}
+}
+
+// Wrap returns a handler that tunnels CONNECT requests, and calls next for any other.
+func (t *Tunnel) Wrap(next server.Handler) server.Handler {
+	return func(w *response.Writer, req *request.Request) {
+		if req.RequestLine.Method != "CONNECT" {
+			next(w, req)
+			return
+		}
+		t.Serve(w, req)
+	}
+}
+
+// Serve tunnels a CONNECT request. The request line parser already checked
+// that the target is in authority-form.
+func (t *Tunnel) Serve(w *response.Writer, req *request.Request) {
+	target := req.RequestLine.RequestTarget
+	_, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		writeError(w, response.StatusBadRequest, fmt.Sprintf("Invalid target %s: %v", target, err))
+		return
+	}
+	if port, _ := strconv.Atoi(portStr); !slices.Contains(t.opts.AllowedPorts, port) {
+		writeError(w, response.StatusForbidden, fmt.Sprintf("Port %s is not allowed", portStr))
+		return
+	}
+	// Take the connection over before dialing, so connections that cannot be
+	// hijacked, e.g., HTTP/2 streams, are refused without contacting the destination
+	client, clientReader, err := w.Hijack()
+	if err != nil {
+		writeError(w, response.StatusNotImplemented, "CONNECT is not supported on this connection")
+		return
+	}
+
+	upstream, err := t.dialer.Dial("tcp", target)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			writeError(w, response.StatusGatewayTimeout, fmt.Sprintf("Timeout connecting to %s", target))
+			return
+		}
+		writeError(w, response.StatusBadGateway, fmt.Sprintf("Failed to connect to %s: %v", target, err))
+		return
+	}
+	defer upstream.Close()
+
+	// A 2xx response to CONNECT has no content, nor framing headers, based on RFC 9110 Section 9.3.6
+	if err := w.WriteStatusLineReason(response.StatusOK, "Connection Established"); err != nil {
+		return
+	}
+	if err := w.WriteHeaders(headers.NewHeaders()); err != nil {
+		return
+	}
+	if err := t.splice(client, clientReader, upstream); err != nil {
+		t.opts.Logger.Warn("tunnel error", slog.String("remote_addr", req.RemoteAddr), slog.String("target", target), slog.Any("error", err))
+	}
+}
+
+// splice copies bytes in both directions until both are done, or the tunnel
+// is idle. The client reader may hold bytes already read from the client.
+func (t *Tunnel) splice(client net.Conn, clientReader io.Reader, upstream net.Conn) error {
+	var lastActive atomic.Int64
+	lastActive.Store(time.Now().UnixNano())
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		errs[0] = t.copy(upstream, client, clientReader, &lastActive)
+	}()
+	go func() {
+		defer wg.Done()
+		errs[1] = t.copy(client, upstream, upstream, &lastActive)
+	}()
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// copy copies from src, read through r, to dst. A read timing out only ends
+// the copy if the other direction was idle too. Once src is done, the write
+// side of dst is closed, so its peer sees the end of the stream as well.
+func (t *Tunnel) copy(dst, src net.Conn, r io.Reader, lastActive *atomic.Int64) error {
+	buf := make([]byte, bufferSize)
+	for {
+		src.SetReadDeadline(time.Now().Add(t.opts.IdleTimeout))
+		n, err := r.Read(buf)
+		if n > 0 {
+			lastActive.Store(time.Now().UnixNano())
+			dst.SetWriteDeadline(time.Now().Add(t.opts.IdleTimeout))
+			if _, err := dst.Write(buf[:n]); err != nil {
+				src.Close() // unblock the other direction
+				return err
+			}
+		}
+		switch {
+		case err == nil:
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			idle := time.Since(time.Unix(0, lastActive.Load()))
+			if idle < t.opts.IdleTimeout {
+				continue
+			}
+			dst.Close() // the other direction is idle too, end it
+			return nil
+		case errors.Is(err, io.EOF):
+			if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+				cw.CloseWrite()
+				return nil
+			}
+			dst.Close()
+			return nil
+		case errors.Is(err, net.ErrClosed):
+			return nil // closed by the other direction
+		default:
+			dst.Close()
+			return err
+		}
+	}
+}
+
+func writeError(w *response.Writer, statusCode response.StatusCode, message string) {
+	w.WriteStatusLine(statusCode)
+	body := []byte(message)
+	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	w.WriteBody(body)
+}
@@ -0,0 +1,171 @@
+package tunnel
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/request"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/response"
+	"github.com/akhdanfadh/bootdev-courses/http-protocol-go/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// okHandler responds with 200 and a fixed body.
+func okHandler(w *response.Writer, req *request.Request) {
+	body := []byte("ok")
+	w.WriteStatusLine(response.StatusOK)
+	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	w.WriteBody(body)
+}
+
+// startDestination accepts connections and serves each one with the function.
+func startDestination(t *testing.T, serve func(net.Conn)) (string, int) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				serve(conn)
+			}()
+		}
+	}()
+	return l.Addr().String(), l.Addr().(*net.TCPAddr).Port
+}
+
+// echo writes back everything it reads, then closes.
+func echo(conn net.Conn) {
+	io.Copy(conn, conn)
+}
+
+// startProxy starts a server tunneling with the options, and calling okHandler otherwise.
+func startProxy(t *testing.T, opts Options) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s, err := server.ServeListener(l, New(opts).Wrap(okHandler))
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s.Addr().String()
+}
+
+// connect sends a CONNECT request to the proxy, and returns the connection and the response head.
+func connect(t *testing.T, proxy, target string) (net.Conn, *bufio.Reader, string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", proxy)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Write([]byte("CONNECT " + target + " HTTP/1.1\r\nHost: " + target + "\r\n\r\n"))
+	require.NoError(t, err)
+	br := bufio.NewReader(conn)
+	var head strings.Builder
+	for {
+		line, err := br.ReadString('\n')
+		require.NoError(t, err)
+		head.WriteString(line)
+		if line == "\r\n" {
+			return conn, br, head.String()
+		}
+	}
+}
+
+func TestTunnel(t *testing.T) {
+	echoAddr, echoPort := startDestination(t, echo)
+	proxy := startProxy(t, Options{AllowedPorts: []int{echoPort}})
+
+	// Test: Bytes are spliced in both directions after the 200
+	conn, br, head := connect(t, proxy, echoAddr)
+	assert.Equal(t, "HTTP/1.1 200 Connection Established\r\n\r\n", head)
+	_, err := conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(br, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+
+	// Test: Closing the write side reaches the destination, whose close reaches the client
+	require.NoError(t, conn.(*net.TCPConn).CloseWrite())
+	rest, err := io.ReadAll(br)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+
+	// Test: Other methods go to the wrapped handler
+	conn, err = net.Dial("tcp", proxy)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	data, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(data), "\r\n\r\nok"))
+}
+
+func TestTunnelErrors(t *testing.T) {
+	_, echoPort := startDestination(t, echo)
+	proxy := startProxy(t, Options{AllowedPorts: []int{echoPort, 1}})
+
+	// Test: Port outside the allow-list
+	_, _, head := connect(t, proxy, "127.0.0.1:22")
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 403 Forbidden\r\n"))
+
+	// Test: Default allow-list is 443 only
+	_, _, head = connect(t, startProxy(t, Options{}), "127.0.0.1:"+strconv.Itoa(echoPort))
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 403 Forbidden\r\n"))
+
+	// Test: Unreachable destination
+	_, _, head = connect(t, proxy, "127.0.0.1:1")
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 502 Bad Gateway\r\n"))
+
+	// Test: Connection that cannot be hijacked, e.g., an HTTP/2 stream
+	req, err := request.RequestFromReader(strings.NewReader("CONNECT 127.0.0.1:443 HTTP/1.1\r\nHost: 127.0.0.1:443\r\n\r\n"))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	New(Options{}).Serve(response.NewWriter(&buf), req)
+	assert.True(t, strings.HasPrefix(buf.String(), "HTTP/1.1 501 Not Implemented\r\n"))
+}
+
+func TestTunnelIdleTimeout(t *testing.T) {
+	// Destination that sends a byte every 30ms, ten times, but never reads
+	tickAddr, tickPort := startDestination(t, func(conn net.Conn) {
+		for range 10 {
+			time.Sleep(30 * time.Millisecond)
+			if _, err := conn.Write([]byte("x")); err != nil {
+				return
+			}
+		}
+		time.Sleep(time.Second) // then idle, without closing
+	})
+	silentAddr, silentPort := startDestination(t, func(conn net.Conn) {
+		time.Sleep(time.Second)
+	})
+	proxy := startProxy(t, Options{AllowedPorts: []int{tickPort, silentPort}, IdleTimeout: 100 * time.Millisecond})
+
+	// Test: Idle tunnel is closed
+	start := time.Now()
+	_, br, _ := connect(t, proxy, silentAddr)
+	_, err := io.ReadAll(br)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// Test: Traffic in one direction keeps the tunnel open, until it stops
+	start = time.Now()
+	_, br, _ = connect(t, proxy, tickAddr)
+	data, err := io.ReadAll(br)
+	require.NoError(t, err)
+	assert.Equal(t, "xxxxxxxxxx", string(data))
+	assert.Less(t, time.Since(start), 800*time.Millisecond)
+}
@@ -1,10 +1,11 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"log"
 	"net/http"
-	"slices"
 	"strings"
 	"time"
 
@@ -15,11 +16,74 @@ import (
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	UserID    uuid.UUID `json:"user_id"`
-	Body      string    `json:"body"`
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Body       string     `json:"body"`
+	ParentID   *uuid.UUID `json:"parent_id"`   // the chirp replied to, null if none
+	ReplyCount int64      `json:"reply_count"` // replies not deleted, see withReplyCounts
+}
+
+// chirpRow is a chirp selected without its search vector, sqlc generates the
+// same row type for each query listing these columns
+type chirpRow interface {
+	database.CreateChirpRow | database.GetChirpRow | database.ListChirpsAscRow |
+		database.ListChirpsDescRow | database.ListTimelineRow
+}
+
+// newChirp converts a database chirp to its JSON payload, without its reply count
+func newChirp[T chirpRow](row T) Chirp {
+	chirp := database.GetChirpRow(row)
+	return Chirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		UserID:    chirp.UserID,
+		Body:      chirp.Body,
+		ParentID:  uuidPtr(chirp.ParentID),
+	}
+}
+
+// uuidPtr returns the UUID, or nil if NULL, so it is encoded as null in JSON
+func uuidPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+// newChirps converts database chirps, an empty slice rather than nil for JSON
+func newChirps[T chirpRow](chirps []T) []Chirp {
+	formatted := make([]Chirp, len(chirps))
+	for i, chirp := range chirps {
+		formatted[i] = newChirp(chirp)
+	}
+	return formatted
+}
+
+// withReplyCounts sets the reply count of n chirps in one query, chirp returns
+// the i-th one, e.g., of a page or of search results
+func (c *apiConfig) withReplyCounts(ctx context.Context, n int, chirp func(i int) *Chirp) error {
+	if n == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, n)
+	for i := range n {
+		ids[i] = chirp(i).ID
+	}
+	rows, err := c.db.CountReplies(ctx, ids)
+	if err != nil {
+		return err
+	}
+	counts := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ParentID.UUID] = row.ReplyCount
+	}
+	for i := range n {
+		chirp(i).ReplyCount = counts[chirp(i).ID]
+	}
+	return nil
 }
 
 // bannedWordsMap is a set of banned words (Go does not have Set as in Python)
@@ -29,61 +93,63 @@ var bannedWordsMap = map[string]bool{
 	"fornax":    true,
 }
 
-// handlerGetChirps is an HTTP handler function to get all chirps
+// handlerGetChirps is an HTTP handler function to get a page of chirps
 func (c *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 	userIDString := r.URL.Query().Get("author_id") // optional, could be empty
 	sortDirection := r.URL.Query().Get("sort")     // optional, could be empty
-	var chirps []database.Chirp
-	var err error
 
 	// Validate sort direction
 	if sortDirection != "" && sortDirection != "asc" && sortDirection != "desc" {
 		respondJson(w, http.StatusBadRequest, errorResponse{Error: "Invalid sort direction"})
 		return
 	}
-
-	// IF: Get all chirps from the database
-	if userIDString == "" {
-		chirps, err = c.db.GetChirps(r.Context())
-		if err != nil {
-			log.Println("Error getting chirps from database:", err)
-			respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error"})
-			return
-		}
-
-		// ELSE: Get chirps filtered by user ID
-	} else {
-		// Parse user ID from the query parameter
-		userID, err := uuid.Parse(userIDString)
+	// Parse user ID from the query parameter, if filtering by author
+	var userID uuid.NullUUID
+	if userIDString != "" {
+		id, err := uuid.Parse(userIDString)
 		if err != nil {
 			respondJson(w, http.StatusBadRequest, errorResponse{Error: "Invalid user ID format"})
 			return
 		}
-		// Get the chirps
-		chirps, err = c.db.GetChirpsPerUser(r.Context(), userID)
-		if err != nil {
-			log.Println("Error getting chirps from database:", err)
-			respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error"})
-			return
-		}
+		userID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	// Validate limit and cursor
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondJson(w, http.StatusBadRequest, errorResponse{Error: "Invalid pagination: " + err.Error()})
+		return
 	}
 
-	// Store chirps in a slice of Chirp structs
-	formattedChirps := make([]Chirp, len(chirps))
+	// Get the page from the database, sorted there so the indexes are used
+	params := database.ListChirpsAscParams{
+		UserID:         userID,
+		AfterCreatedAt: page.afterCreatedAt(),
+		AfterID:        page.afterID(),
+		Limit:          page.queryLimit(),
+	}
+	var chirps []Chirp
 	if sortDirection == "desc" {
-		slices.Reverse(chirps)
+		var rows []database.ListChirpsDescRow
+		rows, err = c.db.ListChirpsDesc(r.Context(), database.ListChirpsDescParams(params))
+		chirps = newChirps(rows)
+	} else {
+		var rows []database.ListChirpsAscRow
+		rows, err = c.db.ListChirpsAsc(r.Context(), params)
+		chirps = newChirps(rows)
 	}
-	for i, chirp := range chirps {
-		formattedChirps[i] = Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			UserID:    chirp.UserID,
-			Body:      chirp.Body,
-		}
+	if err != nil {
+		log.Println("Error getting chirps from database:", err)
+		respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error"})
+		return
+	}
+	resp := newChirpPage(chirps, page)
+	if err := c.withReplyCounts(r.Context(), len(resp.Chirps), func(i int) *Chirp { return &resp.Chirps[i] }); err != nil {
+		log.Println("Error counting replies in database:", err)
+		respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error"})
+		return
 	}
 
-	respondJson(w, http.StatusOK, formattedChirps)
+	respondJson(w, http.StatusOK, resp)
 }
 
 // handlerGetChirp is an HTTP handler function to get a specific chirp by ID
@@ -97,27 +163,28 @@ func (c *apiConfig) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the chirp from the database
+	// Get the chirp from the database, deleted ones only remain in their thread
 	chirp, err := c.db.GetChirp(r.Context(), chirpID)
-	if err != nil {
+	if err != nil || chirp.DeletedAt.Valid {
 		respondJson(w, http.StatusNotFound, errorResponse{Error: "Chirp not found"})
 		return
 	}
+	resp := newChirp(chirp)
+	if err := c.withReplyCounts(r.Context(), 1, func(int) *Chirp { return &resp }); err != nil {
+		log.Println("Error counting replies in database:", err)
+		respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error"})
+		return
+	}
 
-	respondJson(w, http.StatusOK, Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		UserID:    chirp.UserID,
-		Body:      chirp.Body,
-	})
+	respondJson(w, http.StatusOK, resp)
 }
 
 // handlerAddChirp is an HTTP handler function to add a chirp
 func (c *apiConfig) handlerAddChirp(w http.ResponseWriter, r *http.Request) {
 	// JSON structs for request and responses
 	type validRequest struct {
-		Body string `json:"body"`
+		Body     string     `json:"body"`
+		ParentID *uuid.UUID `json:"parent_id"` // optional, the chirp replied to
 	}
 
 	// Decode the request
@@ -155,11 +222,20 @@ func (c *apiConfig) handlerAddChirp(w http.ResponseWriter, r *http.Request) {
 	}
 	cleaned := cleanChirp(request.Body)
 
-	// Store on database
+	// Store on database, along with a reply only if its parent is not deleted
+	var parentID uuid.NullUUID
+	if request.ParentID != nil {
+		parentID = uuid.NullUUID{UUID: *request.ParentID, Valid: true}
+	}
 	chirp, err := c.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		UserID: userID,
-		Body:   cleaned,
+		UserID:   userID,
+		Body:     cleaned,
+		ParentID: parentID,
 	})
+	if err == sql.ErrNoRows {
+		respondJson(w, http.StatusNotFound, errorResponse{Error: "Parent chirp not found"})
+		return
+	}
 	if err != nil {
 		// Check for invalid user ID
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
@@ -172,13 +248,7 @@ func (c *apiConfig) handlerAddChirp(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// If all good, return the chirp data
-	respondJson(w, http.StatusCreated, Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		UserID:    chirp.UserID,
-		Body:      chirp.Body,
-	})
+	respondJson(w, http.StatusCreated, newChirp(chirp))
 }
 
 // handlerDeleteChirp is an HTTP handler function to delete a chirp by ID
@@ -204,9 +274,20 @@ func (c *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the chirp from the database
-	chirp, err := c.db.GetChirp(r.Context(), chirpID)
+	// Delete in a transaction locking the chirp, so no reply can be added to it
+	// between checking for replies and deleting it
+	tx, err := c.dbConn.BeginTx(r.Context(), nil)
 	if err != nil {
+		log.Println("Error starting transaction:", err)
+		respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error"})
+		return
+	}
+	defer tx.Rollback() // no-op after commit
+	qtx := c.db.WithTx(tx)
+
+	// Get the chirp from the database
+	chirp, err := qtx.GetChirpForUpdate(r.Context(), chirpID)
+	if err != nil || chirp.DeletedAt.Valid {
 		respondJson(w, http.StatusNotFound, errorResponse{Error: "Chirp not found"})
 		return
 	}
@@ -216,9 +297,20 @@ func (c *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete the chirp from the database
-	err = c.db.DeleteChirpByID(r.Context(), chirpID)
+	// A chirp without replies is deleted from the database, otherwise it is
+	// only emptied and marked deleted, so its thread stays whole
+	deleted, err := qtx.DeleteChirpWithoutReplies(r.Context(), chirpID)
+	if err == nil && deleted == 0 {
+		err = qtx.SoftDeleteChirp(r.Context(), chirpID)
+	}
+	if err == nil && deleted > 0 {
+		err = deleteEmptyAncestors(r.Context(), qtx, chirp.ParentID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
+		log.Println("Error deleting chirp from database:", err)
 		respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error: delete chirp failed"})
 		return
 	}
@@ -227,6 +319,28 @@ func (c *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// deleteEmptyAncestors deletes the parent of a deleted chirp if it was marked
+// deleted and has no replies left, then its parent the same way, and so on.
+// Each parent is locked first, so two replies deleted at once cannot both see
+// the other one and leave their parent behind.
+func deleteEmptyAncestors(ctx context.Context, qtx *database.Queries, parentID uuid.NullUUID) error {
+	for parentID.Valid {
+		parent, err := qtx.GetChirpForUpdate(ctx, parentID.UUID)
+		if err != nil {
+			return err
+		}
+		if !parent.DeletedAt.Valid {
+			return nil
+		}
+		deleted, err := qtx.DeleteChirpWithoutReplies(ctx, parent.ID)
+		if err != nil || deleted == 0 {
+			return err
+		}
+		parentID = parent.ParentID
+	}
+	return nil
+}
+
 // cleanChirp is a function to clean the chirp text by replacing banned words with ****
 func cleanChirp(chirp string) string {
 	// Split on whitespace, change banned to ****, then join
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"database/sql"
 	"log"
 	"os"
 	"sync/atomic"
@@ -18,6 +19,7 @@ type envConfig struct {
 type apiConfig struct {
 	fileserverHits atomic.Int32      // atomic allows to safely use value across goroutines
 	db             *database.Queries // sqlc-generated-struct to interact with the database
+	dbConn         *sql.DB           // underlying connection, to run queries in a transaction
 	platform       string            // environment of running application, e.g. "dev", "prod"
 	JwtSecret      string            // secret key for JWT signing
 	PolkaKey       string            // key for Polka webhook
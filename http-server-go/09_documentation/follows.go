@@ -0,0 +1,217 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-server-go/internal/auth"
+	"github.com/akhdanfadh/bootdev-courses/http-server-go/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// FollowedUser is a user in a follower or following list, without the email
+// since the lists are public
+type FollowedUser struct {
+	ID         uuid.UUID `json:"id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// followPage is the response envelope of the follower and following lists, see chirpPage
+type followPage struct {
+	Users      []FollowedUser `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// handlerFollowUser is an HTTP handler function for the authenticated user to follow another
+func (c *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	// Parse the user ID to follow from the path
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondJson(w, http.StatusBadRequest, errorResponse{Error: "Invalid user ID format"})
+		return
+	}
+
+	// Get Bearer token from the request headers
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondJson(w, http.StatusUnauthorized, errorResponse{Error: "No access token provided"})
+		return
+	}
+	// Validate access token and get user ID
+	userID, err := auth.ValidateJWT(token, c.JwtSecret)
+	if err != nil {
+		respondJson(w, http.StatusUnauthorized, errorResponse{Error: "Unauthorized"})
+		return
+	}
+	if userID == followeeID {
+		respondJson(w, http.StatusBadRequest, errorResponse{Error: "You cannot follow yourself"})
+		return
+	}
+
+	// Store on database, following twice is a no-op
+	err = c.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		// Check for unknown user ID
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			respondJson(w, http.StatusNotFound, errorResponse{Error: "User not found"})
+			return
+		}
+		log.Println("Error adding follow to database:", err)
+		respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error"})
+		return
+	}
+
+	// If all good, return 204
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerUnfollowUser is an HTTP handler function for the authenticated user to unfollow another
+func (c *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	// Parse the user ID to unfollow from the path
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondJson(w, http.StatusBadRequest, errorResponse{Error: "Invalid user ID format"})
+		return
+	}
+
+	// Get Bearer token from the request headers
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondJson(w, http.StatusUnauthorized, errorResponse{Error: "No access token provided"})
+		return
+	}
+	// Validate access token and get user ID
+	userID, err := auth.ValidateJWT(token, c.JwtSecret)
+	if err != nil {
+		respondJson(w, http.StatusUnauthorized, errorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	// Delete from database, unfollowing a user not followed is a no-op
+	err = c.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		log.Println("Error deleting follow from database:", err)
+		respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error"})
+		return
+	}
+
+	// If all good, return 204
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerGetFollowers is an HTTP handler function to list the followers of a user, newest first
+func (c *apiConfig) handlerGetFollowers(w http.ResponseWriter, r *http.Request) {
+	c.respondFollowList(w, r, func(userID uuid.UUID, page pageParams) ([]FollowedUser, error) {
+		rows, err := c.db.ListFollowers(r.Context(), database.ListFollowersParams{
+			UserID:         userID,
+			AfterCreatedAt: page.afterCreatedAt(),
+			AfterID:        page.afterID(),
+			Limit:          page.queryLimit(),
+		})
+		users := make([]FollowedUser, len(rows))
+		for i, row := range rows {
+			users[i] = FollowedUser{ID: row.UserID, FollowedAt: row.CreatedAt}
+		}
+		return users, err
+	})
+}
+
+// handlerGetFollowing is an HTTP handler function to list the users a user follows, newest first
+func (c *apiConfig) handlerGetFollowing(w http.ResponseWriter, r *http.Request) {
+	c.respondFollowList(w, r, func(userID uuid.UUID, page pageParams) ([]FollowedUser, error) {
+		rows, err := c.db.ListFollowing(r.Context(), database.ListFollowingParams{
+			UserID:         userID,
+			AfterCreatedAt: page.afterCreatedAt(),
+			AfterID:        page.afterID(),
+			Limit:          page.queryLimit(),
+		})
+		users := make([]FollowedUser, len(rows))
+		for i, row := range rows {
+			users[i] = FollowedUser{ID: row.UserID, FollowedAt: row.CreatedAt}
+		}
+		return users, err
+	})
+}
+
+// respondFollowList validates the path and pagination of a follow list request,
+// then responds with the page that list returns
+func (c *apiConfig) respondFollowList(w http.ResponseWriter, r *http.Request, list func(uuid.UUID, pageParams) ([]FollowedUser, error)) {
+	// Parse the user ID from the path
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondJson(w, http.StatusBadRequest, errorResponse{Error: "Invalid user ID format"})
+		return
+	}
+	// Validate limit and cursor
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondJson(w, http.StatusBadRequest, errorResponse{Error: "Invalid pagination: " + err.Error()})
+		return
+	}
+
+	users, err := list(userID, page)
+	if err != nil {
+		log.Println("Error getting follows from database:", err)
+		respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error"})
+		return
+	}
+
+	resp := followPage{Users: users}
+	if len(users) > page.Limit {
+		resp.Users = users[:page.Limit]
+		last := resp.Users[page.Limit-1]
+		resp.NextCursor = pageCursor{CreatedAt: last.FollowedAt, ID: last.ID}.encode()
+	}
+	respondJson(w, http.StatusOK, resp)
+}
+
+// handlerGetTimeline is an HTTP handler function to get a page of chirps from
+// the users the authenticated user follows, newest first
+func (c *apiConfig) handlerGetTimeline(w http.ResponseWriter, r *http.Request) {
+	// Get Bearer token from the request headers
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondJson(w, http.StatusUnauthorized, errorResponse{Error: "No access token provided"})
+		return
+	}
+	// Validate access token and get user ID
+	userID, err := auth.ValidateJWT(token, c.JwtSecret)
+	if err != nil {
+		respondJson(w, http.StatusUnauthorized, errorResponse{Error: "Unauthorized"})
+		return
+	}
+	// Validate limit and cursor
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondJson(w, http.StatusBadRequest, errorResponse{Error: "Invalid pagination: " + err.Error()})
+		return
+	}
+
+	chirps, err := c.db.ListTimeline(r.Context(), database.ListTimelineParams{
+		FollowerID:     userID,
+		AfterCreatedAt: page.afterCreatedAt(),
+		AfterID:        page.afterID(),
+		Limit:          page.queryLimit(),
+	})
+	if err != nil {
+		log.Println("Error getting timeline from database:", err)
+		respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error"})
+		return
+	}
+	resp := newChirpPage(newChirps(chirps), page)
+	if err := c.withReplyCounts(r.Context(), len(resp.Chirps), func(i int) *Chirp { return &resp.Chirps[i] }); err != nil {
+		log.Println("Error counting replies in database:", err)
+		respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error"})
+		return
+	}
+
+	respondJson(w, http.StatusOK, resp)
+}
@@ -7,79 +7,338 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countReplies = `-- name: CountReplies :many
+SELECT parent_id, count(*) AS reply_count FROM chirps
+WHERE parent_id = ANY($1::uuid[]) AND deleted_at IS NULL
+GROUP BY parent_id
+`
+
+type CountRepliesRow struct {
+	ParentID   uuid.NullUUID
+	ReplyCount int64
+}
+
+func (q *Queries) CountReplies(ctx context.Context, ids []uuid.UUID) ([]CountRepliesRow, error) {
+	rows, err := q.db.QueryContext(ctx, countReplies, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountRepliesRow
+	for rows.Next() {
+		var i CountRepliesRow
+		if err := rows.Scan(
+			&i.ParentID,
+			&i.ReplyCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, user_id, body)
-VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
-RETURNING id, created_at, updated_at, user_id, body
+INSERT INTO chirps (id, created_at, updated_at, user_id, body, parent_id)
+SELECT gen_random_uuid(), NOW(), NOW(), $1::uuid, $2::text, $3::uuid
+WHERE $3::uuid IS NULL
+    OR EXISTS (SELECT 1 FROM chirps WHERE id = $3 AND deleted_at IS NULL FOR SHARE)
+RETURNING id, created_at, updated_at, user_id, body, parent_id, deleted_at
 `
 
 type CreateChirpParams struct {
-	UserID uuid.UUID
-	Body   string
+	UserID   uuid.UUID
+	Body     string
+	ParentID uuid.NullUUID
+}
+
+type CreateChirpRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Body      string
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
 }
 
-func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.UserID, arg.Body)
-	var i Chirp
+// A reply is only added while its parent is not deleted, locking the parent
+// so it cannot be deleted before the reply is committed
+func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (CreateChirpRow, error) {
+	row := q.db.QueryRowContext(ctx, createChirp, arg.UserID, arg.Body, arg.ParentID)
+	var i CreateChirpRow
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.Body,
+		&i.ParentID,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
-const deleteChirpByID = `-- name: DeleteChirpByID :exec
-DELETE FROM chirps WHERE id = $1
+const deleteChirpWithoutReplies = `-- name: DeleteChirpWithoutReplies :execrows
+DELETE FROM chirps
+WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM chirps AS replies WHERE replies.parent_id = chirps.id)
 `
 
-func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteChirpByID, id)
-	return err
+func (q *Queries) DeleteChirpWithoutReplies(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteChirpWithoutReplies, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, user_id, body FROM chirps WHERE id = $1
+SELECT id, created_at, updated_at, user_id, body, parent_id, deleted_at FROM chirps WHERE id = $1
 `
 
-func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+type GetChirpRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Body      string
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+}
+
+func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (GetChirpRow, error) {
 	row := q.db.QueryRowContext(ctx, getChirp, id)
-	var i Chirp
+	var i GetChirpRow
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.Body,
+		&i.ParentID,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
-const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, user_id, body FROM chirps ORDER BY created_at ASC
+const getChirpForUpdate = `-- name: GetChirpForUpdate :one
+SELECT id, created_at, updated_at, user_id, body, parent_id, deleted_at FROM chirps WHERE id = $1 FOR UPDATE
+`
+
+type GetChirpForUpdateRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Body      string
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+}
+
+func (q *Queries) GetChirpForUpdate(ctx context.Context, id uuid.UUID) (GetChirpForUpdateRow, error) {
+	row := q.db.QueryRowContext(ctx, getChirpForUpdate, id)
+	var i GetChirpForUpdateRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+		&i.ParentID,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getThread = `-- name: GetThread :many
+WITH RECURSIVE ancestors AS (
+    SELECT chirps.id, chirps.parent_id FROM chirps WHERE chirps.id = $1
+    UNION ALL
+    SELECT chirps.id, chirps.parent_id FROM chirps
+    JOIN ancestors ON chirps.id = ancestors.parent_id
+), thread AS (
+    SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.user_id, chirps.body,
+        chirps.parent_id, chirps.deleted_at, 0 AS depth
+    FROM chirps
+    WHERE chirps.id = (SELECT ancestors.id FROM ancestors WHERE ancestors.parent_id IS NULL)
+    UNION ALL
+    SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.user_id, chirps.body,
+        chirps.parent_id, chirps.deleted_at, thread.depth + 1
+    FROM chirps
+    JOIN thread ON chirps.parent_id = thread.id
+)
+SELECT id, created_at, updated_at, user_id, body, parent_id, deleted_at, depth FROM thread
+ORDER BY depth, created_at, id
+`
+
+type GetThreadRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Body      string
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	Depth     int32
+}
+
+func (q *Queries) GetThread(ctx context.Context, id uuid.UUID) ([]GetThreadRow, error) {
+	rows, err := q.db.QueryContext(ctx, getThread, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetThreadRow
+	for rows.Next() {
+		var i GetThreadRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Body,
+			&i.ParentID,
+			&i.DeletedAt,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, user_id, body, parent_id, deleted_at FROM chirps
+WHERE deleted_at IS NULL
+AND ($1::uuid IS NULL OR user_id = $1)
+AND ($2::timestamp IS NULL
+    OR (created_at, id) > ($2, $3::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type ListChirpsAscParams struct {
+	UserID         uuid.NullUUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	Limit          int32
+}
+
+type ListChirpsAscRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Body      string
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]ListChirpsAscRow, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.UserID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListChirpsAscRow
+	for rows.Next() {
+		var i ListChirpsAscRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Body,
+			&i.ParentID,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, user_id, body, parent_id, deleted_at FROM chirps
+WHERE deleted_at IS NULL
+AND ($1::uuid IS NULL OR user_id = $1)
+AND ($2::timestamp IS NULL
+    OR (created_at, id) < ($2, $3::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $4
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+type ListChirpsDescParams struct {
+	UserID         uuid.NullUUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	Limit          int32
+}
+
+type ListChirpsDescRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Body      string
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]ListChirpsDescRow, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.UserID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Chirp
+	var items []ListChirpsDescRow
 	for rows.Next() {
-		var i Chirp
+		var i ListChirpsDescRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.Body,
+			&i.ParentID,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -94,25 +353,135 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirpsPerUser = `-- name: GetChirpsPerUser :many
-SELECT id, created_at, updated_at, user_id, body FROM chirps WHERE user_id = $1 ORDER BY created_at ASC
+const listTimeline = `-- name: ListTimeline :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.user_id, chirps.body,
+    chirps.parent_id, chirps.deleted_at
+FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = $1
+AND chirps.deleted_at IS NULL
+AND ($2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < ($2, $3::uuid))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $4
 `
 
-func (q *Queries) GetChirpsPerUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsPerUser, userID)
+type ListTimelineParams struct {
+	FollowerID     uuid.UUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	Limit          int32
+}
+
+type ListTimelineRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Body      string
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+}
+
+func (q *Queries) ListTimeline(ctx context.Context, arg ListTimelineParams) ([]ListTimelineRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTimeline,
+		arg.FollowerID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTimelineRow
+	for rows.Next() {
+		var i ListTimelineRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Body,
+			&i.ParentID,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT id, created_at, updated_at, user_id, body, parent_id, rank,
+    ts_headline('english', replace(replace(replace(body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+        to_tsquery('english', $1), 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')::text AS headline
+FROM (
+    SELECT id, created_at, updated_at, user_id, body, parent_id,
+        ts_rank(search, to_tsquery('english', $1))::real AS rank
+    FROM chirps
+    WHERE search @@ to_tsquery('english', $1)
+    AND deleted_at IS NULL
+    AND ($2::uuid IS NULL OR user_id = $2)
+) AS matches
+WHERE $3::real IS NULL
+    OR (rank, created_at, id) < ($3, $4::timestamp, $5::uuid)
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT $6
+`
+
+type SearchChirpsParams struct {
+	Query          string
+	UserID         uuid.NullUUID
+	AfterRank      sql.NullFloat64
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	Limit          int32
+}
+
+type SearchChirpsRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Body      string
+	ParentID  uuid.NullUUID
+	Rank      float32
+	Headline  string
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		arg.UserID,
+		arg.AfterRank,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Chirp
+	var items []SearchChirpsRow
 	for rows.Next() {
-		var i Chirp
+		var i SearchChirpsRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.Body,
+			&i.ParentID,
+			&i.Rank,
+			&i.Headline,
 		); err != nil {
 			return nil, err
 		}
@@ -126,3 +495,14 @@ func (q *Queries) GetChirpsPerUser(ctx context.Context, userID uuid.UUID) ([]Chi
 	}
 	return items, nil
 }
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :exec
+UPDATE chirps
+SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) SoftDeleteChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, softDeleteChirp, id)
+	return err
+}
@@ -17,6 +17,15 @@ type Chirp struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	Body      string
+	Search    interface{}
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
 }
 
 type RefreshToken struct {
@@ -27,6 +27,7 @@ func main() {
 	apiCfg := apiConfig{
 		fileserverHits: atomic.Int32{},
 		db:             database.New(db),
+		dbConn:         db,
 		platform:       env.Platform,
 		JwtSecret:      env.JwtSecret,
 		PolkaKey:       env.PolkaKey,
@@ -43,10 +44,12 @@ func main() {
 
 	mux.HandleFunc("GET /api/healthz", handlerReadiness) // healthcheck endpoint
 
-	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)                // get all chirps
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirp)       // get a chirps
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerAddChirp)                // add chirp
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp) // delete chirp
+	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)                  // get all chirps
+	mux.HandleFunc("GET /api/chirps/search", apiCfg.handlerSearchChirps)        // full-text search chirps
+	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirp)         // get a chirps
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", apiCfg.handlerGetThread) // get the conversation of a chirp
+	mux.HandleFunc("POST /api/chirps", apiCfg.handlerAddChirp)                  // add chirp
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)   // delete chirp
 
 	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)     // login endpoint
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh) // create new acces token
@@ -55,6 +58,12 @@ func main() {
 	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser) // update user email and password
 	mux.HandleFunc("POST /api/users", apiCfg.handlerAddUser)   // add users by email
 
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.handlerFollowUser)     // follow a user
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.handlerUnfollowUser) // unfollow a user
+	mux.HandleFunc("GET /api/users/{userID}/followers", apiCfg.handlerGetFollowers) // list followers of a user
+	mux.HandleFunc("GET /api/users/{userID}/following", apiCfg.handlerGetFollowing) // list users a user follows
+	mux.HandleFunc("GET /api/timeline", apiCfg.handlerGetTimeline)                  // chirps of followed users
+
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerPolkaWebhook) // polka webhook handler
 
 	// A simple way to run HTTP server with configured parameters
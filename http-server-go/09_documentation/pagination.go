@@ -0,0 +1,136 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Page sizes of the list endpoints
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// chirpPage is the response envelope of the paginated chirp endpoints
+type chirpPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"` // empty on the last page
+}
+
+// pageCursor is the (created_at, id) key of the last chirp of a page, the next
+// page starts right after it. The id breaks ties between equal timestamps.
+// Search results are ordered by rank first, so their key starts with it.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+	Rank      *float32 // only for search results
+}
+
+// encode returns the cursor as an opaque string for clients
+func (c pageCursor) encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + c.ID.String()
+	if c.Rank != nil {
+		raw += "," + strconv.FormatFloat(float64(*c.Rank), 'g', -1, 32)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor returned by encode
+func decodeCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, err
+	}
+	parts := strings.Split(string(raw), ",")
+	if len(parts) != 2 && len(parts) != 3 {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+	c := pageCursor{}
+	if c.CreatedAt, err = time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return pageCursor{}, err
+	}
+	if c.ID, err = uuid.Parse(parts[1]); err != nil {
+		return pageCursor{}, err
+	}
+	if len(parts) == 3 {
+		rank, err := strconv.ParseFloat(parts[2], 32)
+		if err != nil {
+			return pageCursor{}, err
+		}
+		c.Rank = new(float32)
+		*c.Rank = float32(rank)
+	}
+	return c, nil
+}
+
+// pageParams are the pagination query parameters of a list request
+type pageParams struct {
+	Limit int
+	After *pageCursor // nil for the first page
+}
+
+// parsePageParams reads the optional `limit` and `cursor` query parameters
+func parsePageParams(r *http.Request) (pageParams, error) {
+	params := pageParams{Limit: defaultPageLimit}
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return pageParams{}, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageLimit))
+		}
+		params.Limit = limit
+	}
+	if s := r.URL.Query().Get("cursor"); s != "" {
+		after, err := decodeCursor(s)
+		if err != nil {
+			return pageParams{}, errors.New("malformed cursor")
+		}
+		params.After = &after
+	}
+	return params, nil
+}
+
+// afterCreatedAt, afterID, and afterRank are the cursor as nullable query arguments
+func (p pageParams) afterCreatedAt() sql.NullTime {
+	if p.After == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: p.After.CreatedAt, Valid: true}
+}
+
+func (p pageParams) afterID() uuid.NullUUID {
+	if p.After == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: p.After.ID, Valid: true}
+}
+
+func (p pageParams) afterRank() sql.NullFloat64 {
+	if p.After == nil || p.After.Rank == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: float64(*p.After.Rank), Valid: true}
+}
+
+// queryLimit is one more than the page size, telling whether there is a next page
+func (p pageParams) queryLimit() int32 {
+	return int32(p.Limit + 1)
+}
+
+// newChirpPage trims the chirps fetched with queryLimit to the page size, with
+// the cursor of the next page if there is one
+func newChirpPage(chirps []Chirp, params pageParams) chirpPage {
+	page := chirpPage{Chirps: chirps}
+	if len(chirps) > params.Limit {
+		page.Chirps = chirps[:params.Limit]
+		last := page.Chirps[params.Limit-1]
+		page.NextCursor = pageCursor{CreatedAt: last.CreatedAt, ID: last.ID}.encode()
+	}
+	return page
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPageCursor(t *testing.T) {
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	id := uuid.New()
+	rank := float32(0.0607927)
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := map[string]struct {
+		cursor   string
+		expected pageCursor
+		wantErr  bool
+	}{
+		"Chirp cursor round trip": {
+			cursor:   pageCursor{CreatedAt: createdAt, ID: id}.encode(),
+			expected: pageCursor{CreatedAt: createdAt, ID: id},
+			wantErr:  false,
+		},
+		"Search cursor round trip": {
+			cursor:   pageCursor{CreatedAt: createdAt, ID: id, Rank: &rank}.encode(),
+			expected: pageCursor{CreatedAt: createdAt, ID: id, Rank: &rank},
+			wantErr:  false,
+		},
+		"Non-UTC time is encoded as UTC": {
+			cursor:   pageCursor{CreatedAt: createdAt.In(time.FixedZone("UTC+7", 7*3600)), ID: id}.encode(),
+			expected: pageCursor{CreatedAt: createdAt, ID: id},
+			wantErr:  false,
+		},
+		"Not base64": {
+			cursor:  "not base64!",
+			wantErr: true,
+		},
+		"Single part": {
+			cursor:  raw("2025-01-02T03:04:05Z"),
+			wantErr: true,
+		},
+		"Too many parts": {
+			cursor:  raw("2025-01-02T03:04:05Z," + id.String() + ",0.5,extra"),
+			wantErr: true,
+		},
+		"Invalid time": {
+			cursor:  raw("yesterday," + id.String()),
+			wantErr: true,
+		},
+		"Invalid ID": {
+			cursor:  raw("2025-01-02T03:04:05Z,not-a-uuid"),
+			wantErr: true,
+		},
+		"Invalid rank": {
+			cursor:  raw("2025-01-02T03:04:05Z," + id.String() + ",high"),
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := decodeCursor(tt.cursor)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeCursor() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.CreatedAt.Equal(tt.expected.CreatedAt) || got.ID != tt.expected.ID {
+				t.Errorf("decodeCursor() got = %v, expected %v", got, tt.expected)
+			}
+			if (got.Rank == nil) != (tt.expected.Rank == nil) || (got.Rank != nil && *got.Rank != *tt.expected.Rank) {
+				t.Errorf("decodeCursor() got rank = %v, expected rank %v", got.Rank, tt.expected.Rank)
+			}
+		})
+	}
+}
+
+func TestNewChirpPage(t *testing.T) {
+	base := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	chirps := make([]Chirp, 4)
+	for i := range chirps {
+		chirps[i] = Chirp{ID: uuid.New(), CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+	}
+
+	tests := map[string]struct {
+		chirps       []Chirp
+		limit        int
+		expectedLen  int
+		expectedNext *Chirp // last chirp of the page, nil without a next page
+	}{
+		"Empty page": {
+			chirps:       []Chirp{},
+			limit:        2,
+			expectedLen:  0,
+			expectedNext: nil,
+		},
+		"Fewer chirps than the limit": {
+			chirps:       chirps[:1],
+			limit:        2,
+			expectedLen:  1,
+			expectedNext: nil,
+		},
+		"Exactly the limit": {
+			chirps:       chirps[:2],
+			limit:        2,
+			expectedLen:  2,
+			expectedNext: nil,
+		},
+		"One more than the limit": {
+			chirps:       chirps[:3],
+			limit:        2,
+			expectedLen:  2,
+			expectedNext: &chirps[1],
+		},
+		"Page of one": {
+			chirps:       chirps[:2],
+			limit:        1,
+			expectedLen:  1,
+			expectedNext: &chirps[0],
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			page := newChirpPage(tt.chirps, pageParams{Limit: tt.limit})
+			if len(page.Chirps) != tt.expectedLen {
+				t.Errorf("newChirpPage() got %d chirps, expected %d", len(page.Chirps), tt.expectedLen)
+			}
+			if tt.expectedNext == nil {
+				if page.NextCursor != "" {
+					t.Errorf("newChirpPage() got cursor %q on the last page", page.NextCursor)
+				}
+				return
+			}
+			cursor, err := decodeCursor(page.NextCursor)
+			if err != nil {
+				t.Errorf("newChirpPage() cursor error = %v", err)
+				return
+			}
+			if cursor.ID != tt.expectedNext.ID || !cursor.CreatedAt.Equal(tt.expectedNext.CreatedAt) {
+				t.Errorf("newChirpPage() cursor = %v, expected the key of %v", cursor, tt.expectedNext.ID)
+			}
+		})
+	}
+}
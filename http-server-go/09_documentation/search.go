@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/akhdanfadh/bootdev-courses/http-server-go/internal/database"
+	"github.com/google/uuid"
+)
+
+// searchResult is a chirp matching a search, with its relevance and the body
+// as HTML-escaped text where matched terms are wrapped in <mark> tags
+type searchResult struct {
+	Chirp
+	Rank     float32 `json:"rank"`
+	Headline string  `json:"headline"`
+}
+
+// searchPage is the response envelope of the search endpoint, see chirpPage
+type searchPage struct {
+	Chirps     []searchResult `json:"chirps"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// handlerSearchChirps is an HTTP handler function to search chirps, best matches first
+func (c *apiConfig) handlerSearchChirps(w http.ResponseWriter, r *http.Request) {
+	userIDString := r.URL.Query().Get("author_id") // optional, could be empty
+
+	// Build the full-text query
+	query, err := buildTSQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		respondJson(w, http.StatusBadRequest, errorResponse{Error: "Invalid search query: " + err.Error()})
+		return
+	}
+	// Parse user ID from the query parameter, if filtering by author
+	var userID uuid.NullUUID
+	if userIDString != "" {
+		id, err := uuid.Parse(userIDString)
+		if err != nil {
+			respondJson(w, http.StatusBadRequest, errorResponse{Error: "Invalid user ID format"})
+			return
+		}
+		userID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	// Validate limit and cursor, which must come from a previous search
+	page, err := parsePageParams(r)
+	if err == nil && page.After != nil && page.After.Rank == nil {
+		err = errors.New("cursor is not from a search")
+	}
+	if err != nil {
+		respondJson(w, http.StatusBadRequest, errorResponse{Error: "Invalid pagination: " + err.Error()})
+		return
+	}
+
+	// Search the database
+	rows, err := c.db.SearchChirps(r.Context(), database.SearchChirpsParams{
+		Query:          query,
+		UserID:         userID,
+		AfterRank:      page.afterRank(),
+		AfterCreatedAt: page.afterCreatedAt(),
+		AfterID:        page.afterID(),
+		Limit:          page.queryLimit(),
+	})
+	if err != nil {
+		log.Println("Error searching chirps in database:", err)
+		respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error"})
+		return
+	}
+
+	results := make([]searchResult, len(rows))
+	for i, row := range rows {
+		results[i] = searchResult{
+			Chirp: Chirp{
+				ID:        row.ID,
+				CreatedAt: row.CreatedAt,
+				UpdatedAt: row.UpdatedAt,
+				UserID:    row.UserID,
+				Body:      row.Body,
+				ParentID:  uuidPtr(row.ParentID),
+			},
+			Rank:     row.Rank,
+			Headline: row.Headline,
+		}
+	}
+	resp := searchPage{Chirps: results}
+	if len(results) > page.Limit {
+		resp.Chirps = results[:page.Limit]
+		last := resp.Chirps[page.Limit-1]
+		resp.NextCursor = pageCursor{CreatedAt: last.CreatedAt, ID: last.ID, Rank: &last.Rank}.encode()
+	}
+	if err := c.withReplyCounts(r.Context(), len(resp.Chirps), func(i int) *Chirp { return &resp.Chirps[i].Chirp }); err != nil {
+		log.Println("Error counting replies in database:", err)
+		respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error"})
+		return
+	}
+
+	respondJson(w, http.StatusOK, resp)
+}
+
+// buildTSQuery converts a search query to the to_tsquery syntax, matching
+// chirps with all of its terms. A term is a word, a word ending with * to match
+// it as a prefix, e.g., `chirp*`, or a "quoted phrase" whose words must be
+// adjacent. Anything but letters and digits separates words, so users cannot
+// inject tsquery operators.
+func buildTSQuery(q string) (string, error) {
+	var terms []string
+	for i, part := range strings.Split(q, `"`) {
+		if i%2 == 1 { // inside quotes
+			if words := tsWords(part); len(words) > 0 {
+				terms = append(terms, "("+strings.Join(words, " <-> ")+")")
+			}
+			continue
+		}
+		for _, field := range strings.Fields(part) {
+			words := tsWords(field)
+			if len(words) == 0 {
+				continue
+			}
+			if strings.HasSuffix(field, "*") {
+				words[len(words)-1] += ":*"
+			}
+			terms = append(terms, strings.Join(words, " <-> ")) // e.g., "e-mail"
+		}
+	}
+	if len(terms) == 0 {
+		return "", errors.New("q must contain a word")
+	}
+	return strings.Join(terms, " & "), nil
+}
+
+// tsWords splits s into its words of letters and digits
+func tsWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
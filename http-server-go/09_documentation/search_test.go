@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+func TestBuildTSQuery(t *testing.T) {
+	tests := map[string]struct {
+		q        string
+		expected string
+		wantErr  bool
+	}{
+		"Single word": {
+			q:        "gopher",
+			expected: "gopher",
+			wantErr:  false,
+		},
+		"All words must match": {
+			q:        "  go   gopher ",
+			expected: "go & gopher",
+			wantErr:  false,
+		},
+		"Prefix": {
+			q:        "chirp*",
+			expected: "chirp:*",
+			wantErr:  false,
+		},
+		"Quoted phrase": {
+			q:        `"hello big world" go`,
+			expected: "(hello <-> big <-> world) & go",
+			wantErr:  false,
+		},
+		"Unterminated quote is a phrase": {
+			q:        `go "hello world`,
+			expected: "go & (hello <-> world)",
+			wantErr:  false,
+		},
+		"Hyphenated word is a phrase": {
+			q:        "e-mail",
+			expected: "e <-> mail",
+			wantErr:  false,
+		},
+		"Non-ASCII letters": {
+			q:        "café 日本",
+			expected: "café & 日本",
+			wantErr:  false,
+		},
+		"Operators are separators": {
+			q:        "a|b & !c <-> (d)",
+			expected: "a <-> b & c & d",
+			wantErr:  false,
+		},
+		"Weights and prefix syntax": {
+			q:        "go:* go:AB",
+			expected: "go:* & go <-> AB", // only the trailing * is kept, as a prefix
+			wantErr:  false,
+		},
+		"SQL quotes": {
+			q:        "x'); DROP TABLE chirps; --",
+			expected: "x & DROP & TABLE & chirps",
+			wantErr:  false,
+		},
+		"Empty": {
+			q:       "",
+			wantErr: true,
+		},
+		"Only operators": {
+			q:       `!&| <-> ( ) * :* ""`,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := buildTSQuery(tt.q)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("buildTSQuery() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.expected {
+				t.Errorf("buildTSQuery() got = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
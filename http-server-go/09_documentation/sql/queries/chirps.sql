@@ -1,16 +1,95 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, user_id, body)
-VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
-RETURNING *;
+-- A reply is only added while its parent is not deleted, locking the parent
+-- so it cannot be deleted before the reply is committed
+INSERT INTO chirps (id, created_at, updated_at, user_id, body, parent_id)
+SELECT gen_random_uuid(), NOW(), NOW(), sqlc.arg('user_id')::uuid, sqlc.arg('body')::text, sqlc.narg('parent_id')::uuid
+WHERE sqlc.narg('parent_id')::uuid IS NULL
+    OR EXISTS (SELECT 1 FROM chirps WHERE id = sqlc.narg('parent_id') AND deleted_at IS NULL FOR SHARE)
+RETURNING id, created_at, updated_at, user_id, body, parent_id, deleted_at;
 
--- name: GetChirps :many
-SELECT * FROM chirps ORDER BY created_at ASC;
+-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, user_id, body, parent_id, deleted_at FROM chirps
+WHERE deleted_at IS NULL
+AND (sqlc.narg('user_id')::uuid IS NULL OR user_id = sqlc.narg('user_id'))
+AND (sqlc.narg('after_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
 
--- name: GetChirpsPerUser :many
-SELECT * FROM chirps WHERE user_id = $1 ORDER BY created_at ASC;
+-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, user_id, body, parent_id, deleted_at FROM chirps
+WHERE deleted_at IS NULL
+AND (sqlc.narg('user_id')::uuid IS NULL OR user_id = sqlc.narg('user_id'))
+AND (sqlc.narg('after_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListTimeline :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.user_id, chirps.body,
+    chirps.parent_id, chirps.deleted_at
+FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = sqlc.arg('follower_id')
+AND chirps.deleted_at IS NULL
+AND (sqlc.narg('after_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit');
 
 -- name: GetChirp :one
-SELECT * FROM chirps WHERE id = $1;
+SELECT id, created_at, updated_at, user_id, body, parent_id, deleted_at FROM chirps WHERE id = $1;
+
+-- name: GetChirpForUpdate :one
+SELECT id, created_at, updated_at, user_id, body, parent_id, deleted_at FROM chirps WHERE id = $1 FOR UPDATE;
+
+-- name: DeleteChirpWithoutReplies :execrows
+DELETE FROM chirps
+WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM chirps AS replies WHERE replies.parent_id = chirps.id);
+
+-- name: SoftDeleteChirp :exec
+UPDATE chirps
+SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: CountReplies :many
+SELECT parent_id, count(*) AS reply_count FROM chirps
+WHERE parent_id = ANY(sqlc.arg('ids')::uuid[]) AND deleted_at IS NULL
+GROUP BY parent_id;
+
+-- name: GetThread :many
+WITH RECURSIVE ancestors AS (
+    SELECT chirps.id, chirps.parent_id FROM chirps WHERE chirps.id = sqlc.arg('id')
+    UNION ALL
+    SELECT chirps.id, chirps.parent_id FROM chirps
+    JOIN ancestors ON chirps.id = ancestors.parent_id
+), thread AS (
+    SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.user_id, chirps.body,
+        chirps.parent_id, chirps.deleted_at, 0 AS depth
+    FROM chirps
+    WHERE chirps.id = (SELECT ancestors.id FROM ancestors WHERE ancestors.parent_id IS NULL)
+    UNION ALL
+    SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.user_id, chirps.body,
+        chirps.parent_id, chirps.deleted_at, thread.depth + 1
+    FROM chirps
+    JOIN thread ON chirps.parent_id = thread.id
+)
+SELECT id, created_at, updated_at, user_id, body, parent_id, deleted_at, depth FROM thread
+ORDER BY depth, created_at, id;
 
--- name: DeleteChirpByID :exec
-DELETE FROM chirps WHERE id = $1;
+-- name: SearchChirps :many
+SELECT id, created_at, updated_at, user_id, body, parent_id, rank,
+    ts_headline('english', replace(replace(replace(body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+        to_tsquery('english', sqlc.arg('query')), 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')::text AS headline
+FROM (
+    SELECT id, created_at, updated_at, user_id, body, parent_id,
+        ts_rank(search, to_tsquery('english', sqlc.arg('query')))::real AS rank
+    FROM chirps
+    WHERE search @@ to_tsquery('english', sqlc.arg('query'))
+    AND deleted_at IS NULL
+    AND (sqlc.narg('user_id')::uuid IS NULL OR user_id = sqlc.narg('user_id'))
+) AS matches
+WHERE sqlc.narg('after_rank')::real IS NULL
+    OR (rank, created_at, id) < (sqlc.narg('after_rank'), sqlc.narg('after_created_at')::timestamp, sqlc.narg('after_id')::uuid)
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Deleting a chirp through the API keeps it as an empty row while it has
+-- replies. Deleting a user cascades to their chirps and bypasses that, so
+-- replies from other users to those chirps become top-level chirps.
+ALTER TABLE chirps ADD COLUMN parent_id UUID REFERENCES chirps (id) ON DELETE SET NULL;
+ALTER TABLE chirps ADD COLUMN deleted_at TIMESTAMP;
+CREATE INDEX chirps_parent_id_idx ON chirps (parent_id);
+
+-- +goose Down
+DROP INDEX chirps_parent_id_idx;
+ALTER TABLE chirps DROP COLUMN deleted_at;
+ALTER TABLE chirps DROP COLUMN parent_id;
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/akhdanfadh/bootdev-courses/http-server-go/internal/database"
+	"github.com/google/uuid"
+)
+
+// ThreadChirp is a chirp of a conversation with its replies, oldest first.
+// A deleted chirp with replies stays in its thread with an empty body.
+type ThreadChirp struct {
+	Chirp
+	Deleted bool           `json:"deleted,omitempty"`
+	Replies []*ThreadChirp `json:"replies"`
+}
+
+// handlerGetThread is an HTTP handler function to get the whole conversation
+// a chirp belongs to, as a tree from the chirp that started it
+func (c *apiConfig) handlerGetThread(w http.ResponseWriter, r *http.Request) {
+	// Parse the chirp ID from the path
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondJson(w, http.StatusBadRequest, errorResponse{Error: "Invalid chirp ID"})
+		return
+	}
+
+	// Get the thread from the database, parents come before their replies
+	rows, err := c.db.GetThread(r.Context(), chirpID)
+	if err != nil {
+		log.Println("Error getting thread from database:", err)
+		respondJson(w, http.StatusInternalServerError, errorResponse{Error: "Internal server error"})
+		return
+	}
+	if len(rows) == 0 {
+		respondJson(w, http.StatusNotFound, errorResponse{Error: "Chirp not found"})
+		return
+	}
+
+	respondJson(w, http.StatusOK, buildThread(rows))
+}
+
+// buildThread assembles the rows of GetThread into a tree, counting replies
+// that are not deleted. Parents must come before their replies, the first
+// row is the root.
+func buildThread(rows []database.GetThreadRow) *ThreadChirp {
+	if len(rows) == 0 {
+		return nil
+	}
+	nodes := make(map[uuid.UUID]*ThreadChirp, len(rows))
+	for _, row := range rows {
+		node := &ThreadChirp{
+			Chirp: Chirp{
+				ID:        row.ID,
+				CreatedAt: row.CreatedAt,
+				UpdatedAt: row.UpdatedAt,
+				UserID:    row.UserID,
+				Body:      row.Body,
+				ParentID:  uuidPtr(row.ParentID),
+			},
+			Deleted: row.DeletedAt.Valid,
+			Replies: make([]*ThreadChirp, 0),
+		}
+		nodes[row.ID] = node
+		if parent, ok := nodes[row.ParentID.UUID]; ok && row.ParentID.Valid {
+			parent.Replies = append(parent.Replies, node)
+			if !node.Deleted {
+				parent.ReplyCount++
+			}
+		}
+	}
+	return nodes[rows[0].ID]
+}
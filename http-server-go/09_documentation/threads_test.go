@@ -0,0 +1,87 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhdanfadh/bootdev-courses/http-server-go/internal/database"
+	"github.com/google/uuid"
+)
+
+// renderThread writes a thread as "name(reply count)[replies]", with deleted
+// chirps marked by a leading "-", e.g., "a(1)[b(0)[] -c(0)[]]"
+func renderThread(node *ThreadChirp, names map[uuid.UUID]string) string {
+	if node == nil {
+		return "<nil>"
+	}
+	replies := make([]string, len(node.Replies))
+	for i, reply := range node.Replies {
+		replies[i] = renderThread(reply, names)
+	}
+	deleted := ""
+	if node.Deleted {
+		deleted = "-"
+	}
+	return fmt.Sprintf("%s%s(%d)[%s]", deleted, names[node.ID], node.ReplyCount, strings.Join(replies, " "))
+}
+
+func TestBuildThread(t *testing.T) {
+	ids := map[string]uuid.UUID{}
+	names := map[uuid.UUID]string{}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		ids[name] = uuid.New()
+		names[ids[name]] = name
+	}
+	// row returns the thread row of a chirp, a name starting with "-" is deleted
+	row := func(name, parent string) database.GetThreadRow {
+		r := database.GetThreadRow{CreatedAt: time.Now(), Body: "chirp"}
+		if deleted, ok := strings.CutPrefix(name, "-"); ok {
+			name = deleted
+			r.Body = ""
+			r.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+		r.ID = ids[name]
+		if parent != "" {
+			r.ParentID = uuid.NullUUID{UUID: ids[parent], Valid: true}
+		}
+		return r
+	}
+
+	tests := map[string]struct {
+		rows     []database.GetThreadRow
+		expected string
+	}{
+		"No rows": {
+			rows:     nil,
+			expected: "<nil>",
+		},
+		"Chirp without replies": {
+			rows:     []database.GetThreadRow{row("a", "")},
+			expected: "a(0)[]",
+		},
+		"Replies keep their order": {
+			rows:     []database.GetThreadRow{row("a", ""), row("b", "a"), row("c", "a")},
+			expected: "a(2)[b(0)[] c(0)[]]",
+		},
+		"Nested replies": {
+			rows:     []database.GetThreadRow{row("a", ""), row("b", "a"), row("e", "a"), row("c", "b"), row("d", "c")},
+			expected: "a(2)[b(1)[c(1)[d(0)[]]] e(0)[]]",
+		},
+		"Deleted chirps stay without being counted": {
+			rows:     []database.GetThreadRow{row("-a", ""), row("-b", "a"), row("c", "a"), row("d", "b")},
+			expected: "-a(1)[-b(1)[d(0)[]] c(0)[]]",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := renderThread(buildThread(tt.rows), names)
+			if got != tt.expected {
+				t.Errorf("buildThread() got = %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}